@@ -15,10 +15,12 @@ package ast
 
 import (
 	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/parser/opcode"
 )
 
 var (
 	_ DMLNode = &DeleteStmt{}
+	_ DMLNode = &HandlerStmt{}
 	_ DMLNode = &InsertStmt{}
 	_ DMLNode = &UnionStmt{}
 	_ DMLNode = &UpdateStmt{}
@@ -67,6 +69,10 @@ type Join struct {
 	Tp JoinType
 	// On represents join on condition.
 	On *OnCondition
+	// Using represents the columns in the USING clause, which are equal on both sides and appear once in the result.
+	Using []*ColumnName
+	// NaturalJoin represents a NATURAL join, which is a USING join of all the columns with the same names.
+	NaturalJoin bool
 }
 
 // Accept implements Node Accept interface.
@@ -244,6 +250,23 @@ const (
 	SelectLockInShareMode
 )
 
+// SelectResultSize is the size of the GROUP BY or DISTINCT result hinted by SQL_SMALL_RESULT or SQL_BIG_RESULT.
+type SelectResultSize int
+
+// Select result sizes.
+const (
+	SelectResultSizeDefault SelectResultSize = iota
+	SelectResultSizeSmall
+	SelectResultSizeBig
+)
+
+// OptimizerHint is a hint in the comment like /*+ MAX_EXECUTION_TIME(1000) */ following the first keyword of
+// a SELECT, INSERT, UPDATE or DELETE statement.
+type OptimizerHint struct {
+	Name model.CIStr
+	Args []string
+}
+
 // WildCardField is a special type of select field content.
 type WildCardField struct {
 	node
@@ -372,6 +395,9 @@ func (n *ByItem) Accept(v Visitor) (Node, bool) {
 type GroupByClause struct {
 	node
 	Items []*ByItem
+	// GroupingSets is not nil for "GROUP BY GROUPING SETS (...)".
+	// Each set is a list of offsets into Items.
+	GroupingSets [][]int
 }
 
 // Accept implements Node Accept interface.
@@ -460,6 +486,10 @@ type SelectStmt struct {
 	Limit *Limit
 	// Lock is the lock type
 	LockTp SelectLockType
+	// ResultSize is the size of the result hinted by SQL_SMALL_RESULT or SQL_BIG_RESULT.
+	ResultSize SelectResultSize
+	// Hints is the optimizer hints of the statement.
+	Hints []*OptimizerHint
 }
 
 // Accept implements Node Accept interface.
@@ -647,6 +677,7 @@ type InsertStmt struct {
 	Priority    int
 	OnDuplicate []*Assignment
 	Select      ResultSetNode
+	Hints       []*OptimizerHint
 }
 
 // Accept implements Node Accept interface.
@@ -721,6 +752,7 @@ type DeleteStmt struct {
 	Quick        bool
 	IsMultiTable bool
 	BeforeFrom   bool
+	Hints        []*OptimizerHint
 }
 
 // Accept implements Node Accept interface.
@@ -780,6 +812,7 @@ type UpdateStmt struct {
 	LowPriority   bool
 	Ignore        bool
 	MultipleTable bool
+	Hints         []*OptimizerHint
 }
 
 // Accept implements Node Accept interface.
@@ -843,6 +876,80 @@ func (n *Limit) Accept(v Visitor) (Node, bool) {
 	return v.Leave(n)
 }
 
+// HandlerStmtType is the type for HANDLER statement.
+type HandlerStmtType int
+
+// HANDLER statement types.
+const (
+	HandlerOpen HandlerStmtType = iota + 1
+	HandlerRead
+	HandlerClose
+)
+
+// HandlerReadType is the row where HANDLER READ starts to read.
+type HandlerReadType int
+
+// HANDLER READ types. HandlerReadKey starts from the key compared with the values by the operator.
+const (
+	HandlerReadFirst HandlerReadType = iota + 1
+	HandlerReadNext
+	HandlerReadPrev
+	HandlerReadLast
+	HandlerReadKey
+)
+
+// HandlerStmt is a statement to read the rows of a table in the order of an index, like a cursor.
+// See https://dev.mysql.com/doc/refman/5.7/en/handler.html
+type HandlerStmt struct {
+	dmlNode
+
+	Tp        HandlerStmtType
+	TableRefs *TableRefsClause
+	// IndexName is empty if the rows are read in the order of the table.
+	IndexName model.CIStr
+	ReadTp    HandlerReadType
+	Op        opcode.Op
+	Values    []ExprNode
+	Where     ExprNode
+	Limit     *Limit
+}
+
+// Accept implements Node Accept interface.
+func (n *HandlerStmt) Accept(v Visitor) (Node, bool) {
+	newNode, skipChildren := v.Enter(n)
+	if skipChildren {
+		return v.Leave(newNode)
+	}
+	n = newNode.(*HandlerStmt)
+	node, ok := n.TableRefs.Accept(v)
+	if !ok {
+		return n, false
+	}
+	n.TableRefs = node.(*TableRefsClause)
+	for i, val := range n.Values {
+		node, ok = val.Accept(v)
+		if !ok {
+			return n, false
+		}
+		n.Values[i] = node.(ExprNode)
+	}
+	if n.Where != nil {
+		node, ok = n.Where.Accept(v)
+		if !ok {
+			return n, false
+		}
+		n.Where = node.(ExprNode)
+	}
+	if n.Limit != nil {
+		node, ok = n.Limit.Accept(v)
+		if !ok {
+			return n, false
+		}
+		n.Limit = node.(*Limit)
+	}
+	return v.Leave(n)
+}
+
 // ShowStmtType is the type for SHOW statement.
 type ShowStmtType int
 
@@ -67,6 +67,7 @@ const (
 	RowFunc    = "row"
 	SetVar     = "setvar"
 	GetVar     = "getvar"
+	Ifnull     = "ifnull"
 )
 
 // FuncCallExpr is for function expression.
@@ -181,6 +182,8 @@ const (
 	AggFuncMin = "min"
 	// AggFuncGroupConcat is the name of group_concat function.
 	AggFuncGroupConcat = "group_concat"
+	// AggFuncGrouping is the name of grouping function.
+	AggFuncGrouping = "grouping"
 )
 
 // AggregateFuncExpr represents aggregate function expression.
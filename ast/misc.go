@@ -401,6 +401,7 @@ type AdminStmtType int
 const (
 	AdminShowDDL = iota + 1
 	AdminCheckTable
+	AdminCheckIndex
 )
 
 // AdminStmt is the struct for Admin statement.
@@ -409,6 +410,8 @@ type AdminStmt struct {
 
 	Tp     AdminStmtType
 	Tables []*TableName
+	// Index is the index to check for ADMIN CHECK INDEX.
+	Index string
 }
 
 // Accept implements Node Accpet interface.
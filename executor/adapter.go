@@ -14,6 +14,8 @@
 package executor
 
 import (
+	"time"
+
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/context"
@@ -21,6 +23,7 @@ import (
 	"github.com/pingcap/tidb/infoschema"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/plan"
+	"github.com/pingcap/tidb/sessionctx/variable"
 )
 
 // recordSet wraps an executor, implements ast.RecordSet interface
@@ -28,6 +31,8 @@ type recordSet struct {
 	fields   []*ast.ResultField
 	executor Executor
 	schema   expression.Schema
+	// deadline is the time after which Next returns ErrMaxExecTimeExceeded, zero means no deadline.
+	deadline time.Time
 }
 
 func (a *recordSet) Fields() ([]*ast.ResultField, error) {
@@ -49,6 +54,9 @@ func (a *recordSet) Fields() ([]*ast.ResultField, error) {
 }
 
 func (a *recordSet) Next() (*ast.Row, error) {
+	if err := checkDeadline(a.deadline); err != nil {
+		return nil, errors.Trace(err)
+	}
 	row, err := a.executor.Next()
 	if err != nil || row == nil {
 		return nil, errors.Trace(err)
@@ -81,19 +89,29 @@ func (a *statement) IsDDL() bool {
 }
 
 func (a *statement) Exec(ctx context.Context) (ast.RecordSet, error) {
+	startTime := time.Now()
 	b := newExecutorBuilder(ctx, a.is)
 	e := b.build(a.plan)
 	if b.err != nil {
 		return nil, errors.Trace(b.err)
 	}
 
+	maxExecutionTime := a.plan.MaxExecutionTime()
 	if executorExec, ok := e.(*ExecuteExec); ok {
 		err := executorExec.Build()
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
 		e = executorExec.StmtExec
+		maxExecutionTime = executorExec.MaxExecutionTime
 	}
+	var deadline time.Time
+	if maxExecutionTime > 0 {
+		deadline = startTime.Add(time.Duration(maxExecutionTime) * time.Millisecond)
+	}
+	// The executors that read all the rows of their sources before returning any row check the deadline by the
+	// session variables.
+	variable.GetSessionVars(ctx).StmtDeadline = deadline
 
 	if len(e.Fields()) == 0 && len(e.Schema()) == 0 {
 		// No result fields means no Recordset.
@@ -115,9 +133,11 @@ func (a *statement) Exec(ctx context.Context) (ast.RecordSet, error) {
 			f.ColumnAsName = f.Column.Name
 		}
 	}
-	return &recordSet{
+	rs := &recordSet{
 		executor: e,
 		fields:   fs,
 		schema:   e.Schema(),
-	}, nil
+		deadline: deadline,
+	}
+	return rs, nil
 }
@@ -0,0 +1,45 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/testleak"
+	"github.com/pingcap/tidb/util/types"
+)
+
+var _ = Suite(&testAdapterSuite{})
+
+type testAdapterSuite struct {
+}
+
+func (s *testAdapterSuite) TestRecordSetDeadline(c *C) {
+	defer testleak.AfterTest(c)()
+	newRecordSet := func(deadline time.Time) *recordSet {
+		src := &mockExec{rows: []*Row{{Data: types.MakeDatums()}}}
+		return &recordSet{executor: src, deadline: deadline}
+	}
+	// The rows are returned before the deadline or if there is no deadline.
+	for _, deadline := range []time.Time{{}, time.Now().Add(time.Hour)} {
+		row, err := newRecordSet(deadline).Next()
+		c.Assert(err, IsNil)
+		c.Assert(row, NotNil)
+	}
+	_, err := newRecordSet(time.Now().Add(-time.Second)).Next()
+	c.Assert(ErrMaxExecTimeExceeded.Equal(err), IsTrue)
+	c.Assert(ErrMaxExecTimeExceeded.ToSQLError().Code, Equals, uint16(mysql.ErrQueryTimeout))
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/util/charset"
+	"github.com/pingcap/tidb/util/distinct"
 	"github.com/pingcap/tidb/util/types"
 	"github.com/pingcap/tipb/go-tipb"
 )
@@ -42,6 +43,8 @@ type executorBuilder struct {
 	ctx context.Context
 	is  infoschema.InfoSchema
 	err error
+	// cacheResults maps the share id of the cache plans to the result shared by their executors.
+	cacheResults map[int]*cacheResult
 }
 
 func newExecutorBuilder(ctx context.Context, is infoschema.InfoSchema) *executorBuilder {
@@ -130,12 +133,24 @@ func (b *executorBuilder) build(p plan.Plan) Executor {
 		return b.buildNewTableDual(v)
 	case *plan.PhysicalApply:
 		return b.buildApply(v)
+	case *plan.PhysicalCache:
+		return b.buildCache(v)
 	case *plan.Exists:
 		return b.buildExists(v)
 	case *plan.MaxOneRow:
 		return b.buildMaxOneRow(v)
+	case *plan.HandlerRead:
+		return b.buildHandlerRead(v)
 	case *plan.Trim:
 		return b.buildTrim(v)
+	case *plan.Expand:
+		return b.buildExpand(v)
+	case *plan.Do:
+		return b.buildDo(v)
+	case *plan.Set:
+		return b.buildSet(v)
+	case *plan.NewUpdate:
+		return b.buildNewUpdate(v)
 	default:
 		b.err = ErrUnknownPlan.Gen("Unknown Plan %T", p)
 		return nil
@@ -220,8 +235,9 @@ func (b *executorBuilder) buildShowDDL(v *plan.ShowDDL) Executor {
 
 func (b *executorBuilder) buildCheckTable(v *plan.CheckTable) Executor {
 	return &CheckTableExec{
-		tables: v.Tables,
-		ctx:    b.ctx,
+		tables:  v.Tables,
+		indices: v.Indices,
+		ctx:     b.ctx,
 	}
 }
 
@@ -519,7 +535,11 @@ func (b *executorBuilder) buildUnion(v *plan.Union) Executor {
 }
 
 func (b *executorBuilder) buildDistinct(v *plan.Distinct) Executor {
-	return &DistinctExec{Src: b.build(v.GetChildByIndex(0)), schema: v.GetSchema()}
+	e := &DistinctExec{Src: b.build(v.GetChildByIndex(0)), schema: v.GetSchema()}
+	if v.Streamed {
+		e.checker = distinct.CreateSortedDistinctChecker()
+	}
+	return e
 }
 
 func (b *executorBuilder) buildPrepare(v *plan.Prepare) Executor {
@@ -622,6 +642,7 @@ func (b *executorBuilder) buildInsert(v *plan.Insert) Executor {
 	}
 	// fields is used to evaluate values expr.
 	insert.fields = ts.GetResultFields()
+	insert.resolveValuesExprs()
 	return insert
 }
 
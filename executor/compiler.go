@@ -33,6 +33,10 @@ type Compiler struct {
 // If it is not supported, the node will be converted to old statement.
 func (c *Compiler) Compile(ctx context.Context, node ast.StmtNode) (ast.Statement, error) {
 	ast.SetFlag(node)
+	// SHOW WARNINGS returns the warnings of the last statement, so it doesn't clear them.
+	if show, ok := node.(*ast.ShowStmt); !ok || show.Tp != ast.ShowWarnings {
+		variable.GetSessionVars(ctx).ClearWarnings()
+	}
 	if _, ok := node.(*ast.UpdateStmt); ok {
 		sVars := variable.GetSessionVars(ctx)
 		sVars.InUpdateStmt = true
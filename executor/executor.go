@@ -15,6 +15,7 @@ package executor
 
 import (
 	"sort"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
@@ -25,10 +26,11 @@ import (
 	"github.com/pingcap/tidb/inspectkv"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/sessionctx"
-	"github.com/pingcap/tidb/sessionctx/db"
 	"github.com/pingcap/tidb/sessionctx/forupdate"
+	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/codec"
@@ -53,24 +55,34 @@ var (
 
 // Error instances.
 var (
-	ErrUnknownPlan     = terror.ClassExecutor.New(CodeUnknownPlan, "Unknown plan")
-	ErrPrepareMulti    = terror.ClassExecutor.New(CodePrepareMulti, "Can not prepare multiple statements")
-	ErrStmtNotFound    = terror.ClassExecutor.New(CodeStmtNotFound, "Prepared statement not found")
-	ErrSchemaChanged   = terror.ClassExecutor.New(CodeSchemaChanged, "Schema has changed")
-	ErrWrongParamCount = terror.ClassExecutor.New(CodeWrongParamCount, "Wrong parameter count")
-	ErrRowKeyCount     = terror.ClassExecutor.New(CodeRowKeyCount, "Wrong row key entry count")
+	ErrUnknownPlan         = terror.ClassExecutor.New(CodeUnknownPlan, "Unknown plan")
+	ErrPrepareMulti        = terror.ClassExecutor.New(CodePrepareMulti, "Can not prepare multiple statements")
+	ErrStmtNotFound        = terror.ClassExecutor.New(CodeStmtNotFound, "Prepared statement not found")
+	ErrSchemaChanged       = terror.ClassExecutor.New(CodeSchemaChanged, "Schema has changed")
+	ErrWrongParamCount     = terror.ClassExecutor.New(CodeWrongParamCount, "Wrong parameter count")
+	ErrRowKeyCount         = terror.ClassExecutor.New(CodeRowKeyCount, "Wrong row key entry count")
+	ErrMaxExecTimeExceeded = terror.ClassExecutor.New(CodeMaxExecTimeExceeded,
+		"Query execution was interrupted, maximum statement execution time exceeded")
 )
 
 // Error codes.
 const (
-	CodeUnknownPlan     terror.ErrCode = 1
-	CodePrepareMulti    terror.ErrCode = 2
-	CodeStmtNotFound    terror.ErrCode = 3
-	CodeSchemaChanged   terror.ErrCode = 4
-	CodeWrongParamCount terror.ErrCode = 5
-	CodeRowKeyCount     terror.ErrCode = 6
+	CodeUnknownPlan         terror.ErrCode = 1
+	CodePrepareMulti        terror.ErrCode = 2
+	CodeStmtNotFound        terror.ErrCode = 3
+	CodeSchemaChanged       terror.ErrCode = 4
+	CodeWrongParamCount     terror.ErrCode = 5
+	CodeRowKeyCount         terror.ErrCode = 6
+	CodeMaxExecTimeExceeded terror.ErrCode = 7
 )
 
+func init() {
+	mySQLErrCodes := map[terror.ErrCode]uint16{
+		CodeMaxExecTimeExceeded: mysql.ErrQueryTimeout,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassExecutor] = mySQLErrCodes
+}
+
 // Row represents a record row.
 type Row struct {
 	// Data is the output record data for current Plan.
@@ -98,6 +110,28 @@ type Executor interface {
 	Schema() expression.Schema
 }
 
+// checkDeadline returns ErrMaxExecTimeExceeded if the deadline has passed, zero means no deadline.
+func checkDeadline(deadline time.Time) error {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return ErrMaxExecTimeExceeded.Gen("Query execution was interrupted, maximum statement execution time exceeded")
+	}
+	return nil
+}
+
+// checkStmtDeadline checks the deadline of the current statement. It's called for every source row by the
+// executors that read all the rows of their sources before returning the first row, like sort and aggregation,
+// otherwise they couldn't be interrupted until they return.
+func checkStmtDeadline(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	sessionVars := variable.GetSessionVars(ctx)
+	if sessionVars == nil {
+		return nil
+	}
+	return checkDeadline(sessionVars.StmtDeadline)
+}
+
 // ShowDDLExec represents a show DDL executor.
 type ShowDDLExec struct {
 	fields []*ast.ResultField
@@ -175,9 +209,10 @@ func (e *ShowDDLExec) Close() error {
 
 // CheckTableExec represents a check table executor.
 type CheckTableExec struct {
-	tables []*ast.TableName
-	ctx    context.Context
-	done   bool
+	tables  []*ast.TableName
+	indices [][]*model.IndexInfo
+	ctx     context.Context
+	done    bool
 }
 
 // Schema implements Executor Schema interface.
@@ -196,15 +231,17 @@ func (e *CheckTableExec) Next() (*Row, error) {
 		return nil, nil
 	}
 
-	dbName := model.NewCIStr(db.GetCurrentSchema(e.ctx))
 	is := sessionctx.GetDomain(e.ctx).InfoSchema()
 
-	for _, t := range e.tables {
-		tb, err := is.TableByName(dbName, t.Name)
+	for i, t := range e.tables {
+		tb, err := is.TableByName(t.Schema, t.Name)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
 		for _, idx := range tb.Indices() {
+			if !containsIndex(e.indices[i], idx.Meta()) {
+				continue
+			}
 			txn, err := e.ctx.GetTxn(false)
 			if err != nil {
 				return nil, errors.Trace(err)
@@ -220,6 +257,15 @@ func (e *CheckTableExec) Next() (*Row, error) {
 	return nil, nil
 }
 
+func containsIndex(indices []*model.IndexInfo, index *model.IndexInfo) bool {
+	for _, idx := range indices {
+		if idx.ID == index.ID {
+			return true
+		}
+	}
+	return false
+}
+
 // Close implements plan.Plan Close interface.
 func (e *CheckTableExec) Close() error {
 	return nil
@@ -1022,6 +1068,9 @@ func (e *SortExec) Next() (*Row, error) {
 			totalCount = offset + int(e.Limit.Count)
 		}
 		for {
+			if err := checkStmtDeadline(e.ctx); err != nil {
+				return nil, errors.Trace(err)
+			}
 			srcRow, err := e.Src.Next()
 			if err != nil {
 				return nil, errors.Trace(err)
@@ -1110,6 +1159,9 @@ func (e *AggregateExec) Next() (*Row, error) {
 		e.groupMap = make(map[string]bool)
 		e.groups = [][]byte{}
 		for {
+			if err := checkStmtDeadline(e.ctx); err != nil {
+				return nil, errors.Trace(err)
+			}
 			hasMore, err := e.innerNext()
 			if err != nil {
 				return nil, errors.Trace(err)
@@ -28,6 +28,7 @@ import (
 	"github.com/pingcap/tidb/meta"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/plan/statistics"
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/sessionctx/db"
@@ -87,6 +88,8 @@ func (e *SimpleExec) Next() (*Row, error) {
 	case *ast.BinlogStmt:
 		// We just ignore it.
 		return nil, nil
+	case *ast.HandlerStmt:
+		err = e.executeHandler(x)
 	}
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -123,87 +126,92 @@ func (e *SimpleExec) executeUse(s *ast.UseStmt) error {
 }
 
 func (e *SimpleExec) executeSet(s *ast.SetStmt) error {
+	for _, v := range s.Variables {
+		if err := e.executeSetVar(v, nil); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// executeSetVar sets the variable of the assignment to value, which is evaluated from the assignment if it's nil.
+func (e *SimpleExec) executeSetVar(v *ast.VariableAssignment, value *types.Datum) error {
 	sessionVars := variable.GetSessionVars(e.ctx)
 	globalVars := variable.GetGlobalVarAccessor(e.ctx)
-	for _, v := range s.Variables {
-		// Variable is case insensitive, we use lower case.
-		if v.Name == ast.SetNames {
-			// This is set charset stmt.
-			cs := v.Value.GetValue().(string)
-			var co string
-			if v.ExtendValue != nil {
-				co = v.ExtendValue.GetValue().(string)
-			}
-			err := e.setCharset(cs, co)
-			if err != nil {
-				return errors.Trace(err)
-			}
-			continue
+	// Variable is case insensitive, we use lower case.
+	if v.Name == ast.SetNames {
+		// This is set charset stmt.
+		cs := v.Value.GetValue().(string)
+		var co string
+		if v.ExtendValue != nil {
+			co = v.ExtendValue.GetValue().(string)
 		}
-		name := strings.ToLower(v.Name)
-		if !v.IsSystem {
-			// Set user variable.
-			value, err := evaluator.Eval(e.ctx, v.Value)
+		return errors.Trace(e.setCharset(cs, co))
+	}
+	name := strings.ToLower(v.Name)
+	if !v.IsSystem {
+		// Set user variable.
+		if value == nil {
+			d, err := evaluator.Eval(e.ctx, v.Value)
 			if err != nil {
 				return errors.Trace(err)
 			}
+			value = &d
+		}
 
-			if value.IsNull() {
-				delete(sessionVars.Users, name)
-			} else {
-				svalue, err1 := value.ToString()
-				if err1 != nil {
-					return errors.Trace(err1)
-				}
-				sessionVars.Users[name] = fmt.Sprintf("%v", svalue)
+		if value.IsNull() {
+			delete(sessionVars.Users, name)
+		} else {
+			svalue, err1 := value.ToString()
+			if err1 != nil {
+				return errors.Trace(err1)
 			}
-			continue
+			sessionVars.Users[name] = fmt.Sprintf("%v", svalue)
 		}
+		return nil
+	}
 
-		// Set system variable
-		sysVar := variable.GetSysVar(name)
-		if sysVar == nil {
-			return variable.UnknownSystemVar.Gen("Unknown system variable '%s'", name)
-		}
-		if sysVar.Scope == variable.ScopeNone {
-			return errors.Errorf("Variable '%s' is a read only variable", name)
+	// Set system variable
+	sysVar := variable.GetSysVar(name)
+	if sysVar == nil {
+		return variable.UnknownSystemVar.Gen("Unknown system variable '%s'", name)
+	}
+	if sysVar.Scope == variable.ScopeNone {
+		return errors.Errorf("Variable '%s' is a read only variable", name)
+	}
+	if v.IsGlobal {
+		// Set global scope system variable.
+		if sysVar.Scope&variable.ScopeGlobal == 0 {
+			return errors.Errorf("Variable '%s' is a SESSION variable and can't be used with SET GLOBAL", name)
 		}
-		if v.IsGlobal {
-			// Set global scope system variable.
-			if sysVar.Scope&variable.ScopeGlobal == 0 {
-				return errors.Errorf("Variable '%s' is a SESSION variable and can't be used with SET GLOBAL", name)
-			}
-			value, err := e.getVarValue(v, sysVar, nil)
-			if err != nil {
-				return errors.Trace(err)
-			}
-			if value.IsNull() {
-				value.SetString("")
-			}
-			svalue, err := value.ToString()
-			if err != nil {
-				return errors.Trace(err)
-			}
-			err = globalVars.SetGlobalSysVar(e.ctx, name, svalue)
-			if err != nil {
-				return errors.Trace(err)
-			}
-		} else {
-			// Set session scope system variable.
-			if sysVar.Scope&variable.ScopeSession == 0 {
-				return errors.Errorf("Variable '%s' is a GLOBAL variable and should be set with SET GLOBAL", name)
-			}
-			value, err := e.getVarValue(v, nil, globalVars)
-			if err != nil {
-				return errors.Trace(err)
-			}
-			err = sessionVars.SetSystemVar(name, value)
+		if value == nil {
+			d, err := e.getVarValue(v, sysVar, nil)
 			if err != nil {
 				return errors.Trace(err)
 			}
+			value = &d
 		}
+		if value.IsNull() {
+			value.SetString("")
+		}
+		svalue, err := value.ToString()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(globalVars.SetGlobalSysVar(e.ctx, name, svalue))
 	}
-	return nil
+	// Set session scope system variable.
+	if sysVar.Scope&variable.ScopeSession == 0 {
+		return errors.Errorf("Variable '%s' is a GLOBAL variable and should be set with SET GLOBAL", name)
+	}
+	if value == nil {
+		d, err := e.getVarValue(v, nil, globalVars)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		value = &d
+	}
+	return errors.Trace(sessionVars.SetSystemVar(name, *value))
 }
 
 func (e *SimpleExec) getVarValue(v *ast.VariableAssignment, sysVar *variable.SysVar, globalVars variable.GlobalVarAccessor) (value types.Datum, err error) {
@@ -348,6 +356,22 @@ func (e *SimpleExec) executeSetPwd(s *ast.SetPwdStmt) error {
 	return errors.Trace(err)
 }
 
+// executeHandler opens or closes the handler of the table, which is checked when the statement is planned.
+// Opening an opened handler reads it from the start again.
+func (e *SimpleExec) executeHandler(s *ast.HandlerStmt) error {
+	vars := variable.GetSessionVars(e.ctx)
+	name := plan.HandlerName(s)
+	if s.Tp == ast.HandlerOpen {
+		vars.Handlers[name] = &variable.Handler{}
+		return nil
+	}
+	if _, ok := vars.Handlers[name]; !ok {
+		return plan.ErrUnknownTable.Gen("Unknown table '%s' in HANDLER", name)
+	}
+	delete(vars.Handlers, name)
+	return nil
+}
+
 func (e *SimpleExec) executeAnalyzeTable(s *ast.AnalyzeTableStmt) error {
 	for _, table := range s.TableNames {
 		err := e.createStatisticsForTable(table)
@@ -370,7 +394,8 @@ func (e *SimpleExec) createStatisticsForTable(tn *ast.TableName) error {
 	} else {
 		tableName = tn.Schema.L + "." + tn.Name.L
 	}
-	sql := "select * from " + tableName
+	// The table is scanned explicitly, so the scan isn't forbidden by tidb_full_scan_row_limit.
+	sql := "select * from " + tableName + " use index ()"
 	result, err := e.ctx.(sqlexec.RestrictedSQLExecutor).ExecRestrictedSQL(e.ctx, sql)
 	if err != nil {
 		return errors.Trace(err)
@@ -21,6 +21,7 @@ import (
 	"github.com/pingcap/tidb/meta"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/plan/statistics"
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/sessionctx/variable"
@@ -122,6 +123,40 @@ func (s *testSuite) TestSetVar(c *C) {
 	tk.MustQuery(`select @@session.low_priority_updates;`).Check(testkit.Rows("ON"))
 }
 
+func (s *testSuite) TestSetSubquery(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (c int)")
+	tk.MustExec("insert t values (1), (2)")
+	tk.MustExec("set @a = (select count(*) from t)")
+	tk.MustQuery("select @a").Check(testkit.Rows("2"))
+	tk.MustExec("set @a = 0, @b = (select max(c) + 1 from t), @@autocommit = (select min(c) from t), names utf8")
+	tk.MustQuery("select @a, @b, @@autocommit").Check(testkit.Rows("0 3 1"))
+	tk.MustExec("set @a = (select c from t where c > 2)")
+	tk.MustQuery("select @a").Check(testkit.Rows("<nil>"))
+	// The variables are set from left to right, so the later values read the new values of the earlier ones.
+	tk.MustExec("set @a = 1, @b = (select @a)")
+	tk.MustQuery("select @a, @b").Check(testkit.Rows("1 1"))
+	tk.MustExec("set @a = 2, @b = @a + (select 1)")
+	tk.MustQuery("select @a, @b").Check(testkit.Rows("2 3"))
+	tk.MustExec("set @c = 5, @d = (select count(*) from t where c < @c), @c = (select @d + @c)")
+	tk.MustQuery("select @c, @d").Check(testkit.Rows("7 2"))
+	// The variable that isn't set before the statement is read after it's set too.
+	tk.MustExec("set @e = 3, @f = (select @e)")
+	tk.MustQuery("select @e, @f").Check(testkit.Rows("3 3"))
+	// The subquery must return at most one row.
+	_, err := tk.Exec("set @a = (select c from t)")
+	c.Assert(err, NotNil)
+	// The subqueries are checked like the ones of the other statements.
+	tk.MustExec("set tidb_full_scan_row_limit = 100")
+	_, err = tk.Exec("set @a = 1, @b = (select max(c) from t)")
+	c.Assert(plan.ErrFullScanForbidden.Equal(err), IsTrue)
+	// It's rejected when it's planned, so no variable is set.
+	tk.MustQuery("select @a").Check(testkit.Rows("2"))
+}
+
 func (s *testSuite) TestSetCharset(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
@@ -146,6 +181,15 @@ func (s *testSuite) TestDo(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
 	tk.MustExec("do 1, 2")
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (c int)")
+	tk.MustExec("insert t values (1), (2)")
+	rs, err := tk.Exec("do (select count(*) from t), exists (select * from t where c > 1)")
+	c.Assert(err, IsNil)
+	c.Assert(rs, IsNil)
+	tk.MustExec("do @a := (select max(c) from t)")
+	tk.MustQuery("select @a").Check(testkit.Rows("2"))
 }
 
 func (s *testSuite) TestTransaction(c *C) {
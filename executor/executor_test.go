@@ -23,14 +23,19 @@ import (
 	"github.com/ngaut/log"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/domain"
 	"github.com/pingcap/tidb/executor"
 	"github.com/pingcap/tidb/inspectkv"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/plan"
+	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/store/tikv"
+	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/testkit"
 	"github.com/pingcap/tidb/util/testleak"
 	"github.com/pingcap/tidb/util/types"
@@ -127,6 +132,14 @@ func (s *testSuite) TestAdmin(c *C) {
 	c.Assert(err, IsNil)
 	r, err = tk.Exec("admin check table admin_test")
 	c.Assert(err, NotNil)
+	r, err = tk.Exec("admin check index admin_test c1")
+	c.Assert(err, NotNil)
+	// only the named index is checked
+	tk.MustExec("alter table admin_test add index c2 (c2)")
+	tk.MustExec("admin check index admin_test c2")
+	r, err = tk.Exec("admin check index admin_test c3")
+	c.Assert(err, NotNil)
+	tk.MustExec("admin check index admin_test1 c1")
 }
 
 func (s *testSuite) TestPrepared(c *C) {
@@ -160,6 +173,39 @@ func (s *testSuite) TestPrepared(c *C) {
 	result := tk.MustQuery("select distinct c1, c2 from prepare_test where c1 = ?", 1)
 	result.Check([][]interface{}{{1, nil}})
 
+	// The system variable is read when the prepared statement is executed.
+	tk.MustExec("set @@auto_increment_increment = 2")
+	tk.MustExec(`prepare stmt_test_6 from 'select id from prepare_test where id = @@auto_increment_increment'`)
+	tk.MustQuery("execute stmt_test_6").Check(testkit.Rows("2"))
+	tk.MustExec("set @@auto_increment_increment = 1")
+	tk.MustQuery("execute stmt_test_6").Check(testkit.Rows("1"))
+	// The filter parameter is read when the prepared statement is executed.
+	tk.MustExec(`prepare stmt_test_7 from 'select id from prepare_test where id < 10 and c1 = ?'`)
+	tk.MustQuery("set @a = 1; execute stmt_test_7 using @a").Check(testkit.Rows("1"))
+	tk.MustQuery("set @a = 2; execute stmt_test_7 using @a").Check(testkit.Rows("2"))
+	// The plan of the execution is cacheable if it doesn't depend on the parameters and the system variables.
+	ctx := tk.Se.(context.Context)
+	tk.MustExec(`prepare stmt_test_8 from 'select id from prepare_test where c1 = @a'`)
+	cases := []struct {
+		name      string
+		usingVars []ast.ExprNode
+		cacheable bool
+	}{
+		{"stmt_test_6", nil, false},
+		{"stmt_test_7", []ast.ExprNode{ast.NewValueExpr(1)}, false},
+		{"stmt_test_8", nil, true},
+	}
+	for _, ca := range cases {
+		exec := &executor.ExecuteExec{
+			IS:        sessionctx.GetDomain(ctx).InfoSchema(),
+			Ctx:       ctx,
+			Name:      ca.name,
+			UsingVars: ca.usingVars,
+		}
+		c.Assert(exec.Build(), IsNil)
+		c.Assert(exec.Cacheable, Equals, ca.cacheable, Commentf("for %s", ca.name))
+	}
+
 	// Call Session PrepareStmt directly to get stmtId.
 	stmtId, _, _, err := tk.Se.PrepareStmt("select c1, c2 from prepare_test where c1 = ?")
 	c.Assert(err, IsNil)
@@ -371,6 +417,27 @@ func (s *testSuite) TestInsert(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (s *testSuite) TestInsertOnDuplicateKeyUpdate(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists odku_test")
+	tk.MustExec("create table odku_test (a int primary key, b int, c int default 7)")
+	tk.MustExec("insert into odku_test values (1, 1, 1), (2, 2, 2)")
+
+	// VALUES(col) refers to the value of col in each conflicting row, whatever the order of the insert columns.
+	tk.MustExec("insert into odku_test (c, a, b) values (10, 1, 11), (20, 2, 22), (30, 3, 33) on duplicate key update b = values(b) + values(c), c = values(a)")
+	tk.MustQuery("select * from odku_test").Check(testkit.Rows("1 21 1", "2 42 2", "3 33 30"))
+
+	// VALUES(col) of a column omitted in the insert column list is its default value.
+	tk.MustExec("insert into odku_test (a, b) values (1, 5) on duplicate key update b = values(c)")
+	tk.MustQuery("select * from odku_test where a = 1").Check(testkit.Rows("1 7 1"))
+
+	// The column names refer to the duplicate row.
+	tk.MustExec("insert into odku_test (a) values (2), (3) on duplicate key update b = b + 1, c = values(b)")
+	tk.MustQuery("select * from odku_test where a > 1").Check(testkit.Rows("2 43 <nil>", "3 34 <nil>"))
+}
+
 func (s *testSuite) TestInsertAutoInc(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
@@ -715,6 +782,23 @@ func (s *testSuite) TestSelectOrderBy(c *C) {
 	r.Check(testkit.Rows("0", "-1", "-2"))
 	r = tk.MustQuery("select t.d from t order by d;")
 	r.Check(testkit.Rows("1", "2", "3"))
+	r = tk.MustQuery("select c, d from t order by 2 desc;")
+	r.Check(testkit.Rows("1 3", "1 2", "1 1"))
+	_, err := tk.Exec("select c, d from t order by 3")
+	c.Assert(plan.ErrUnknownColumn.Equal(err), IsTrue)
+	c.Assert(err.Error(), Equals, "[optimizer:9]Unknown column '3' in 'order clause'")
+	_, err = tk.Exec("select c, d from t order by 0")
+	c.Assert(plan.ErrUnknownColumn.Equal(err), IsTrue)
+	c.Assert(plan.ErrUnknownColumn.ToSQLError().Code, Equals, uint16(mysql.ErrBadField))
+	// The order by column d is added as an auxiliary field, which a position can't refer to.
+	_, err = tk.Exec("select c from t order by d, 2")
+	c.Assert(plan.ErrUnknownColumn.Equal(err), IsTrue)
+	_, err = tk.Exec("select d, count(*) from t group by d having count(*) > 0 order by 3")
+	c.Assert(err.Error(), Equals, "[optimizer:9]Unknown column '3' in 'order clause'")
+	r = tk.MustQuery("select d, count(*) from t group by d having count(*) > 0 order by 2, 1 desc")
+	r.Check(testkit.Rows("3 1", "2 1", "1 1"))
+	_, err = tk.Exec("select c from t group by 0")
+	c.Assert(err.Error(), Equals, "[optimizer:9]Unknown column '0' in 'group statement'")
 }
 
 func (s *testSuite) TestSelectDistinct(c *C) {
@@ -816,6 +900,51 @@ func (s *testSuite) TestUpdate(c *C) {
 	tk.MustExec("drop table update_test")
 }
 
+func (s *testSuite) TestUpdateSubquery(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t1, t2")
+	tk.MustExec("create table t1 (id int primary key, c int, d int)")
+	tk.MustExec("create table t2 (id int, v int)")
+	tk.MustExec("insert t1 values (1, 0, 0), (2, 0, 0), (3, 0, 0)")
+	tk.MustExec("insert t2 values (1, 10), (1, 20), (2, 5)")
+
+	// The rows without a match are set to null.
+	tk.MustExec("update t1 set c = (select max(v) from t2 where t2.id = t1.id)")
+	tk.CheckExecResult(3, 0)
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 20 0", "2 5 0", "3 <nil> 0"))
+	// The values are computed from the old row.
+	tk.MustExec("update t1 set d = c + 1, c = (select count(*) from t2 where t2.id = t1.id) where id > 1")
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 20 0", "2 1 6", "3 0 <nil>"))
+	tk.MustExec("update t1 set d = (select v from t2 where t2.id = t1.id and v > 10)")
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 20 20", "2 1 <nil>", "3 0 <nil>"))
+	_, err := tk.Exec("update t1 set d = (select v from t2 where t2.id = t1.id)")
+	c.Assert(err, NotNil)
+	tk.MustExec("update t1 set d = exists (select * from t2 where t2.id = t1.id)")
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 20 1", "2 1 1", "3 0 0"))
+	tk.MustExec("update t1 set id = id + 10, c = (select max(v) from t2 where t2.id = t1.id) order by id limit 2")
+	tk.MustQuery("select * from t1").Check(testkit.Rows("3 0 0", "11 20 1", "12 5 1"))
+	tk.MustExec("update t1, t2 set t2.v = (select c from t1 as x where x.id = t2.id + 10), t1.d = 9 where t1.id = t2.id + 10")
+	tk.MustQuery("select * from t2").Check(testkit.Rows("1 20", "1 20", "2 5"))
+	tk.MustQuery("select * from t1").Check(testkit.Rows("3 0 0", "11 20 9", "12 5 9"))
+	// The tables are found by their table sources, not by the names of their columns.
+	tk.MustExec("update t1 as a, t2 as b set b.v = (select d from t1 as x where x.id = b.id + 10), a.c = 7 where a.id = b.id + 10")
+	tk.MustQuery("select * from t2").Check(testkit.Rows("1 9", "1 9", "2 9"))
+	tk.MustQuery("select * from t1").Check(testkit.Rows("3 0 0", "11 7 9", "12 7 9"))
+	// The rows of t1 read by the subquery aren't updated.
+	tk.MustExec("update t1 set c = (select max(x.id) from t1 as x where x.id < t1.id)")
+	tk.MustQuery("select * from t1").Check(testkit.Rows("3 <nil> 0", "11 3 9", "12 11 9"))
+	tk.MustExec("drop database if exists update_subquery")
+	tk.MustExec("create database update_subquery")
+	tk.MustExec("create table update_subquery.t1 (id int primary key, c int)")
+	tk.MustExec("insert update_subquery.t1 values (1, 0), (2, 0)")
+	tk.MustExec("update test.t1, update_subquery.t1 set update_subquery.t1.c = (select count(*) from t2 where t2.id = update_subquery.t1.id), test.t1.d = 1 where test.t1.id = update_subquery.t1.id + 10")
+	tk.MustQuery("select * from update_subquery.t1").Check(testkit.Rows("1 2", "2 1"))
+	tk.MustQuery("select * from test.t1").Check(testkit.Rows("3 <nil> 0", "11 3 1", "12 11 1"))
+	tk.MustExec("drop database update_subquery")
+}
+
 func (s *testSuite) fillMultiTableForUpdate(tk *testkit.TestKit) {
 	// Create and fill table items
 	tk.MustExec("CREATE TABLE items (id int, price TEXT);")
@@ -984,6 +1113,12 @@ func (s *testSuite) TestUnion(c *C) {
 	r = tk.MustQuery(`select "abc" union select 1`)
 	r.Check(testkit.Rows("abc", "1"))
 
+	r = tk.MustQuery("select x.a from (select id as a, id + 1 as b from union_test union select 1, 3) x order by x.a")
+	r.Check(testkit.Rows("1", "1", "2"))
+
+	r = tk.MustQuery("select x.a from (select distinct id as a, id + 1 as b from union_test union all select 1, 3) x order by x.a")
+	r.Check(testkit.Rows("1", "1", "2"))
+
 	tk.MustExec("commit")
 }
 
@@ -1091,6 +1226,10 @@ func (s *testSuite) TestJoin(c *C) {
 	result.Check(testkit.Rows())
 	result = tk.MustQuery("select * from t left outer join t1 on t.c1 = t1.c1 and t.c1 != 1")
 	result.Check(testkit.Rows("1 1 <nil> <nil>", "2 2 2 3"))
+	result = tk.MustQuery("select * from t left outer join t1 on t.c1 = t1.c1 and t.c1 = 2")
+	result.Check(testkit.Rows("1 1 <nil> <nil>", "2 2 2 3"))
+	result = tk.MustQuery("select * from t join t1 on t.c1 = t1.c1 and t1.c1 = 2")
+	result.Check(testkit.Rows("2 2 2 3"))
 
 	tk.MustExec("drop table if exists t1")
 	tk.MustExec("drop table if exists t2")
@@ -1122,6 +1261,89 @@ func (s *testSuite) TestJoin(c *C) {
 	result = tk.MustQuery("select * from t a , t1 b where (a.c1, a.c2) = (b.c1, b.c2);")
 	result.Check(testkit.Rows("1 2 1 2"))
 
+	tk.MustExec("drop table if exists t1")
+	tk.MustExec("drop table if exists t2")
+	tk.MustExec("create table t1 (c1 int, c2 int)")
+	tk.MustExec("create table t2 (c1 int, c2 int)")
+	tk.MustExec("insert into t1 values (1, 1), (2, 2), (3, NULL)")
+	tk.MustExec("insert into t2 values (1, 1), (1, 2), (2, 3), (NULL, 4)")
+	result = tk.MustQuery("select * from t1 left join (select c1, count(*) from t2 group by c1) x on t1.c1 = x.c1 order by t1.c1")
+	result.Check(testkit.Rows("1 1 1 2", "2 2 2 1", "3 <nil> <nil> <nil>"))
+	result = tk.MustQuery("select * from t1 left join (select c1, sum(c2) as s from t2 group by c1) x on t1.c1 = x.c1 and x.s > 1 order by t1.c1")
+	result.Check(testkit.Rows("1 1 1 3", "2 2 2 3", "3 <nil> <nil> <nil>"))
+	result = tk.MustQuery("select * from t1 left join t2 on t1.c1 = t2.c1 order by t1.c1, t2.c2")
+	result.Check(testkit.Rows("1 1 1 1", "1 1 1 2", "2 2 2 3", "3 <nil> <nil> <nil>"))
+	result = tk.MustQuery("select count(*) from t1 join t2 on 1 = 1")
+	result.Check(testkit.Rows("12"))
+	result = tk.MustQuery("select t1.c1, t2.c2 from t1 join t2 on t1.c2 = t1.c2 and t2.c1 = 2 order by t1.c1")
+	result.Check(testkit.Rows("1 3", "2 3"))
+	result = tk.MustQuery("select t1.c1, t2.c2 from t1 left join t2 on t1.c2 = t1.c2 and t2.c1 = 2 order by t1.c1")
+	result.Check(testkit.Rows("1 3", "2 3", "3 <nil>"))
+	tk.MustExec("drop table if exists t3")
+	tk.MustExec("create table t3 (c1 int not null)")
+	tk.MustExec("insert into t3 values (1), (2)")
+	result = tk.MustQuery("select count(*) from t3 a join t3 b on a.c1 = a.c1")
+	result.Check(testkit.Rows("4"))
+}
+
+func (s *testSuite) TestJoinUsing(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t1")
+	tk.MustExec("drop table if exists t2")
+	tk.MustExec("create table t1 (a int, b int)")
+	tk.MustExec("create table t2 (c int, a int)")
+	tk.MustExec("insert into t1 values (1, 1), (2, 2)")
+	tk.MustExec("insert into t2 values (3, 2), (4, 3)")
+	cases := []struct {
+		sql    string
+		fields []string
+		result [][]interface{}
+	}{
+		{
+			"select * from t1 join t2 order by t1.a",
+			[]string{"a", "b", "c", "a"},
+			testkit.Rows("1 1 3 2", "1 1 4 3", "2 2 3 2", "2 2 4 3"),
+		},
+		{
+			"select * from t1 join t2 using (a)",
+			[]string{"a", "b", "c"},
+			testkit.Rows("2 2 3"),
+		},
+		{
+			"select * from t1 natural left join t2 order by a",
+			[]string{"a", "b", "c"},
+			testkit.Rows("1 1 <nil>", "2 2 3"),
+		},
+		{
+			"select * from t1 right join t2 using (a) order by a",
+			[]string{"a", "c", "b"},
+			testkit.Rows("2 3 2", "3 4 <nil>"),
+		},
+		{
+			"select t1.*, t2.a from t1 left join t2 using (a) where a > 0 order by t1.a",
+			[]string{"a", "b", "a"},
+			testkit.Rows("1 1 <nil>", "2 2 2"),
+		},
+	}
+	for _, ca := range cases {
+		rs, err := tk.Exec(ca.sql)
+		c.Assert(err, IsNil, Commentf("for %s", ca.sql))
+		fields, err := rs.Fields()
+		c.Assert(err, IsNil)
+		var names []string
+		for _, field := range fields {
+			names = append(names, field.ColumnAsName.O)
+		}
+		c.Assert(names, DeepEquals, ca.fields, Commentf("for %s", ca.sql))
+		rs.Close()
+		tk.MustQuery(ca.sql).Check(ca.result)
+	}
+	// The left side of the last join has two columns a.
+	_, err := tk.Exec("select * from t1 join t2 join t1 as t3 using (a)")
+	c.Assert(plan.ErrAmbiguousColumn.Equal(err), IsTrue)
+	c.Assert(plan.ErrAmbiguousColumn.ToSQLError().Code, Equals, uint16(mysql.ErrNonUniq))
 }
 
 func (s *testSuite) TestMultiJoin(c *C) {
@@ -1234,6 +1456,25 @@ func (s *testSuite) TestIndexScan(c *C) {
 	result.Check(testkit.Rows("<nil>"))
 }
 
+func (s *testSuite) TestCoveringIndexScan(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, b int, c int, d int, index idx (b, c))")
+	tk.MustExec("insert t values (1, 10, 100, 1000), (2, 20, 200, 2000), (3, 30, 300, 3000)")
+	// The handle and the index columns are read in the order of the select fields, not the index columns.
+	result := tk.MustQuery("select c, a, b from t use index (idx) where b > 10")
+	result.Check(testkit.Rows("200 2 20", "300 3 30"))
+	result = tk.MustQuery("select a from t use index (idx) where b = 20")
+	result.Check(testkit.Rows("2"))
+	result = tk.MustQuery("select c from t use index (idx) where b < 30 order by b desc")
+	result.Check(testkit.Rows("200", "100"))
+	// Column d isn't in the index, so the rows are looked up.
+	result = tk.MustQuery("select d, a from t use index (idx) where b >= 20")
+	result.Check(testkit.Rows("2000 2", "3000 3"))
+}
+
 func (s *testSuite) TestSubquerySameTable(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
@@ -1245,6 +1486,23 @@ func (s *testSuite) TestSubquerySameTable(c *C) {
 	result.Check(testkit.Rows("2"))
 	result = tk.MustQuery("select a from t where not exists(select 1 from t as x where x.a < t.a)")
 	result.Check(testkit.Rows("1"))
+
+	// The inequality correlated exists is built as an apply, which must agree with the nested count form.
+	tk.MustExec("drop table if exists o, s")
+	tk.MustExec("create table o (a int, t int)")
+	tk.MustExec("create table s (b int, t int)")
+	tk.MustExec("insert o values (1, 1), (2, 5), (3, 9), (4, null)")
+	tk.MustExec("insert s values (1, 3), (2, 6), (3, null)")
+	result = tk.MustQuery("select a from o where exists(select 1 from s where s.t > o.t and s.b <= o.a)")
+	result.Check(testkit.Rows("1", "2"))
+	result = tk.MustQuery("select a from o where (select count(*) from s where s.t > o.t and s.b <= o.a) > 0")
+	result.Check(testkit.Rows("1", "2"))
+	result = tk.MustQuery("select a from o where not exists(select 1 from s where s.t > o.t)")
+	result.Check(testkit.Rows("3", "4"))
+	result = tk.MustQuery("select a from o where (select count(*) from s where s.t > o.t) = 0")
+	result.Check(testkit.Rows("3", "4"))
+	result = tk.MustQuery("select a, exists(select 1 from s where s.t > o.t) from o")
+	result.Check(testkit.Rows("1 1", "2 1", "3 0", "4 0"))
 }
 
 func (s *testSuite) TestIndexReverseOrder(c *C) {
@@ -1307,6 +1565,80 @@ func (s *testSuite) TestInSubquery(c *C) {
 	tk.MustExec("create table t1 (a float)")
 	tk.MustExec("insert t1 values (281.37)")
 	tk.MustQuery("select a from t1 where (a in (select a from t1))").Check(testkit.Rows("281.37"))
+
+	// The identical subqueries share the materialized result.
+	tk.MustExec("drop table if exists t2")
+	tk.MustExec("create table t2 (a int, b int)")
+	tk.MustExec("insert t2 values (1, 1), (2, 1), (3, 2), (4, 3)")
+	sq := "(select b from t2 where a > 1 group by b having count(*) > 0)"
+	result = tk.MustQuery("select a from t2 where a in " + sq + " and b in " + sq)
+	result.Check(testkit.Rows("1", "2", "3"))
+	result = tk.MustQuery("select a from t2 where a not in " + sq + " and b in " + sq)
+	result.Check(testkit.Rows("4"))
+	result = tk.MustQuery("select a, a in " + sq + " or b in " + sq + " from t2")
+	result.Check(testkit.Rows("1 1", "2 1", "3 1", "4 1"))
+}
+
+func (s *testSuite) TestSemiJoin(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t1")
+	tk.MustExec("drop table if exists t2")
+	tk.MustExec("create table t1 (a int, b int)")
+	tk.MustExec("create table t2 (a int, b int)")
+	tk.MustExec("insert t1 values (1, 1), (2, 2), (null, 3)")
+	tk.MustExec("insert t2 values (1, 1), (null, 2)")
+	cases := []struct {
+		sql    string
+		result [][]interface{}
+	}{
+		// EXISTS isn't null aware, a null key doesn't match any row.
+		{
+			"select b from t1 where exists (select * from t2 where t2.a = t1.a)",
+			testkit.Rows("1"),
+		},
+		{
+			"select b from t1 where not exists (select * from t2 where t2.a = t1.a)",
+			testkit.Rows("2", "3"),
+		},
+		{
+			"select b from t1 where exists (select distinct b from t2 where t2.a = t1.a)",
+			testkit.Rows("1"),
+		},
+		{
+			"select b, exists (select * from t2 where t2.a = t1.a) from t1",
+			testkit.Rows("1 1", "2 0", "3 0"),
+		},
+		{
+			"select b, not exists (select * from t2 where t2.a = t1.a) from t1",
+			testkit.Rows("1 0", "2 1", "3 1"),
+		},
+		// IN is unknown if a key is null, unless the subquery is empty.
+		{
+			"select b from t1 where a not in (select a from t2)",
+			testkit.Rows(),
+		},
+		{
+			"select b from t1 where a not in (select a from t2 where a is not null)",
+			testkit.Rows("2"),
+		},
+		{
+			"select b, a in (select a from t2) from t1",
+			testkit.Rows("1 1", "2 <nil>", "3 <nil>"),
+		},
+		{
+			"select b, a not in (select a from t2 where a is not null) from t1",
+			testkit.Rows("1 0", "2 1", "3 <nil>"),
+		},
+		{
+			"select b, a in (select a from t2 where a > 1), a not in (select a from t2 where a > 1) from t1",
+			testkit.Rows("1 0 1", "2 0 1", "3 0 1"),
+		},
+	}
+	for _, ca := range cases {
+		tk.MustQuery(ca.sql).Check(ca.result)
+	}
 }
 
 func (s *testSuite) TestDefaultNull(c *C) {
@@ -1615,6 +1947,10 @@ func (s *testSuite) TestNewSubquery(c *C) {
 	result.Check(testkit.Rows("1 1", "2 2"))
 	result = tk.MustQuery("select 1 = (select count(*) from t where t.c = k.d) from t k")
 	result.Check(testkit.Rows("1", "1", "0"))
+	result = tk.MustQuery("select (select count(*) from (select d, count(*) as c from t group by d) x where x.c >= k.c) from t k")
+	result.Check(testkit.Rows("3", "0", "0"))
+	result = tk.MustQuery("select (select count(*) from t, (select d, max(c) as m from t group by d) x where t.d = x.m and t.c > k.c) from t k")
+	result.Check(testkit.Rows("1", "0", "0"))
 	result = tk.MustQuery("select 1 = (select count(*) from t where exists( select * from t m where t.c = k.d)) from t k")
 	result.Check(testkit.Rows("1", "1", "0"))
 	result = tk.MustQuery("select t.c = any (select count(*) from t) from t")
@@ -1629,6 +1965,25 @@ func (s *testSuite) TestNewSubquery(c *C) {
 	result.Check(testkit.Rows())
 	result = tk.MustQuery("select (select count(*) from t where t.c = k.d) from t k")
 	result.Check(testkit.Rows("1", "1", "0"))
+	result = tk.MustQuery("select k.c, (select count(d) from t where t.c = k.d), (select max(d) from t where t.c = k.d), (select min(d) from t where t.c = k.d) from t k")
+	result.Check(testkit.Rows("1 1 1 1", "2 1 2 2", "3 0 <nil> <nil>"))
+	result = tk.MustQuery("select k.c, (select sum(d) from t where t.c = k.d), (select avg(d) from t where t.c = k.d and t.d > 1) from t k")
+	result.Check(testkit.Rows("1 1 <nil>", "2 2 2.0000", "3 <nil> <nil>"))
+	result = tk.MustQuery("select k.c from t k where (select count(*) from t where t.c = k.d) = 0")
+	result.Check(testkit.Rows("3"))
+	// The filter on the outer side is evaluated below the apply.
+	result = tk.MustQuery("select k.c from t k where exists(select * from t where t.c < k.c) and k.d > 1")
+	result.Check(testkit.Rows("2", "3"))
+	result = tk.MustQuery("select k.c from t k where k.c >= (select count(*) from t where t.d < k.d) and k.d < 4")
+	result.Check(testkit.Rows("1", "2"))
+	result = tk.MustQuery("select k.c, (select count(*) + 1 from t where t.c = k.d) from t k")
+	result.Check(testkit.Rows("1 2", "2 2", "3 1"))
+	// The subquery correlated by columns of different types isn't decorrelated, the values are compared as numbers.
+	tk.MustExec("drop table if exists t2")
+	tk.MustExec("create table t2 (v varchar(10))")
+	tk.MustExec("insert t2 values ('1.0'), ('01'), ('2.00')")
+	result = tk.MustQuery("select k.c, (select count(*) from t2 where t2.v = k.c) from t k")
+	result.Check(testkit.Rows("1 2", "2 1", "3 0"))
 	result = tk.MustQuery("select t.c from t where (t.c, t.d) in (select * from t)")
 	result.Check(testkit.Rows("1", "2", "3"))
 	result = tk.MustQuery("select t.c from t where (t.c, t.d) not in (select * from t)")
@@ -1801,6 +2156,199 @@ func (s *testSuite) TestAggregation(c *C) {
 	result.Check(testkit.Rows("2"))
 }
 
+func (s *testSuite) TestStreamAggregation(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	// Any group by over columns is streamed.
+	tk.MustExec("set tidb_hash_agg_group_limit = 1")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, c int, d int, index c_d (c, d))")
+	tk.MustExec("insert t values (1, NULL, 1), (2, 1, 1), (3, 1, 2), (4, 1, 3), (5, 1, 1), (6, 3, 2), (7, 4, 3)")
+	result := tk.MustQuery("select d, count(*), sum(c) from t group by d")
+	result.Check(testkit.Rows("1 3 2", "2 2 4", "3 2 5"))
+	result = tk.MustQuery("select c, d, count(*) from t group by c, d")
+	result.Check(testkit.Rows("<nil> 1 1", "1 1 2", "1 2 1", "1 3 1", "3 2 1", "4 3 1"))
+	result = tk.MustQuery("select d, count(distinct c), max(a) from t where a > 1 group by d")
+	result.Check(testkit.Rows("1 1 5", "2 2 6", "3 2 7"))
+	result = tk.MustQuery("select count(*) from t where a > 10 group by d")
+	result.Check(testkit.Rows())
+	result = tk.MustQuery("select count(*) from t")
+	result.Check(testkit.Rows("7"))
+	_, err := tk.Exec("set tidb_hash_agg_group_limit = -1")
+	c.Assert(err, NotNil)
+	tk.MustExec("set tidb_hash_agg_group_limit = 0")
+	result = tk.MustQuery("select sql_big_result d, count(*), sum(c) from t group by d")
+	result.Check(testkit.Rows("1 3 2", "2 2 4", "3 2 5"))
+	result = tk.MustQuery("select distinct sql_big_result d from t")
+	result.Check(testkit.Rows("1", "2", "3"))
+	result = tk.MustQuery("select distinct sql_big_result c, d from t")
+	result.Check(testkit.Rows("<nil> 1", "1 1", "1 2", "1 3", "3 2", "4 3"))
+	result = tk.MustQuery("select distinct sql_small_result d from t order by d")
+	result.Check(testkit.Rows("1", "2", "3"))
+}
+
+func (s *testSuite) TestGroupSkip(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, c int, d int, index c_d (c, d))")
+	tk.MustExec("insert t values (1, NULL, 1), (2, 1, NULL), (3, 1, 2), (4, 1, 3), (5, 3, NULL), (6, 3, 2), (7, 4, NULL), (8, NULL, NULL)")
+	result := tk.MustQuery("select c, min(d) from t group by c")
+	result.Check(testkit.Rows("<nil> 1", "1 2", "3 2", "4 <nil>"))
+	result = tk.MustQuery("select max(d), c from t group by c")
+	result.Check(testkit.Rows("<nil> 4", "2 3", "3 1", "1 <nil>"))
+	result = tk.MustQuery("select c, min(d) from t where a > 10 group by c")
+	result.Check(testkit.Rows())
+	// The written rows aren't read by the seeks, so all the entries are aggregated.
+	tk.MustExec("begin")
+	tk.MustExec("insert t values (9, 1, 1), (10, 5, 4)")
+	result = tk.MustQuery("select c, min(d) from t group by c")
+	result.Check(testkit.Rows("<nil> 1", "1 1", "3 2", "4 <nil>", "5 4"))
+	tk.MustExec("rollback")
+}
+
+func (s *testSuite) TestStreamDistinctCount(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, c int, d int, e int, index c_d (c, d))")
+	tk.MustExec("insert t values (1, NULL, 1, 1), (2, 1, 1, 2), (3, 1, 2, 1), (4, 1, 3, NULL), (5, 1, 1, 2), (6, 3, 2, 1), (7, 4, 3, 3)")
+	// The index c_d sorts the arguments.
+	result := tk.MustQuery("select count(distinct c, d) from t")
+	result.Check(testkit.Rows("5"))
+	result = tk.MustQuery("select count(distinct d, c) from t where c > 1")
+	result.Check(testkit.Rows("2"))
+	result = tk.MustQuery("select count(distinct c, d) from t where a > 10")
+	result.Check(testkit.Rows("0"))
+	// There is no index on the arguments, so they are checked in a hash set.
+	result = tk.MustQuery("select count(distinct d, e) from t")
+	result.Check(testkit.Rows("4"))
+	result = tk.MustQuery("select count(distinct c + 1, d) from t")
+	result.Check(testkit.Rows("5"))
+}
+
+func (s *testSuite) TestRowInPrimaryKey(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int, c int, primary key (a, b))")
+	tk.MustExec("insert t values (1, 1, 1), (1, 2, 2), (2, 1, 3), (3, 4, 4)")
+	result := tk.MustQuery("select * from t where (a, b) in ((3, 4), (1, 2), (3, 4), (5, 6))")
+	result.Check(testkit.Rows("1 2 2", "3 4 4"))
+	result = tk.MustQuery("select c from t where (b, a) in ((1, 2), (1, 1), (2, null)) and c > 1")
+	result.Check(testkit.Rows("3"))
+}
+
+func (s *testSuite) TestHandler(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, c int, d int, index c_d (c, d))")
+	tk.MustExec("insert t values (1, 2, 1), (2, 1, 2), (3, 1, 1), (4, 3, 2), (5, 2, 3)")
+	tk.MustExec("handler t open")
+	result := tk.MustQuery("handler t read c_d first")
+	result.Check(testkit.Rows("3 1 1"))
+	result = tk.MustQuery("handler t read c_d last limit 2")
+	result.Check(testkit.Rows("4 3 2", "5 2 3"))
+	result = tk.MustQuery("handler t read c_d = (2)")
+	result.Check(testkit.Rows("1 2 1"))
+	result = tk.MustQuery("handler t read c_d >= (1, 2) limit 3")
+	result.Check(testkit.Rows("2 1 2", "1 2 1", "5 2 3"))
+	result = tk.MustQuery("handler t read c_d > (2, 1) where a < 5")
+	result.Check(testkit.Rows("4 3 2"))
+	result = tk.MustQuery("handler t read c_d < (2)")
+	result.Check(testkit.Rows("2 1 2"))
+	result = tk.MustQuery("handler t read `primary` > (3)")
+	result.Check(testkit.Rows("4 3 2"))
+	// The rows are read in the order of the primary key after the last one read.
+	result = tk.MustQuery("handler t read next where c = 2 limit 5")
+	result.Check(testkit.Rows("5 2 3"))
+	_, err := tk.Exec("handler t read d next")
+	c.Assert(plan.ErrKeyDoesNotExist.Equal(err), IsTrue)
+	tk.MustExec("handler t close")
+	_, err = tk.Exec("handler t read c_d next")
+	c.Assert(plan.ErrUnknownTable.Equal(err), IsTrue)
+	_, err = tk.Exec("handler t close")
+	c.Assert(err.Error(), Equals, "[optimizer:13]Unknown table 't' in HANDLER")
+
+	tk.MustExec("handler t open")
+	result = tk.MustQuery("handler t read c_d next")
+	result.Check(testkit.Rows("3 1 1"))
+	result = tk.MustQuery("handler t read c_d next")
+	result.Check(testkit.Rows("2 1 2"))
+	result = tk.MustQuery("handler t read c_d next limit 2")
+	result.Check(testkit.Rows("1 2 1", "5 2 3"))
+	result = tk.MustQuery("handler t read c_d prev")
+	result.Check(testkit.Rows("1 2 1"))
+	result = tk.MustQuery("handler t read c_d next")
+	result.Check(testkit.Rows("5 2 3"))
+	result = tk.MustQuery("handler t read c_d = (3)")
+	result.Check(testkit.Rows("4 3 2"))
+	result = tk.MustQuery("handler t read c_d next")
+	result.Check(testkit.Rows())
+	// Opening the handler again reads from the start.
+	tk.MustExec("handler t open")
+	result = tk.MustQuery("handler t read c_d next")
+	result.Check(testkit.Rows("3 1 1"))
+	tk.MustExec("handler t close")
+
+	// The rows with duplicate keys of a non-unique index are read in the order of their handles.
+	tk.MustExec("drop table if exists t1")
+	tk.MustExec("create table t1 (a int, c int, index c (c))")
+	tk.MustExec("insert t1 values (1, 1), (2, 1), (3, 1), (4, 2)")
+	tk.MustExec("handler t1 open")
+	result = tk.MustQuery("handler t1 read c next")
+	result.Check(testkit.Rows("1 1"))
+	result = tk.MustQuery("handler t1 read c next")
+	result.Check(testkit.Rows("2 1"))
+	result = tk.MustQuery("handler t1 read c next limit 2")
+	result.Check(testkit.Rows("3 1", "4 2"))
+	result = tk.MustQuery("handler t1 read c prev")
+	result.Check(testkit.Rows("3 1"))
+	result = tk.MustQuery("handler t1 read c prev limit 2")
+	result.Check(testkit.Rows("2 1", "1 1"))
+	result = tk.MustQuery("handler t1 read c next limit 1, 2")
+	result.Check(testkit.Rows("3 1", "4 2"))
+	result = tk.MustQuery("handler t1 read c prev where a > 1")
+	result.Check(testkit.Rows("3 1"))
+	tk.MustExec("handler t1 close")
+}
+
+func (s *testSuite) TestGroupingSets(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int, c int)")
+	tk.MustExec("insert t values (1, 1, 1), (1, NULL, 2), (2, 1, 3)")
+	result := tk.MustQuery("select a, b, count(*), sum(c) from t group by grouping sets ((a), (b))")
+	result.Check(testkit.Rows("1 <nil> 2 3", "<nil> 1 2 4", "<nil> <nil> 1 2", "2 <nil> 1 3"))
+	// The NULL of b in the last row is a real one, which GROUPING() tells from the padded ones.
+	result = tk.MustQuery("select a, b, grouping(a), grouping(b) from t group by grouping sets ((a), (b))")
+	result.Check(testkit.Rows("1 <nil> 0 1", "<nil> 1 1 0", "<nil> <nil> 1 0", "2 <nil> 0 1"))
+	result = tk.MustQuery("select b, count(*) from t group by grouping sets ((b), ()) having grouping(b) = 1")
+	result.Check(testkit.Rows("<nil> 3"))
+	result = tk.MustQuery("select a, count(*) from t where c > 1 group by grouping sets ((a), (a))")
+	result.Check(testkit.Rows("1 1", "1 1", "2 1", "2 1"))
+
+	// The old planner doesn't support them.
+	plan.UseNewPlanner = false
+	for _, sql := range []string{
+		"select a, count(*) from t group by grouping sets ((a), ())",
+		"select a, grouping(a) from t group by a",
+		"select a from t group by a having GROUPING(a) = 0",
+	} {
+		_, err := tk.Exec(sql)
+		c.Assert(plan.ErrUnsupportedType.Equal(err), IsTrue, Commentf("sql: %s", sql))
+	}
+	plan.UseNewPlanner = true
+}
+
 func (s *testSuite) TestAdapterStatement(c *C) {
 	defer testleak.AfterTest(c)()
 	se, err := tidb.CreateSession(s.store)
@@ -1842,6 +2390,36 @@ func (s *testSuite) TestRow(c *C) {
 	result.Check(testkit.Rows("1 1"))
 	result = tk.MustQuery("select * from t where (c, d) = (select * from t k where (t.c,t.d) = (c,d))")
 	result.Check(testkit.Rows("1 1", "1 3", "2 1", "2 3"))
+	result = tk.MustQuery("select * from t where (c, d) = (select c, d from t where d > 1 order by c desc limit 1)")
+	result.Check(testkit.Rows("2 3"))
+	result = tk.MustQuery("select * from t where (c, d) != (select c, d from t where c = 1 and d = 3)")
+	result.Check(testkit.Rows("1 1", "2 1", "2 3"))
+	result = tk.MustQuery("select * from t where (d, c) = (select c, d from t k where k.c = t.d and k.d = t.c)")
+	result.Check(testkit.Rows("1 1"))
+	result = tk.MustQuery("select * from t where (c, d) = (select c, d from t where c > 5)")
+	result.Check(testkit.Rows())
+
+	// The subquery compared with a row must return at most one row.
+	rs, err := tk.Exec("select * from t where (c, d) = (select c, d from t where c = 1)")
+	if err == nil {
+		_, err = rs.Next()
+		rs.Close()
+	}
+	c.Assert(err, NotNil)
+	rs, err = tk.Exec("select * from t where (c, d) = (select k.c, k.d from t k where k.c = t.c)")
+	c.Assert(err, IsNil)
+	_, err = rs.Next()
+	c.Assert(err, NotNil)
+	rs.Close()
+
+	for _, sql := range []string{
+		"select * from t where (c, d) = (select c from t limit 1)",
+		"select * from t where c = (select c, d from t limit 1)",
+		"select * from t where (c, d) < (select c, d, d from t limit 1)",
+	} {
+		_, err = tk.Exec(sql)
+		c.Assert(terror.ErrorEqual(err, plan.ErrSameColumns), IsTrue, Commentf("for %s", sql))
+	}
 }
 
 func (s *testSuite) TestColumnName(c *C) {
@@ -1876,3 +2454,93 @@ func (s *testSuite) TestSelectVar(c *C) {
 	result.Check(testkit.Rows("2 2", "2 3", "3 2"))
 
 }
+
+func (s *testSuite) TestFullScanRowLimit(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, b int, c int, index (c))")
+	tk.MustExec("insert into t values (1, 1, 1), (2, 2, 2)")
+	tk.MustExec("set tidb_full_scan_row_limit = 100")
+	// The table that hasn't been analyzed is taken to have the pseudo row count.
+	_, err := tk.Exec("select * from t where b = 1")
+	c.Assert(plan.ErrFullScanForbidden.Equal(err), IsTrue)
+	c.Assert(plan.ErrFullScanForbidden.ToSQLError().Code, Equals, uint16(mysql.ErrTooBigSelect))
+	// The non-correlated subqueries evaluated when the plan is built are checked too.
+	_, err = tk.Exec("select * from t where a = (select max(b) from t)")
+	c.Assert(plan.ErrFullScanForbidden.Equal(err), IsTrue)
+	_, err = tk.Exec("select * from t where a = 1 and exists (select * from t where b = 1)")
+	c.Assert(plan.ErrFullScanForbidden.Equal(err), IsTrue)
+	result := tk.MustQuery("select * from t use index () where b = 1")
+	result.Check(testkit.Rows("1 1 1"))
+	result = tk.MustQuery("select * from t where a = 2")
+	result.Check(testkit.Rows("2 2 2"))
+	result = tk.MustQuery("select * from t where c = 2")
+	result.Check(testkit.Rows("2 2 2"))
+	_, err = tk.Exec("set tidb_full_scan_row_limit = -1")
+	c.Assert(err, NotNil)
+	// The row count recorded by ANALYZE TABLE is used once the table is analyzed.
+	tk.MustExec("analyze table t")
+	result = tk.MustQuery("select * from t where b = 1")
+	result.Check(testkit.Rows("1 1 1"))
+	tk.MustExec("set tidb_full_scan_row_limit = 1")
+	_, err = tk.Exec("select * from t where b = 1")
+	c.Assert(plan.ErrFullScanForbidden.Equal(err), IsTrue)
+	tk.MustExec("set tidb_full_scan_row_limit = 0")
+	result = tk.MustQuery("select * from t where b = 1")
+	result.Check(testkit.Rows("1 1 1"))
+}
+
+func (s *testSuite) TestMaxExecutionTime(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, b int)")
+	tk.MustExec("insert /*+ MAX_EXECUTION_TIME(1000) */ into t values (1, 1), (2, 2)")
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 3125 MAX_EXECUTION_TIME hint is supported by top-level standalone SELECT statements only"))
+	tk.MustExec("update /*+ MAX_EXECUTION_TIME(1000) */ t set b = 3 where a = 2")
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 3125 MAX_EXECUTION_TIME hint is supported by top-level standalone SELECT statements only"))
+	tk.MustQuery("select /*+ MAX_EXECUTION_TIME(10000) */ * from t").Check(testkit.Rows("1 1", "2 3"))
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+	tk.MustQuery("select /*+ MAX_EXECUTION_TIME(ten) */ a from t where a = 1").Check(testkit.Rows("1"))
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1064 Optimizer hint syntax error at MAX_EXECUTION_TIME(ten)"))
+	tk.MustQuery("select /*+ MAX_EXECUTION_TIME(10000) MAX_EXECUTION_TIME(20000) */ a from t where a = 1").Check(testkit.Rows("1"))
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 3126 Hint MAX_EXECUTION_TIME(20000) is ignored as conflicting/duplicated"))
+
+	// The sort and the aggregation read all the rows, which takes 250ms, before returning the first one, so
+	// they're interrupted.
+	tk.MustExec("insert into t values (3, 3), (4, 4), (5, 5)")
+	for _, sql := range []string{
+		"select /*+ MAX_EXECUTION_TIME(100) */ a from t order by sleep(0.05), b",
+		"select /*+ MAX_EXECUTION_TIME(100) */ count(sleep(0.05)) from t",
+	} {
+		rs, err := tk.Exec(sql)
+		c.Assert(err, IsNil)
+		_, err = rs.Next()
+		c.Assert(executor.ErrMaxExecTimeExceeded.Equal(err), IsTrue, Commentf("sql: %s", sql))
+		c.Assert(rs.Close(), IsNil)
+	}
+
+	// The deadline of the record set is tested in the adapter, the prepared statement gets the hinted time.
+	tk.MustExec("prepare stmt from 'select /*+ MAX_EXECUTION_TIME(1) */ * from t'")
+	ctx := tk.Se.(context.Context)
+	exec := &executor.ExecuteExec{IS: sessionctx.GetDomain(ctx).InfoSchema(), Ctx: ctx, Name: "stmt"}
+	c.Assert(exec.Build(), IsNil)
+	c.Assert(exec.MaxExecutionTime, Equals, uint64(1))
+}
+
+func (s *testSuite) TestIndexTopN(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, b int, c int, index idx_c (c))")
+	tk.MustExec("insert t values (1, 10, 5), (2, 20, 3), (3, 30, 8), (4, 40, 1), (5, 50, 7), (6, 60, 2), (7, 70, 6), (8, 80, 4)")
+	tk.MustQuery("select b from t order by c limit 5").Check(testkit.Rows("40", "60", "20", "80", "10"))
+	tk.MustQuery("select b from t order by c desc limit 5").Check(testkit.Rows("30", "50", "70", "10", "80"))
+	tk.MustQuery("select b, c from t order by c limit 2, 3").Check(testkit.Rows("20 3", "80 4", "10 5"))
+	tk.MustQuery("select b from t where c > 4 order by c limit 2").Check(testkit.Rows("10", "70"))
+	tk.MustQuery("select b from t where b > 30 order by c limit 2").Check(testkit.Rows("40", "60"))
+}
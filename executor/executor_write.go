@@ -39,6 +39,12 @@ var (
 type UpdateExec struct {
 	SelectExec  Executor
 	OrderedList []*ast.Assignment
+	// valuesInRow means that the rows of SelectExec are the columns of the tables followed by the assigned values,
+	// see plan.NewUpdate. Otherwise, the values are evaluated with the result fields of SelectExec.
+	valuesInRow bool
+	// tableOffsets are the offsets of the columns of the updated tables in the rows if valuesInRow is true, see
+	// plan.NewUpdate.
+	tableOffsets map[*model.CIStr]int
 
 	// Map for unique (Table, handle) pair.
 	updatedRowKeys map[table.Table]map[int64]struct{}
@@ -78,11 +84,18 @@ func (e *UpdateExec) Next() (*Row, error) {
 	row := e.rows[e.cursor]
 	newData := e.newRowsData[e.cursor]
 	for _, entry := range row.RowKeys {
+		if _, ok := e.tableOffsets[entry.TableAsName]; e.valuesInRow && !ok {
+			// The row keys of the tables read by the subqueries are joined in the row, these tables aren't updated.
+			continue
+		}
 		tbl := entry.Tbl
 		if e.updatedRowKeys[tbl] == nil {
 			e.updatedRowKeys[tbl] = make(map[int64]struct{})
 		}
-		offset := e.getTableOffset(tbl)
+		offset, err := e.getTableOffset(entry)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
 		handle := entry.Handle
 		oldData := row.Data[offset : offset+len(tbl.WritableCols())]
 		newTableData := newData[offset : offset+len(tbl.WritableCols())]
@@ -119,6 +132,11 @@ func (e *UpdateExec) fetchRows() error {
 		if row == nil {
 			return nil
 		}
+		if e.valuesInRow {
+			e.rows = append(e.rows, row)
+			e.newRowsData = append(e.newRowsData, e.newDataInRow(row))
+			continue
+		}
 		data := make([]types.Datum, len(e.SelectExec.Fields()))
 		newData := make([]types.Datum, len(e.SelectExec.Fields()))
 		for i, f := range e.SelectExec.Fields() {
@@ -138,13 +156,33 @@ func (e *UpdateExec) fetchRows() error {
 	}
 }
 
-func (e *UpdateExec) getTableOffset(t table.Table) int {
+// newDataInRow returns the new values of the columns in a row of SelectExec, which are followed by the assigned
+// values, and cuts the assigned values from the row.
+func (e *UpdateExec) newDataInRow(row *Row) []types.Datum {
+	newData := make([]types.Datum, len(e.OrderedList))
+	copy(newData, row.Data)
+	valueIdx := len(e.OrderedList)
+	for i, assign := range e.OrderedList {
+		if assign != nil {
+			newData[i] = row.Data[valueIdx]
+			valueIdx++
+		}
+	}
+	row.Data = row.Data[:len(e.OrderedList)]
+	return newData
+}
+
+func (e *UpdateExec) getTableOffset(entry *RowKeyEntry) (int, error) {
+	t := entry.Tbl
+	if e.valuesInRow {
+		return e.tableOffsets[entry.TableAsName], nil
+	}
 	fields := e.SelectExec.Fields()
 	i := 0
 	for i < len(fields) {
 		field := fields[i]
 		if field.Table.Name.L == t.Meta().Name.L {
-			return i
+			return i, nil
 		}
 		for _, col := range field.Table.Columns {
 			if col.State == model.StateDeleteOnly || col.State == model.StateDeleteReorganization {
@@ -153,7 +191,7 @@ func (e *UpdateExec) getTableOffset(t table.Table) int {
 			i++
 		}
 	}
-	return 0
+	return 0, errors.Errorf("table %s isn't found in the rows to update", t.Meta().Name)
 }
 
 func updateRecord(ctx context.Context, h int64, oldData, newData []types.Datum, updateColumns map[int]*ast.Assignment, t table.Table, offset int, onDuplicateUpdate bool) error {
@@ -419,6 +457,9 @@ type InsertExec struct {
 
 	OnDuplicate []*ast.Assignment
 	fields      []*ast.ResultField
+	// values are the result fields referred by the VALUES(col) expressions in OnDuplicate,
+	// which are set to the inserted row, while fields are set to the duplicate row.
+	values []*ast.ResultField
 
 	Priority int
 
@@ -764,14 +805,17 @@ func (e *InsertExec) onDuplicateUpdate(row []types.Datum, h int64, cols map[int]
 	if err != nil {
 		return errors.Trace(err)
 	}
-	// For evaluate ValuesExpr
+	// The column names refer to the duplicate row, and the ValuesExprs refer to the inserted row.
 	// http://dev.mysql.com/doc/refman/5.7/en/miscellaneous-functions.html#function_values
 	for i, rf := range e.fields {
-		rf.Expr.SetValue(row[i].GetValue())
+		rf.Expr.SetValue(data[i].GetValue())
+	}
+	for _, rf := range e.values {
+		rf.Expr.SetValue(row[rf.Column.Offset].GetValue())
 	}
 	// Evaluate assignment
 	newData := make([]types.Datum, len(data))
-	for i, c := range row {
+	for i, c := range data {
 		asgn, ok := cols[i]
 		if !ok {
 			newData[i] = c
@@ -789,6 +833,36 @@ func (e *InsertExec) onDuplicateUpdate(row []types.Datum, h int64, cols map[int]
 	return nil
 }
 
+// resolveValuesExprs makes the ValuesExprs in OnDuplicate refer to their own result fields, so they can be
+// evaluated with the inserted row while the column names are evaluated with the duplicate row.
+func (e *InsertExec) resolveValuesExprs() {
+	extractor := &valuesExprExtractor{}
+	for _, asgn := range e.OnDuplicate {
+		asgn.Expr.Accept(extractor)
+	}
+	for _, v := range extractor.values {
+		refer := *v.Column.Refer
+		refer.Expr = ast.NewValueExpr(nil)
+		v.Column.Refer = &refer
+		e.values = append(e.values, &refer)
+	}
+}
+
+type valuesExprExtractor struct {
+	values []*ast.ValuesExpr
+}
+
+func (e *valuesExprExtractor) Enter(in ast.Node) (ast.Node, bool) {
+	return in, false
+}
+
+func (e *valuesExprExtractor) Leave(in ast.Node) (ast.Node, bool) {
+	if x, ok := in.(*ast.ValuesExpr); ok {
+		e.values = append(e.values, x)
+	}
+	return in, true
+}
+
 func findColumnByName(t table.Table, tableName, colName string) (*table.Column, error) {
 	if len(tableName) > 0 && tableName != t.Meta().Name.O {
 		return nil, errors.Errorf("unknown field %s.%s", tableName, colName)
@@ -632,9 +632,12 @@ func convertIndexRangeTypes(ran *plan.IndexRange, fieldTypes []*types.FieldType)
 			ran.LowExclude = false
 		}
 		// The converted value has changed, the other column values doesn't matter.
-		// For equal condition, converted value changed means there will be no match.
+		// For equal condition, converted value changed means there will be no match,
+		// e.g. for int columns a and b, a = 1.1 and b > 3 is converted to a > 1 and a <= 1.
 		// For non equal condition, this column would be the last one to build the range.
-		// Break here to prevent the rest columns modify LowExclude again.
+		// The rest columns are removed, so the range starts before or after all the keys with the prefix,
+		// and break here to prevent the rest columns modify LowExclude again.
+		ran.LowVal = ran.LowVal[:i+1]
 		break
 	}
 	for i := range ran.HighVal {
@@ -661,6 +664,7 @@ func convertIndexRangeTypes(ran *plan.IndexRange, fieldTypes []*types.FieldType)
 		if cmp > 0 && !ran.HighExclude {
 			ran.HighExclude = true
 		}
+		ran.HighVal = ran.HighVal[:i+1]
 		break
 	}
 	return nil
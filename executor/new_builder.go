@@ -19,6 +19,7 @@ import (
 
 	"github.com/juju/errors"
 	"github.com/ngaut/log"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/model"
@@ -53,6 +54,7 @@ func (b *executorBuilder) buildJoin(v *plan.PhysicalHashJoin) Executor {
 		e.smallHashKey = rightHashKey
 		e.bigHashKey = leftHashKey
 		e.leftSmall = false
+		e.singleMatch = v.RightUnique
 	} else {
 		e.leftSmall = true
 		e.smallFilter = expression.ComposeCNFCondition(v.LeftConditions)
@@ -96,13 +98,33 @@ func (b *executorBuilder) buildSemiJoin(v *plan.PhysicalHashSemiJoin) Executor {
 		smallHashKey: rightHashKey,
 		withAux:      v.WithAux,
 		anti:         v.Anti,
+		nullAware:    v.NullAware,
 		targetTypes:  targetTypes,
 	}
 	return e
 }
 
 func (b *executorBuilder) buildAggregation(v *plan.Aggregation) Executor {
+	if v.GroupSkip {
+		if e := b.buildGroupSkip(v); e != nil || b.err != nil {
+			return e
+		}
+	}
 	src := b.build(v.GetChildByIndex(0))
+	if v.Streamed {
+		return &StreamAggExec{
+			Src:          src,
+			schema:       v.GetSchema(),
+			ctx:          b.ctx,
+			AggFuncs:     v.AggFuncs,
+			GroupByItems: v.GroupByItems,
+		}
+	}
+	if v.StreamedDistinct {
+		for _, af := range v.AggFuncs {
+			af.SetSortedDistinct()
+		}
+	}
 	e := &AggregationExec{
 		Src:          src,
 		schema:       v.GetSchema(),
@@ -347,6 +369,39 @@ func (b *executorBuilder) buildNewIndexScan(v *plan.PhysicalIndexScan, s *plan.S
 	return nil
 }
 
+// buildGroupSkip builds the executor seeking the groups in the index for the aggregation. It returns nil if the
+// transaction has written, because the seeks don't read the written rows, so the aggregation falls back to
+// reading all the index entries.
+func (b *executorBuilder) buildGroupSkip(v *plan.Aggregation) Executor {
+	txn, err := b.ctx.GetTxn(false)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	if !txn.IsReadOnly() {
+		return nil
+	}
+	is := v.GetChildByIndex(0).(*plan.PhysicalIndexScan)
+	schema := is.GetSchema()
+	e := &GroupSkipExec{
+		schema:    v.GetSchema(),
+		ctx:       b.ctx,
+		AggFuncs:  v.AggFuncs,
+		indexPlan: is,
+		desc:      is.Desc,
+		builder:   b,
+	}
+	for _, idxCol := range is.Index.Columns[:len(v.GroupByItems)+1] {
+		for i, col := range schema {
+			if col.ColName.L == idxCol.Name.L {
+				e.colOffsets = append(e.colOffsets, i)
+				break
+			}
+		}
+	}
+	return e
+}
+
 func (b *executorBuilder) buildNewSort(v *plan.NewSort) Executor {
 	src := b.build(v.GetChildByIndex(0))
 	return &NewSortExec{
@@ -377,6 +432,25 @@ func (b *executorBuilder) buildApply(v *plan.PhysicalApply) Executor {
 	return apply
 }
 
+func (b *executorBuilder) buildCache(v *plan.PhysicalCache) Executor {
+	e := &CacheExec{
+		schema: v.GetSchema(),
+		Src:    b.build(v.GetChildByIndex(0)),
+	}
+	if v.ShareID == 0 {
+		e.result = &cacheResult{}
+		return e
+	}
+	if b.cacheResults == nil {
+		b.cacheResults = make(map[int]*cacheResult)
+	}
+	if _, ok := b.cacheResults[v.ShareID]; !ok {
+		b.cacheResults[v.ShareID] = &cacheResult{}
+	}
+	e.result = b.cacheResults[v.ShareID]
+	return e
+}
+
 func (b *executorBuilder) buildExists(v *plan.Exists) Executor {
 	return &ExistsExec{
 		schema: v.GetSchema(),
@@ -391,6 +465,23 @@ func (b *executorBuilder) buildMaxOneRow(v *plan.MaxOneRow) Executor {
 	}
 }
 
+func (b *executorBuilder) buildHandlerRead(v *plan.HandlerRead) Executor {
+	e := &HandlerReadExec{
+		schema:    v.GetSchema(),
+		Src:       b.build(v.GetChildByIndex(0)),
+		handler:   v.Handler,
+		indexName: v.IndexName,
+		lastKey:   v.LastKey,
+		desc:      v.Desc,
+		offset:    v.Offset,
+		count:     v.Count,
+	}
+	for _, col := range v.KeyColumns {
+		e.keyOffsets = append(e.keyOffsets, v.GetSchema().GetIndex(col))
+	}
+	return e
+}
+
 func (b *executorBuilder) buildTrim(v *plan.Trim) Executor {
 	return &TrimExec{
 		schema: v.GetSchema(),
@@ -399,6 +490,41 @@ func (b *executorBuilder) buildTrim(v *plan.Trim) Executor {
 	}
 }
 
+func (b *executorBuilder) buildExpand(v *plan.Expand) Executor {
+	return &ExpandExec{
+		schema:       v.GetSchema(),
+		Src:          b.build(v.GetChildByIndex(0)),
+		groupingCols: v.GroupingCols,
+		groupingSets: v.GroupingSets,
+	}
+}
+
+func (b *executorBuilder) buildDo(v *plan.Do) Executor {
+	return &DoExec{Src: b.build(v.GetChildByIndex(0))}
+}
+
+func (b *executorBuilder) buildSet(v *plan.Set) Executor {
+	values := make(map[*ast.VariableAssignment]Executor, len(v.Values))
+	for assign, value := range v.Values {
+		values[assign] = b.build(value)
+	}
+	return &SetExec{
+		Stmt:   v.Stmt,
+		Values: values,
+		ctx:    b.ctx,
+	}
+}
+
+func (b *executorBuilder) buildNewUpdate(v *plan.NewUpdate) Executor {
+	return &UpdateExec{
+		ctx:          b.ctx,
+		SelectExec:   b.build(v.GetChildByIndex(0)),
+		OrderedList:  v.OrderedList,
+		valuesInRow:  true,
+		tableOffsets: v.TableOffsets,
+	}
+}
+
 func (b *executorBuilder) buildNewUnion(v *plan.NewUnion) Executor {
 	e := &NewUnionExec{
 		schema: v.GetSchema(),
@@ -14,6 +14,7 @@
 package executor
 
 import (
+	"bytes"
 	"sort"
 
 	"github.com/juju/errors"
@@ -24,6 +25,7 @@ import (
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/plan"
+	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/types"
@@ -49,6 +51,8 @@ type HashJoinExec struct {
 	cursor       int
 	// targetTypes means the target the type that both smallHashKey and bigHashKey should convert to.
 	targetTypes []*types.FieldType
+	// singleMatch means every big row matches at most one small row.
+	singleMatch bool
 }
 
 // Close implements Executor Close interface.
@@ -116,6 +120,9 @@ func (e *HashJoinExec) prepare() error {
 	e.hashTable = make(map[string][]*Row)
 	e.cursor = 0
 	for {
+		if err := checkStmtDeadline(e.ctx); err != nil {
+			return errors.Trace(err)
+		}
 		row, err := e.smallExec.Next()
 		if err != nil {
 			return errors.Trace(err)
@@ -183,6 +190,9 @@ func (e *HashJoinExec) constructMatchedRows(bigRow *Row) (matchedRows []*Row, er
 		}
 		if otherMatched {
 			matchedRows = append(matchedRows, matchedRow)
+			if e.singleMatch {
+				break
+			}
 		}
 	}
 
@@ -190,9 +200,9 @@ func (e *HashJoinExec) constructMatchedRows(bigRow *Row) (matchedRows []*Row, er
 }
 
 func (e *HashJoinExec) fillNullRow(bigRow *Row) (returnRow *Row) {
+	// The padded row has no row keys, because it isn't read from the tables.
 	smallRow := &Row{
-		RowKeys: make([]*RowKeyEntry, len(e.smallExec.Schema())),
-		Data:    make([]types.Datum, len(e.smallExec.Schema())),
+		Data: make([]types.Datum, len(e.smallExec.Schema())),
 	}
 
 	for _, data := range smallRow.Data {
@@ -281,6 +291,8 @@ type HashSemiJoinExec struct {
 	smallTableHasNull bool
 	// If anti is true, semi join only output the unmatched row.
 	anti bool
+	// If nullAware is true, a null key is unknown to match rather than unmatched, as for IN.
+	nullAware bool
 }
 
 // Close implements Executor Close interface.
@@ -308,6 +320,9 @@ func (e *HashSemiJoinExec) Fields() []*ast.ResultField {
 func (e *HashSemiJoinExec) prepare() error {
 	e.hashTable = make(map[string][]*Row)
 	for {
+		if err := checkStmtDeadline(e.ctx); err != nil {
+			return errors.Trace(err)
+		}
 		row, err := e.smallExec.Next()
 		if err != nil {
 			return errors.Trace(err)
@@ -332,7 +347,7 @@ func (e *HashSemiJoinExec) prepare() error {
 			return errors.Trace(err)
 		}
 		if hasNull {
-			e.smallTableHasNull = true
+			e.smallTableHasNull = e.nullAware
 			continue
 		}
 		if rows, ok := e.hashTable[string(hashcode)]; !ok {
@@ -352,7 +367,8 @@ func (e *HashSemiJoinExec) rowIsMatched(bigRow *Row) (matched bool, hasNull bool
 		return false, false, errors.Trace(err)
 	}
 	if hasNull {
-		return false, true, nil
+		// A null key is unknown to match unless the small table is empty.
+		return false, e.nullAware && (len(e.hashTable) > 0 || e.smallTableHasNull), nil
 	}
 	rows, ok := e.hashTable[string(hashcode)]
 	if !ok {
@@ -407,9 +423,9 @@ func (e *HashSemiJoinExec) Next() (*Row, error) {
 			if err != nil {
 				return nil, errors.Trace(err)
 			}
-		}
-		if !matched && e.smallTableHasNull {
-			isNull = true
+			if !matched && e.smallTableHasNull {
+				isNull = true
+			}
 		}
 		if e.anti && !isNull {
 			matched = !matched
@@ -469,6 +485,9 @@ func (e *AggregationExec) Next() (*Row, error) {
 	if !e.executed {
 		e.groupMap = make(map[string]bool)
 		for {
+			if err := checkStmtDeadline(e.ctx); err != nil {
+				return nil, errors.Trace(err)
+			}
 			hasMore, err := e.innerNext()
 			if err != nil {
 				return nil, errors.Trace(err)
@@ -499,12 +518,16 @@ func (e *AggregationExec) Next() (*Row, error) {
 }
 
 func (e *AggregationExec) getGroupKey(row *Row) ([]byte, error) {
-	if len(e.GroupByItems) == 0 {
+	return getGroupKey(e.ctx, e.GroupByItems, row)
+}
+
+func getGroupKey(ctx context.Context, groupByItems []expression.Expression, row *Row) ([]byte, error) {
+	if len(groupByItems) == 0 {
 		return []byte{}, nil
 	}
-	vals := make([]types.Datum, 0, len(e.GroupByItems))
-	for _, item := range e.GroupByItems {
-		v, err := item.Eval(row.Data, e.ctx)
+	vals := make([]types.Datum, 0, len(groupByItems))
+	for _, item := range groupByItems {
+		v, err := item.Eval(row.Data, ctx)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -550,6 +573,195 @@ func (e *AggregationExec) innerNext() (ret bool, err error) {
 	return true, nil
 }
 
+// StreamAggExec deals with the aggregate functions over the rows sorted by the group by items.
+// It only keeps the current group in memory, and returns it once a row of the next group is read.
+type StreamAggExec struct {
+	Src          Executor
+	schema       expression.Schema
+	ctx          context.Context
+	AggFuncs     []expression.AggregationFunction
+	GroupByItems []expression.Expression
+
+	executed    bool
+	hasGroup    bool
+	curGroupKey []byte
+}
+
+// Close implements Executor Close interface.
+func (e *StreamAggExec) Close() error {
+	e.executed = false
+	e.hasGroup = false
+	e.curGroupKey = nil
+	for _, agg := range e.AggFuncs {
+		agg.Clear()
+	}
+	return e.Src.Close()
+}
+
+// Schema implements Executor Schema interface.
+func (e *StreamAggExec) Schema() expression.Schema {
+	return e.schema
+}
+
+// Fields implements Executor Fields interface.
+func (e *StreamAggExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Next implements Executor Next interface.
+func (e *StreamAggExec) Next() (*Row, error) {
+	for !e.executed {
+		srcRow, err := e.Src.Next()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if srcRow == nil {
+			e.executed = true
+			break
+		}
+		groupKey, err := getGroupKey(e.ctx, e.GroupByItems, srcRow)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		var retRow *Row
+		if e.hasGroup && !bytes.Equal(groupKey, e.curGroupKey) {
+			retRow = e.finishGroup()
+		}
+		e.curGroupKey, e.hasGroup = groupKey, true
+		for _, af := range e.AggFuncs {
+			if err = af.Update(srcRow.Data, groupKey, e.ctx); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if retRow != nil {
+			return retRow, nil
+		}
+	}
+	if !e.hasGroup {
+		return nil, nil
+	}
+	return e.finishGroup(), nil
+}
+
+// finishGroup returns the result of the current group and releases it.
+func (e *StreamAggExec) finishGroup() *Row {
+	retRow := &Row{Data: make([]types.Datum, 0, len(e.AggFuncs))}
+	for _, af := range e.AggFuncs {
+		retRow.Data = append(retRow.Data, af.GetGroupResult(e.curGroupKey))
+		af.Clear()
+	}
+	e.hasGroup = false
+	return retRow
+}
+
+// GroupSkipExec aggregates the groups over an index, whose leading columns are the group by columns followed by
+// the argument of MIN, or MAX if the index is read in the descending order. The first entry of every group has
+// the result, so only it is read, and the next group is found by seeking past the group, i.e. a loose index scan.
+type GroupSkipExec struct {
+	schema   expression.Schema
+	ctx      context.Context
+	AggFuncs []expression.AggregationFunction
+
+	// indexPlan is the scan of all the index entries, which is copied to seek the groups.
+	indexPlan *plan.PhysicalIndexScan
+	desc      bool
+	// colOffsets are the offsets in the index rows of the group by columns in the index order, followed by
+	// the aggregated column.
+	colOffsets []int
+	builder    *executorBuilder
+
+	lastGroup []types.Datum
+	executed  bool
+}
+
+// Schema implements Executor Schema interface.
+func (e *GroupSkipExec) Schema() expression.Schema {
+	return e.schema
+}
+
+// Fields implements Executor Fields interface.
+func (e *GroupSkipExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *GroupSkipExec) Close() error {
+	e.lastGroup = nil
+	e.executed = false
+	return nil
+}
+
+// Next implements Executor Next interface.
+func (e *GroupSkipExec) Next() (*Row, error) {
+	if e.executed {
+		return nil, nil
+	}
+	ran := &plan.IndexRange{LowVal: []types.Datum{{}}, HighVal: []types.Datum{types.MaxValueDatum()}}
+	if e.lastGroup != nil {
+		if e.desc {
+			ran.HighVal, ran.HighExclude = e.lastGroup, true
+		} else {
+			ran.LowVal, ran.LowExclude = e.lastGroup, true
+		}
+	}
+	row, err := e.seek(ran)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if row == nil {
+		e.executed = true
+		return nil, nil
+	}
+	groupLen := len(e.colOffsets) - 1
+	e.lastGroup = make([]types.Datum, 0, groupLen)
+	for _, offset := range e.colOffsets[:groupLen] {
+		e.lastGroup = append(e.lastGroup, row.Data[offset])
+	}
+	if !e.desc && row.Data[e.colOffsets[groupLen]].IsNull() {
+		// MIN ignores the nulls, which are the first entries of the group.
+		ran = &plan.IndexRange{
+			LowVal:  append(append([]types.Datum(nil), e.lastGroup...), types.MinNotNullDatum()),
+			HighVal: append(append([]types.Datum(nil), e.lastGroup...), types.MaxValueDatum()),
+		}
+		notNullRow, err := e.seek(ran)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if notNullRow != nil {
+			row = notNullRow
+		}
+	}
+	retRow := &Row{Data: make([]types.Datum, 0, len(e.AggFuncs))}
+	for _, af := range e.AggFuncs {
+		d, err := af.GetArgs()[0].Eval(row.Data, e.ctx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		retRow.Data = append(retRow.Data, d)
+	}
+	return retRow, nil
+}
+
+// seek returns the first index entry in the range read in the order of the index scan.
+func (e *GroupSkipExec) seek(ran *plan.IndexRange) (*Row, error) {
+	// The range is converted to the column types when it's sent, so the values of the last group are copied.
+	ran.LowVal = append([]types.Datum(nil), ran.LowVal...)
+	ran.HighVal = append([]types.Datum(nil), ran.HighVal...)
+	is := *e.indexPlan
+	is.Ranges = []*plan.IndexRange{ran}
+	limit := int64(1)
+	is.LimitCount = &limit
+	exec := e.builder.buildNewIndexScan(&is, nil)
+	if e.builder.err != nil {
+		return nil, errors.Trace(e.builder.err)
+	}
+	row, err := exec.Next()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return row, errors.Trace(exec.Close())
+}
+
 // ProjectionExec represents a select fields executor.
 type ProjectionExec struct {
 	Src          Executor
@@ -879,6 +1091,9 @@ func (e *NewSortExec) Next() (*Row, error) {
 			totalCount = offset + int(e.Limit.Count)
 		}
 		for {
+			if err := checkStmtDeadline(e.ctx); err != nil {
+				return nil, errors.Trace(err)
+			}
 			srcRow, err := e.Src.Next()
 			if err != nil {
 				return nil, errors.Trace(err)
@@ -1264,6 +1479,65 @@ func (e *ApplyExec) Next() (*Row, error) {
 	}
 }
 
+// CacheExec fetches all the rows from Src when it is executed for the first time,
+// then returns the cached rows for every execution after Close.
+type CacheExec struct {
+	schema expression.Schema
+	Src    Executor
+	result *cacheResult
+	cursor int
+}
+
+// cacheResult is the rows cached by the cache executors. It's shared by the cache executors of the identical
+// subqueries, whose sources return the same rows, so it's fetched from the source executed first.
+type cacheResult struct {
+	rows    []*Row
+	fetched bool
+}
+
+// Schema implements Executor Schema interface.
+func (e *CacheExec) Schema() expression.Schema {
+	return e.schema
+}
+
+// Fields implements Executor Fields interface.
+func (e *CacheExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *CacheExec) Close() error {
+	e.cursor = 0
+	return nil
+}
+
+// Next implements Executor Next interface.
+func (e *CacheExec) Next() (*Row, error) {
+	if !e.result.fetched {
+		for {
+			row, err := e.Src.Next()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if row == nil {
+				break
+			}
+			e.result.rows = append(e.result.rows, row)
+		}
+		e.result.fetched = true
+		if err := e.Src.Close(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if e.cursor >= len(e.result.rows) {
+		return nil, nil
+	}
+	row := e.result.rows[e.cursor]
+	e.cursor++
+	// The parent may append data to the row, so limit the capacity to keep the cached data untouched.
+	return &Row{Data: row.Data[:len(row.Data):len(row.Data)], RowKeys: row.RowKeys}, nil
+}
+
 // ExistsExec represents exists executor.
 type ExistsExec struct {
 	schema    expression.Schema
@@ -1346,6 +1620,85 @@ func (e *MaxOneRowExec) Next() (*Row, error) {
 	return nil, nil
 }
 
+// HandlerReadExec returns the rows read by HANDLER READ and keeps the index key and the handle of the last row in
+// the handler. If lastKey isn't nil, the rows up to it in the order of (key, handle) are skipped and the limit is
+// applied here.
+type HandlerReadExec struct {
+	schema     expression.Schema
+	Src        Executor
+	handler    *variable.Handler
+	indexName  string
+	keyOffsets []int
+	lastKey    []types.Datum
+	desc       bool
+	offset     uint64
+	count      uint64
+	cursor     uint64
+}
+
+// Schema implements Executor Schema interface.
+func (e *HandlerReadExec) Schema() expression.Schema {
+	return e.schema
+}
+
+// Fields implements Executor Fields interface.
+func (e *HandlerReadExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *HandlerReadExec) Close() error {
+	return e.Src.Close()
+}
+
+// Next implements Executor Next interface.
+func (e *HandlerReadExec) Next() (*Row, error) {
+	for {
+		if e.lastKey != nil && e.cursor >= e.offset+e.count {
+			return nil, nil
+		}
+		row, err := e.Src.Next()
+		if err != nil || row == nil {
+			return nil, errors.Trace(err)
+		}
+		key := make([]types.Datum, 0, len(e.keyOffsets)+1)
+		for _, offset := range e.keyOffsets {
+			key = append(key, row.Data[offset])
+		}
+		key = append(key, types.NewIntDatum(row.RowKeys[0].Handle))
+		if e.lastKey != nil {
+			cmp, err := compareHandlerKeys(key, e.lastKey)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if e.desc {
+				cmp = -cmp
+			}
+			if cmp <= 0 {
+				continue
+			}
+			e.cursor++
+			if e.cursor <= e.offset {
+				continue
+			}
+		}
+		e.handler.IndexName = e.indexName
+		e.handler.LastKey = key
+		return row, nil
+	}
+}
+
+// compareHandlerKeys compares the index keys followed by the handles part by part.
+func compareHandlerKeys(a, b []types.Datum) (int, error) {
+	for i := range a {
+		cmp, err := a[i].CompareDatum(b[i])
+		if err != nil || cmp != 0 {
+			return cmp, errors.Trace(err)
+		}
+	}
+	return 0, nil
+}
+
 // TrimExec truncates src rows.
 type TrimExec struct {
 	schema expression.Schema
@@ -1381,6 +1734,149 @@ func (e *TrimExec) Next() (*Row, error) {
 	return row, nil
 }
 
+// ExpandExec outputs each row of Src once for every grouping set. The row is followed by
+// the grouping columns, which are NULL if absent in the set, their padded flags and the set id.
+type ExpandExec struct {
+	schema       expression.Schema
+	Src          Executor
+	groupingCols []*expression.Column
+	groupingSets [][]int
+	row          *Row
+	cursor       int
+}
+
+// Schema implements Executor Schema interface.
+func (e *ExpandExec) Schema() expression.Schema {
+	return e.schema
+}
+
+// Fields implements Executor Fields interface.
+func (e *ExpandExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *ExpandExec) Close() error {
+	e.row = nil
+	e.cursor = 0
+	return e.Src.Close()
+}
+
+// Next implements Executor Next interface.
+func (e *ExpandExec) Next() (*Row, error) {
+	if e.row == nil || e.cursor >= len(e.groupingSets) {
+		row, err := e.Src.Next()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if row == nil {
+			return nil, nil
+		}
+		e.row = row
+		e.cursor = 0
+	}
+	n := len(e.groupingCols)
+	data := make([]types.Datum, len(e.row.Data)+2*n+1)
+	copy(data, e.row.Data)
+	padded, flags := data[len(e.row.Data):], data[len(e.row.Data)+n:]
+	for i := 0; i < n; i++ {
+		flags[i].SetInt64(1)
+	}
+	for _, i := range e.groupingSets[e.cursor] {
+		padded[i] = e.row.Data[e.groupingCols[i].Index]
+		flags[i].SetInt64(0)
+	}
+	flags[n].SetInt64(int64(e.cursor))
+	e.cursor++
+	return &Row{Data: data, RowKeys: e.row.RowKeys}, nil
+}
+
+// DoExec executes Src for DO statement and discards the rows.
+type DoExec struct {
+	Src Executor
+}
+
+// Schema implements Executor Schema interface.
+func (e *DoExec) Schema() expression.Schema {
+	return nil
+}
+
+// Fields implements Executor Fields interface.
+func (e *DoExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *DoExec) Close() error {
+	return e.Src.Close()
+}
+
+// Next implements Executor Next interface.
+func (e *DoExec) Next() (*Row, error) {
+	for {
+		row, err := e.Src.Next()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if row == nil {
+			return nil, nil
+		}
+	}
+}
+
+// SetExec executes SET statement whose values contain subqueries. The variables are set in order as MySQL does,
+// so a value is evaluated after the earlier variables are set.
+type SetExec struct {
+	Stmt *ast.SetStmt
+	// Values are the executors of the values with subqueries, each of which returns one row of the value.
+	Values map[*ast.VariableAssignment]Executor
+	ctx    context.Context
+	done   bool
+}
+
+// Schema implements Executor Schema interface.
+func (e *SetExec) Schema() expression.Schema {
+	return nil
+}
+
+// Fields implements Executor Fields interface.
+func (e *SetExec) Fields() []*ast.ResultField {
+	return nil
+}
+
+// Close implements Executor Close interface.
+func (e *SetExec) Close() error {
+	for _, value := range e.Values {
+		if err := value.Close(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// Next implements Executor Next interface.
+func (e *SetExec) Next() (*Row, error) {
+	if e.done {
+		return nil, nil
+	}
+	simple := &SimpleExec{Statement: e.Stmt, ctx: e.ctx}
+	for _, v := range e.Stmt.Variables {
+		var value *types.Datum
+		if exec, ok := e.Values[v]; ok {
+			row, err := exec.Next()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			value = &row.Data[0]
+		}
+		if err := simple.executeSetVar(v, value); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	e.done = true
+	return nil, nil
+}
+
 // NewUnionExec represents union executor.
 type NewUnionExec struct {
 	fields []*ast.ResultField
@@ -27,6 +27,7 @@ import (
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/util/codec"
@@ -175,8 +176,27 @@ func (e *NewXSelectIndexExec) nextForSingleRead() (*Row, error) {
 			// TODO: Implement aggregation push down in single read index
 			return nil, errors.New("Can't push aggr in a single read index executor!")
 		}
-		return resultRowToRow(e.table, h, rowData, e.asName), nil
+		return resultRowToRow(e.table, h, e.indexRowToColumns(h, rowData), e.asName), nil
+	}
+}
+
+// indexRowToColumns picks the data of the required columns from an index row, whose data is in index column order.
+// The integer primary key isn't stored as an index column, so we get it from the handle.
+func (e *NewXSelectIndexExec) indexRowToColumns(h int64, rowData []types.Datum) []types.Datum {
+	data := make([]types.Datum, 0, len(e.indexPlan.Columns))
+	for _, col := range e.indexPlan.Columns {
+		if e.tableInfo.PKIsHandle && mysql.HasPriKeyFlag(col.Flag) {
+			data = append(data, types.NewIntDatum(h))
+			continue
+		}
+		for i, idxCol := range e.indexPlan.Index.Columns {
+			if idxCol.Name.L == col.Name.L {
+				data = append(data, rowData[i])
+				break
+			}
+		}
 	}
+	return data
 }
 
 func (e *NewXSelectIndexExec) nextForDoubleRead() (*Row, error) {
@@ -269,7 +289,6 @@ func (e *NewXSelectIndexExec) doIndexRequest() (*xapi.SelectResult, error) {
 	concurrency := 1
 	if !e.indexPlan.DoubleRead {
 		concurrency = defaultConcurrency
-		selIdxReq.IndexInfo.Columns = xapi.ColumnsToProto(e.indexPlan.Columns, false)
 	} else if e.indexPlan.OutOfOrder {
 		concurrency = defaultConcurrency
 	}
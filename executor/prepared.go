@@ -183,6 +183,11 @@ type ExecuteExec struct {
 	ID        uint32
 	StmtExec  Executor
 	Stmt      ast.StmtNode
+	// Cacheable means the plan built for this execution doesn't depend on the values of the parameters and
+	// the system variables, so it can be reused by the later executions.
+	Cacheable bool
+	// MaxExecutionTime is the maximum execution time in milliseconds hinted by the prepared statement.
+	MaxExecutionTime uint64
 }
 
 // Schema implements Executor Schema interface.
@@ -248,6 +253,9 @@ func (e *ExecuteExec) Build() error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	// The executor builder moves the conditions pushed down to the coprocessor out of the plan, so the plan is
+	// checked before it's built.
+	e.Cacheable = plan.UseNewPlanner && plan.Cacheable(p)
 	b := newExecutorBuilder(e.Ctx, e.IS)
 	stmtExec := b.build(p)
 	if b.err != nil {
@@ -255,6 +263,7 @@ func (e *ExecuteExec) Build() error {
 	}
 	e.StmtExec = stmtExec
 	e.Stmt = prepared.Stmt
+	e.MaxExecutionTime = p.MaxExecutionTime()
 	return nil
 }
 
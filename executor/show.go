@@ -29,6 +29,7 @@ import (
 	"github.com/pingcap/tidb/privilege"
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/charset"
 	"github.com/pingcap/tidb/util/types"
 )
@@ -115,7 +116,7 @@ func (e *ShowExec) fetchAll() error {
 	case ast.ShowVariables:
 		return e.fetchShowVariables()
 	case ast.ShowWarnings:
-		// empty result
+		return e.fetchShowWarnings()
 	}
 	return nil
 }
@@ -135,6 +136,19 @@ func (e *ShowExec) fetchShowEngines() error {
 	return nil
 }
 
+func (e *ShowExec) fetchShowWarnings() error {
+	for _, warn := range variable.GetSessionVars(e.ctx).GetWarnings() {
+		warn = errors.Cause(warn)
+		code, msg := uint16(mysql.ErrUnknown), warn.Error()
+		if te, ok := warn.(*terror.Error); ok {
+			sqlErr := te.ToSQLError()
+			code, msg = sqlErr.Code, sqlErr.Message
+		}
+		e.rows = append(e.rows, &Row{Data: types.MakeDatums("Warning", int64(code), msg)})
+	}
+	return nil
+}
+
 func (e *ShowExec) fetchShowDatabases() error {
 	dbs := e.is.AllSchemaNames()
 	// TODO: let information_schema be the first database
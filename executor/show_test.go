@@ -139,3 +139,21 @@ func (s *testSuite) TestForeignKeyInShowCreateTable(c *C) {
 	}
 
 }
+
+func (s *testSuite) TestShowWarnings(c *C) {
+	defer testleak.AfterTest(c)()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, b int, c int, d int, index idx_c (c))")
+	tk.MustQuery("select * from t where b = 1")
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1105 Full scan on table t, an index on (b) may help"))
+	// SHOW WARNINGS doesn't clear the warnings.
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1105 Full scan on table t, an index on (b) may help"))
+	tk.MustQuery("select * from t where d > 1 and b = 1 and c > 1")
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1105 Full scan on table t, an index on (b, d) may help"))
+	tk.MustQuery("select * from t where a = 1 and b = 1")
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+	tk.MustQuery("select * from t where b = d")
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+}
@@ -51,6 +51,10 @@ type AggregationFunction interface {
 
 	// SetContext sets the aggregate evaluation context.
 	SetContext(ctx map[string](*ast.AggEvaluateContext))
+
+	// SetSortedDistinct indicates the arguments are updated in order, so the distinct ones are found by comparing
+	// the arguments with the last ones.
+	SetSortedDistinct()
 }
 
 // NewAggFunction creates a new AggregationFunction.
@@ -81,6 +85,7 @@ type aggFunction struct {
 	Args         []Expression
 	Distinct     bool
 	resultMapper aggCtxMapper
+	sorted       bool
 }
 
 func newAggFunc(name string, args []Expression, dist bool) aggFunction {
@@ -118,7 +123,9 @@ func (af *aggFunction) getContext(groupKey []byte) *ast.AggEvaluateContext {
 	ctx, ok := af.resultMapper[string(groupKey)]
 	if !ok {
 		ctx = &ast.AggEvaluateContext{}
-		if af.Distinct {
+		if af.Distinct && af.sorted {
+			ctx.DistinctChecker = distinct.CreateSortedDistinctChecker()
+		} else if af.Distinct {
 			ctx.DistinctChecker = distinct.CreateDistinctChecker()
 		}
 		af.resultMapper[string(groupKey)] = ctx
@@ -130,6 +137,11 @@ func (af *aggFunction) SetContext(ctx map[string](*ast.AggEvaluateContext)) {
 	af.resultMapper = ctx
 }
 
+// SetSortedDistinct implements AggregationFunction interface.
+func (af *aggFunction) SetSortedDistinct() {
+	af.sorted = true
+}
+
 func (af *aggFunction) updateSum(row []types.Datum, groupKey []byte, ectx context.Context) error {
 	ctx := af.getContext(groupKey)
 	a := af.Args[0]
@@ -39,6 +39,9 @@ type Expression interface {
 
 	// ToString converts an expression into a string.
 	ToString() string
+
+	// Equal checks if two expressions are the same expression.
+	Equal(expr Expression) bool
 }
 
 // EvalBool evaluates expression to a boolean value.
@@ -72,6 +75,9 @@ type Column struct {
 	// IsAggOrSubq means if this column is referenced to a Aggregation column or a Subquery column.
 	// If so, this column's name will be the plain sql text.
 	IsAggOrSubq bool
+	// Redundant means this column of a join is coalesced with the column of the same name on the other side by
+	// USING or NATURAL, so it can only be referred to by its qualified name.
+	Redundant bool
 
 	// only used during execution
 	Index      int
@@ -149,11 +155,14 @@ func (s Schema) DeepCopy() Schema {
 }
 
 // FindColumn finds an Column from schema for a ast.ColumnName. It compares the db/table/column names.
-// If there are more than one result, it will raise ambiguous error.
+// If there are more than one result, it will raise ambiguous error. A redundant column is found only by a qualified name.
 func (s Schema) FindColumn(astCol *ast.ColumnName) (*Column, error) {
 	dbName, tblName, colName := astCol.Schema, astCol.Table, astCol.Name
 	idx := -1
 	for i, col := range s {
+		if col.Redundant && tblName.L == "" {
+			continue
+		}
 		if (dbName.L == "" || dbName.L == col.DBName.L) &&
 			(tblName.L == "" || tblName.L == col.TblName.L) &&
 			(colName.L == col.ColName.L) {
@@ -234,10 +243,11 @@ func NewFunction(funcName string, retType *types.FieldType, args ...Expression)
 	}
 
 	datums := make([]types.Datum, 0, len(args))
-
+	parameterized := false
 	for i := 0; i < len(args) && canConstantFolding; i++ {
 		if v, ok := args[i].(*Constant); ok {
 			datums = append(datums, types.NewDatum(v.Value.GetValue()))
+			parameterized = parameterized || v.Parameterized
 		} else {
 			canConstantFolding = false
 		}
@@ -250,8 +260,9 @@ func NewFunction(funcName string, retType *types.FieldType, args ...Expression)
 			return nil, errors.Trace(err)
 		}
 		return &Constant{
-			Value:   newArgs,
-			RetType: retType,
+			Value:         newArgs,
+			RetType:       retType,
+			Parameterized: parameterized,
 		}, nil
 	}
 	funcArgs := make([]Expression, len(args))
@@ -296,6 +307,24 @@ func (sf *ScalarFunction) DeepCopy() Expression {
 	return newFunc
 }
 
+// Equal implements Expression interface. The functions that return uncertain results, like rand(), are never
+// equal, because they may return different values.
+func (sf *ScalarFunction) Equal(expr Expression) bool {
+	fun, ok := expr.(*ScalarFunction)
+	if !ok || sf.FuncName.L != fun.FuncName.L || len(sf.Args) != len(fun.Args) {
+		return false
+	}
+	if _, ok := evaluator.DynamicFuncs[sf.FuncName.L]; ok {
+		return false
+	}
+	for i, arg := range sf.Args {
+		if !arg.Equal(fun.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetType implements Expression interface.
 func (sf *ScalarFunction) GetType() *types.FieldType {
 	return sf.RetType
@@ -317,6 +346,9 @@ func (sf *ScalarFunction) Eval(row []types.Datum, ctx context.Context) (types.Da
 type Constant struct {
 	Value   types.Datum
 	RetType *types.FieldType
+	// Parameterized means the value is of a parameter marker or a system variable, which is read when the
+	// statement is executed, so it may differ between the executions of a prepared statement.
+	Parameterized bool
 }
 
 // ToString implements Expression interface.
@@ -330,6 +362,16 @@ func (c *Constant) DeepCopy() Expression {
 	return &con
 }
 
+// Equal implements Expression interface.
+func (c *Constant) Equal(expr Expression) bool {
+	con, ok := expr.(*Constant)
+	if !ok || c.Value.Kind() != con.Value.Kind() {
+		return false
+	}
+	cmp, err := c.Value.CompareDatum(con.Value)
+	return err == nil && cmp == 0
+}
+
 // GetType implements Expression interface.
 func (c *Constant) GetType() *types.FieldType {
 	return c.RetType
@@ -355,3 +397,19 @@ func ComposeCNFCondition(conditions []Expression) Expression {
 		ComposeCNFCondition(conditions[:length/2]))
 	return expr
 }
+
+// ComposeDNFCondition composes DNF items into a balance deep DNF tree.
+func ComposeDNFCondition(conditions []Expression) Expression {
+	length := len(conditions)
+	if length == 0 {
+		return nil
+	}
+	if length == 1 {
+		return conditions[0]
+	}
+	expr, _ := NewFunction(ast.OrOr,
+		types.NewFieldType(mysql.TypeTiny),
+		ComposeDNFCondition(conditions[length/2:]),
+		ComposeDNFCondition(conditions[:length/2]))
+	return expr
+}
@@ -17,7 +17,11 @@ import (
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/util/testleak"
+	"github.com/pingcap/tidb/util/types"
 )
 
 var _ = Suite(&testExpressionSuite{})
@@ -32,3 +36,35 @@ func (s *testExpressionSuite) TestExpression(c *C) {
 	defer testleak.AfterTest(c)()
 	// TODO: add more test.
 }
+
+func (s *testExpressionSuite) TestEqual(c *C) {
+	defer testleak.AfterTest(c)()
+	tp := types.NewFieldType(mysql.TypeLonglong)
+	newCol := func(name string) *Column {
+		return &Column{FromID: "t", ColName: model.NewCIStr(name), RetType: tp}
+	}
+	newFunc := func(name string, args ...Expression) Expression {
+		f, err := NewFunction(name, tp, args...)
+		c.Assert(err, IsNil)
+		return f
+	}
+	one := &Constant{Value: types.NewIntDatum(1), RetType: tp}
+	cases := []struct {
+		a     Expression
+		b     Expression
+		equal bool
+	}{
+		{newCol("a"), newCol("a"), true},
+		{newCol("a"), newCol("b"), false},
+		{one, &Constant{Value: types.NewIntDatum(1), RetType: tp}, true},
+		{one, &Constant{Value: types.NewIntDatum(2), RetType: tp}, false},
+		{one, &Constant{Value: types.NewStringDatum("1"), RetType: tp}, false},
+		{newFunc(ast.EQ, newCol("a"), one), newFunc(ast.EQ, newCol("a"), one), true},
+		{newFunc(ast.EQ, newCol("a"), one), newFunc(ast.GT, newCol("a"), one), false},
+		{newFunc(ast.EQ, newCol("a"), newCol("b")), newFunc(ast.EQ, newCol("b"), newCol("a")), false},
+		{newFunc(ast.GT, newFunc("rand"), one), newFunc(ast.GT, newFunc("rand"), one), false},
+	}
+	for _, ca := range cases {
+		c.Assert(ca.a.Equal(ca.b), Equals, ca.equal, Commentf("for %s and %s", ca.a.ToString(), ca.b.ToString()))
+	}
+}
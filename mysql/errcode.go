@@ -883,3 +883,10 @@ const (
 	ErrRowInWrongPartition                                          = 1863
 	ErrErrorLast                                                    = 1863
 )
+
+// MySQL 5.7 error codes, which are numbered from 3000 after the codes above.
+const (
+	ErrQueryTimeout                    = 3024
+	ErrWarnUnsupportedMaxExecutionTime = 3125
+	ErrWarnConflictingHint             = 3126
+)
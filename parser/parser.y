@@ -47,6 +47,7 @@ import (
 %token	<ident>
 	/*yy:token "%c"     */	identifier      "identifier"
 	/*yy:token "\"%c\"" */	stringLit       "string literal"
+	hintComment	"optimizer hint comment"
 
 	/* the following tokens belong to NotKeywordToken*/
 	abs		"ABS"
@@ -68,6 +69,7 @@ import (
 	dayofyear	"DAYOFYEAR"
 	foundRows	"FOUND_ROWS"
 	groupConcat	"GROUP_CONCAT"
+	grouping	"GROUPING"
 	greatest	"GREATEST"
 	hour		"HOUR"
 	hex         	"HEX"
@@ -125,6 +127,7 @@ import (
 	boolType	"BOOL"
 	btree		"BTREE"
 	charsetKwd	"CHARSET"
+	closeKwd	"CLOSE"
 	checksum	"CHECKSUM"
 	collation	"COLLATION"
 	columns		"COLUMNS"
@@ -154,9 +157,11 @@ import (
 	full		"FULL"
 	grants		"GRANTS"
 	hash		"HASH"
+	handler		"HANDLER"
 	identified	"IDENTIFIED"
 	isolation	"ISOLATION"
 	keyBlockSize	"KEY_BLOCK_SIZE"
+	last		"LAST"
 	local		"LOCAL"
 	level		"LEVEL"
 	mode		"MODE"
@@ -164,11 +169,14 @@ import (
 	minRows		"MIN_ROWS"
 	names		"NAMES"
 	national	"NATIONAL"
+	next		"NEXT"
 	no		"NO"
 	offset		"OFFSET"
 	only		"ONLY"
+	openKwd		"OPEN"
 	password	"PASSWORD"
 	prepare		"PREPARE"
+	prev		"PREV"
 	privileges	"PRIVILEGES"
 	quarter		"QUARTER"
 	quick		"QUICK"
@@ -180,6 +188,7 @@ import (
 	rowFormat	"ROW_FORMAT"
 	serializable	"SERIALIZABLE"
 	session		"SESSION"
+	sets		"SETS"
 	signed		"SIGNED"
 	space 		"SPACE"
 	sqlCache	"SQL_CACHE"
@@ -292,6 +301,7 @@ import (
 	lowPriority	"LOW_PRIORITY"
 	lsh		"<<"
 	mod 		"MOD"
+	natural		"NATURAL"
 	neq		"!="
 	neqSynonym	"<>"
 	not		"NOT"
@@ -320,6 +330,8 @@ import (
 	set		"SET"
 	share		"SHARE"
 	show		"SHOW"
+	sqlBigResult	"SQL_BIG_RESULT"
+	sqlSmallResult	"SQL_SMALL_RESULT"
 	strcmp		"STRCMP"
 	sysVar		"SYS_VAR"
 	sysDate		"SYSDATE"
@@ -477,7 +489,11 @@ import (
 	GlobalScope		"The scope of variable"
 	GrantStmt		"Grant statement"
 	GroupByClause		"GROUP BY clause"
+	GroupingSet		"Grouping set"
+	GroupingSetList		"Grouping set list"
 	HashString		"Hashed string"
+	HandlerReadType		"HANDLER READ direction"
+	HandlerStmt		"HANDLER statement"
 	HavingClause		"HAVING clause"
 	IfExists		"If Exists"
 	IfNotExists		"If Not Exists"
@@ -551,6 +567,7 @@ import (
 	SelectStmt		"SELECT statement"
 	SelectStmtCalcFoundRows	"SELECT statement optional SQL_CALC_FOUND_ROWS"
 	SelectStmtSQLCache	"SELECT statement optional SQL_CAHCE/SQL_NO_CACHE"
+	SelectStmtResultSize	"SELECT statement optional SQL_SMALL_RESULT/SQL_BIG_RESULT"
 	SelectStmtDistinct	"SELECT statement optional DISTINCT clause"
 	SelectStmtFieldList	"SELECT statement field list"
 	SelectStmtLimit		"SELECT statement optional LIMIT clause"
@@ -636,6 +653,7 @@ import (
 	OptCharset		"Optional Character setting"
 	OptCollate		"Optional Collate setting"
 	NUM			"numbers"
+	OptimizerHintsOpt	"optional optimizer hints"
 	LengthNum		"Field length num(uint64)"
 
 %type	<ident>
@@ -660,11 +678,11 @@ import (
 %precedence lowerThanKey
 %precedence key
 
-%left   join inner cross left right full
+%left   join inner cross left right full natural
 /* A dummy token to force the priority of TableRef production in a join. */
 %left   tableRefPriority
 %precedence lowerThanOn
-%precedence on
+%precedence on using
 %right  assignmentEq
 %left 	oror or
 %left 	xor
@@ -1373,6 +1391,93 @@ DefaultKwdOpt:
 	{}
 |	"DEFAULT"
 
+/******************************************************************
+ * Handler statement
+ * See https://dev.mysql.com/doc/refman/5.7/en/handler.html
+ ******************************************************************/
+HandlerStmt:
+	"HANDLER" TableName "OPEN"
+	{
+		$$ = &ast.HandlerStmt{Tp: ast.HandlerOpen, TableRefs: handlerTableRefs($2.(*ast.TableName), "")}
+	}
+|	"HANDLER" TableName "CLOSE"
+	{
+		$$ = &ast.HandlerStmt{Tp: ast.HandlerClose, TableRefs: handlerTableRefs($2.(*ast.TableName), "")}
+	}
+|	"HANDLER" TableName "READ" HandlerReadType WhereClauseOptional SelectStmtLimit
+	{
+		readTp := $4.(ast.HandlerReadType)
+		if readTp != ast.HandlerReadFirst && readTp != ast.HandlerReadNext {
+			yylex.Errorf("Only FIRST and NEXT are allowed to read a table without an index.")
+			return 1
+		}
+		x := &ast.HandlerStmt{Tp: ast.HandlerRead, TableRefs: handlerTableRefs($2.(*ast.TableName), ""), ReadTp: readTp}
+		if $5 != nil {
+			x.Where = $5.(ast.ExprNode)
+		}
+		if $6 != nil {
+			x.Limit = $6.(*ast.Limit)
+		}
+		$$ = x
+	}
+|	"HANDLER" TableName "READ" Identifier HandlerReadType WhereClauseOptional SelectStmtLimit
+	{
+		x := &ast.HandlerStmt{
+			Tp:		ast.HandlerRead,
+			TableRefs:	handlerTableRefs($2.(*ast.TableName), $4),
+			IndexName:	model.NewCIStr($4),
+			ReadTp:		$5.(ast.HandlerReadType),
+		}
+		if $6 != nil {
+			x.Where = $6.(ast.ExprNode)
+		}
+		if $7 != nil {
+			x.Limit = $7.(*ast.Limit)
+		}
+		$$ = x
+	}
+|	"HANDLER" TableName "READ" Identifier CompareOp '(' ExpressionList ')' WhereClauseOptional SelectStmtLimit
+	{
+		op := $5.(opcode.Op)
+		if op == opcode.NE || op == opcode.NullEQ {
+			yylex.Errorf("Only =, >=, >, <= and < are allowed to compare the index key.")
+			return 1
+		}
+		x := &ast.HandlerStmt{
+			Tp:		ast.HandlerRead,
+			TableRefs:	handlerTableRefs($2.(*ast.TableName), $4),
+			IndexName:	model.NewCIStr($4),
+			ReadTp:		ast.HandlerReadKey,
+			Op:		op,
+			Values:		$7.([]ast.ExprNode),
+		}
+		if $9 != nil {
+			x.Where = $9.(ast.ExprNode)
+		}
+		if $10 != nil {
+			x.Limit = $10.(*ast.Limit)
+		}
+		$$ = x
+	}
+
+HandlerReadType:
+	"FIRST"
+	{
+		$$ = ast.HandlerReadFirst
+	}
+|	"NEXT"
+	{
+		$$ = ast.HandlerReadNext
+	}
+|	"PREV"
+	{
+		$$ = ast.HandlerReadPrev
+	}
+|	"LAST"
+	{
+		$$ = ast.HandlerReadLast
+	}
+
 /******************************************************************
  * Do statement
  * See https://dev.mysql.com/doc/refman/5.7/en/do.html
@@ -1391,58 +1496,61 @@ DoStmt:
  *
  *******************************************************************/
 DeleteFromStmt:
-	"DELETE" LowPriorityOptional QuickOptional IgnoreOptional "FROM" TableName WhereClauseOptional OrderByOptional LimitClause
+	"DELETE" OptimizerHintsOpt LowPriorityOptional QuickOptional IgnoreOptional "FROM" TableName WhereClauseOptional OrderByOptional LimitClause
 	{
 		// Single Table
-		join := &ast.Join{Left: &ast.TableSource{Source: $6.(ast.ResultSetNode)}, Right: nil}
+		join := &ast.Join{Left: &ast.TableSource{Source: $7.(ast.ResultSetNode)}, Right: nil}
 		x := &ast.DeleteStmt{
 			TableRefs:	&ast.TableRefsClause{TableRefs: join},
-			LowPriority:	$2.(bool),
-			Quick:		$3.(bool),
-			Ignore:		$4.(bool),
-		}
-		if $7 != nil {
-			x.Where = $7.(ast.ExprNode)
+			Hints:		$2.([]*ast.OptimizerHint),
+			LowPriority:	$3.(bool),
+			Quick:		$4.(bool),
+			Ignore:		$5.(bool),
 		}
 		if $8 != nil {
-			x.Order = $8.(*ast.OrderByClause)
+			x.Where = $8.(ast.ExprNode)
 		}
 		if $9 != nil {
-			x.Limit = $9.(*ast.Limit)
+			x.Order = $9.(*ast.OrderByClause)
+		}
+		if $10 != nil {
+			x.Limit = $10.(*ast.Limit)
 		}
 
 		$$ = x
 	}
-|	"DELETE" LowPriorityOptional QuickOptional IgnoreOptional TableNameList "FROM" TableRefs WhereClauseOptional
+|	"DELETE" OptimizerHintsOpt LowPriorityOptional QuickOptional IgnoreOptional TableNameList "FROM" TableRefs WhereClauseOptional
 	{
 		// Multiple Table
 		x := &ast.DeleteStmt{
-			LowPriority:	$2.(bool),
-			Quick:		$3.(bool),
-			Ignore:		$4.(bool),
+			Hints:		$2.([]*ast.OptimizerHint),
+			LowPriority:	$3.(bool),
+			Quick:		$4.(bool),
+			Ignore:		$5.(bool),
 			IsMultiTable:	true,
 			BeforeFrom:	true,
-			Tables:		&ast.DeleteTableList{Tables: $5.([]*ast.TableName)},
-			TableRefs:	&ast.TableRefsClause{TableRefs: $7.(*ast.Join)},
+			Tables:		&ast.DeleteTableList{Tables: $6.([]*ast.TableName)},
+			TableRefs:	&ast.TableRefsClause{TableRefs: $8.(*ast.Join)},
 		}
-		if $8 != nil {
-			x.Where = $8.(ast.ExprNode)
+		if $9 != nil {
+			x.Where = $9.(ast.ExprNode)
 		}
 		$$ = x
 	}
-|	"DELETE" LowPriorityOptional QuickOptional IgnoreOptional "FROM" TableNameList "USING" TableRefs WhereClauseOptional
+|	"DELETE" OptimizerHintsOpt LowPriorityOptional QuickOptional IgnoreOptional "FROM" TableNameList "USING" TableRefs WhereClauseOptional
 	{
 		// Multiple Table
 		x := &ast.DeleteStmt{
-			LowPriority:	$2.(bool),
-			Quick:		$3.(bool),
-			Ignore:		$4.(bool),
+			Hints:		$2.([]*ast.OptimizerHint),
+			LowPriority:	$3.(bool),
+			Quick:		$4.(bool),
+			Ignore:		$5.(bool),
 			IsMultiTable:	true,
-			Tables:		&ast.DeleteTableList{Tables: $6.([]*ast.TableName)},
-			TableRefs:	&ast.TableRefsClause{TableRefs: $8.(*ast.Join)},
+			Tables:		&ast.DeleteTableList{Tables: $7.([]*ast.TableName)},
+			TableRefs:	&ast.TableRefsClause{TableRefs: $9.(*ast.Join)},
 		}
-		if $9 != nil {
-			x.Where = $9.(ast.ExprNode)
+		if $10 != nil {
+			x.Where = $10.(ast.ExprNode)
 		}
 		$$ = x
 	}
@@ -1827,6 +1935,36 @@ GroupByClause:
 	{
 		$$ = &ast.GroupByClause{Items: $3.([]*ast.ByItem)}
 	}
+|	"GROUP" "BY" "GROUPING" "SETS" '(' GroupingSetList ')'
+	{
+		var items []*ast.ByItem
+		var sets [][]int
+		for _, exprs := range $6.([][]ast.ExprNode) {
+			set := make([]int, 0, len(exprs))
+			for _, expr := range exprs {
+				set = append(set, len(items))
+				items = append(items, &ast.ByItem{Expr: expr})
+			}
+			sets = append(sets, set)
+		}
+		$$ = &ast.GroupByClause{Items: items, GroupingSets: sets}
+	}
+
+GroupingSetList:
+	GroupingSet
+	{
+		$$ = [][]ast.ExprNode{$1.([]ast.ExprNode)}
+	}
+|	GroupingSetList ',' GroupingSet
+	{
+		$$ = append($1.([][]ast.ExprNode), $3.([]ast.ExprNode))
+	}
+
+GroupingSet:
+	'(' ExpressionListOpt ')'
+	{
+		$$ = $2
+	}
 
 HavingClause:
 	{
@@ -1923,12 +2061,12 @@ Identifier:
 identifier | UnReservedKeyword | NotKeywordToken
 
 UnReservedKeyword:
- "ACTION" | "ASCII" | "AUTO_INCREMENT" | "AFTER" | "AVG" | "BEGIN" | "BIT" | "BOOL" | "BOOLEAN" | "BTREE" | "CHARSET" | "COLUMNS" | "COMMIT" | "COMPACT" | "COMPRESSED" | "DATE" | "DATETIME" | "DEALLOCATE" | "DO" | "DYNAMIC" | "END" | "ENGINE" | "ENGINES" | "ESCAPE" | "EXECUTE" | "FIELDS" | "FIRST" | "FIXED" | "FULL" | "HASH" | "LOCAL" | "NAMES" | "OFFSET" | "PASSWORD" %prec lowerThanEq | "PREPARE" | "QUICK" | "REDUNDANT" | "ROLLBACK" | "SESSION" | "SIGNED" | "START" | "STATUS" | "GLOBAL" | "TABLES" | "TEXT" | "TIME" | "TIMESTAMP" | "TRANSACTION" | "TRUNCATE" | "UNKNOWN" | "VALUE" | "WARNINGS" | "YEAR" | "MODE"  | "WEEK"  | "ANY" | "SOME" | "USER" | "IDENTIFIED" | "COLLATION" | "COMMENT" | "AVG_ROW_LENGTH" | "CONNECTION" | "CHECKSUM" | "COMPRESSION" | "KEY_BLOCK_SIZE" | "MAX_ROWS" | "MIN_ROWS" | "NATIONAL" | "ROW" | "ROW_FORMAT" | "QUARTER" | "GRANTS" | "TRIGGERS" | "DELAY_KEY_WRITE" | "ISOLATION" | "REPEATABLE" | "COMMITTED" | "UNCOMMITTED" | "ONLY" | "SERIALIZABLE" | "LEVEL" | "VARIABLES" | "SQL_CACHE" | "SQL_NO_CACHE" | "DISABLE"  | "ENABLE" | "REVERSE" | "SPACE" | "PRIVILEGES" | "NO" | "BINLOG"
+ "ACTION" | "ASCII" | "AUTO_INCREMENT" | "AFTER" | "AVG" | "BEGIN" | "BIT" | "BOOL" | "BOOLEAN" | "BTREE" | "CHARSET" | "COLUMNS" | "COMMIT" | "COMPACT" | "COMPRESSED" | "DATE" | "DATETIME" | "DEALLOCATE" | "DO" | "DYNAMIC" | "END" | "ENGINE" | "ENGINES" | "ESCAPE" | "EXECUTE" | "FIELDS" | "FIRST" | "FIXED" | "FULL" | "HASH" | "LOCAL" | "NAMES" | "OFFSET" | "PASSWORD" %prec lowerThanEq | "PREPARE" | "QUICK" | "REDUNDANT" | "ROLLBACK" | "SESSION" | "SIGNED" | "START" | "STATUS" | "GLOBAL" | "TABLES" | "TEXT" | "TIME" | "TIMESTAMP" | "TRANSACTION" | "TRUNCATE" | "UNKNOWN" | "VALUE" | "WARNINGS" | "YEAR" | "MODE"  | "WEEK"  | "ANY" | "SOME" | "USER" | "IDENTIFIED" | "COLLATION" | "COMMENT" | "AVG_ROW_LENGTH" | "CONNECTION" | "CHECKSUM" | "COMPRESSION" | "KEY_BLOCK_SIZE" | "MAX_ROWS" | "MIN_ROWS" | "NATIONAL" | "ROW" | "ROW_FORMAT" | "QUARTER" | "GRANTS" | "TRIGGERS" | "DELAY_KEY_WRITE" | "ISOLATION" | "REPEATABLE" | "COMMITTED" | "UNCOMMITTED" | "ONLY" | "SERIALIZABLE" | "SETS" | "LEVEL" | "VARIABLES" | "SQL_CACHE" | "SQL_NO_CACHE" | "DISABLE"  | "ENABLE" | "REVERSE" | "SPACE" | "PRIVILEGES" | "NO" | "BINLOG" | "HANDLER" | "OPEN" | "CLOSE" | "NEXT" | "PREV" | "LAST"
 
 NotKeywordToken:
 	"ABS" | "ADDDATE" | "ADMIN" | "COALESCE" | "CONCAT" | "CONCAT_WS" | "CONNECTION_ID" | "CUR_TIME"| "COUNT" | "DAY"
 |	"DATE_ADD" | "DATE_FORMAT" | "DATE_SUB" | "DAYNAME" | "DAYOFMONTH" | "DAYOFWEEK" | "DAYOFYEAR" | "FOUND_ROWS"
-|	"GROUP_CONCAT"| "GREATEST" | "HOUR" | "HEX" | "IFNULL" | "ISNULL" | "LAST_INSERT_ID" | "LCASE" | "LENGTH" | "LOCATE" | "LOWER" | "LTRIM"
+|	"GROUP_CONCAT"| "GROUPING" | "GREATEST" | "HOUR" | "HEX" | "IFNULL" | "ISNULL" | "LAST_INSERT_ID" | "LCASE" | "LENGTH" | "LOCATE" | "LOWER" | "LTRIM"
 |	"MAX" | "MICROSECOND" | "MIN" |	"MINUTE" | "NULLIF" | "MONTH" | "MONTHNAME" | "NOW" | "POW" | "POWER" | "RAND"
 |	"SECOND" | "SLEEP" | "SQL_CALC_FOUND_ROWS" | "SUBDATE" | "SUBSTRING" %prec lowerThanLeftParen | "SUBSTRING_INDEX"
 |	"SUM" | "TRIM" | "RTRIM" | "UCASE" | "UPPER" | "VERSION" | "WEEKDAY" | "WEEKOFYEAR" | "YEARWEEK" | "ROUND"
@@ -1941,15 +2079,16 @@ NotKeywordToken:
  *  TODO: support PARTITION
  **********************************************************************************/
 InsertIntoStmt:
-	"INSERT" Priority IgnoreOptional IntoOpt TableName InsertValues OnDuplicateKeyUpdate
+	"INSERT" OptimizerHintsOpt Priority IgnoreOptional IntoOpt TableName InsertValues OnDuplicateKeyUpdate
 	{
-		x := $6.(*ast.InsertStmt)
-		x.Priority = $2.(int)
+		x := $7.(*ast.InsertStmt)
+		x.Hints = $2.([]*ast.OptimizerHint)
+		x.Priority = $3.(int)
 		// Wraps many layers here so that it can be processed the same way as select statement.
-		ts := &ast.TableSource{Source: $5.(*ast.TableName)}
+		ts := &ast.TableSource{Source: $6.(*ast.TableName)}
 		x.Table = &ast.TableRefsClause{TableRefs: &ast.Join{Left: ts}}
-		if $7 != nil {
-			x.OnDuplicate = $7.([]*ast.Assignment)
+		if $8 != nil {
+			x.OnDuplicate = $8.([]*ast.Assignment)
 		}
 		$$ = x
 	}
@@ -2819,6 +2958,10 @@ FunctionCallAgg:
 	{
 		$$ = &ast.AggregateFuncExpr{F: $1, Args: $4.([]ast.ExprNode), Distinct: $3.(bool)}
 	}
+|	"GROUPING" '(' Expression ')'
+	{
+		$$ = &ast.AggregateFuncExpr{F: $1, Args: []ast.ExprNode{$3.(ast.ExprNode)}}
+	}
 |	"MAX" '(' DistinctOpt Expression ')'
 	{
 		$$ = &ast.AggregateFuncExpr{F: $1, Args: []ast.ExprNode{$4.(ast.ExprNode)}, Distinct: $3.(bool)}
@@ -3185,7 +3328,9 @@ SelectStmt:
 	"SELECT" SelectStmtOpts SelectStmtFieldList SelectStmtLimit SelectLockOpt
 	{
 		st := &ast.SelectStmt {
-			Distinct:      $2.(bool),
+			Distinct:      $2.(*selectStmtOpts).distinct,
+			ResultSize:    $2.(*selectStmtOpts).resultSize,
+			Hints:         $2.(*selectStmtOpts).hints,
 			Fields:        $3.(*ast.FieldList),
 			LockTp:	       $5.(ast.SelectLockType),
 		}
@@ -3213,7 +3358,9 @@ SelectStmt:
 |	"SELECT" SelectStmtOpts SelectStmtFieldList FromDual WhereClauseOptional SelectStmtLimit SelectLockOpt
 	{
 		st := &ast.SelectStmt {
-			Distinct:      $2.(bool),
+			Distinct:      $2.(*selectStmtOpts).distinct,
+			ResultSize:    $2.(*selectStmtOpts).resultSize,
+			Hints:         $2.(*selectStmtOpts).hints,
 			Fields:        $3.(*ast.FieldList),
 			LockTp:	       $7.(ast.SelectLockType),
 		}
@@ -3235,7 +3382,9 @@ SelectStmt:
 	SelectStmtLimit SelectLockOpt
 	{
 		st := &ast.SelectStmt{
-			Distinct:	$2.(bool),
+			Distinct:	$2.(*selectStmtOpts).distinct,
+			ResultSize:	$2.(*selectStmtOpts).resultSize,
+			Hints:		$2.(*selectStmtOpts).hints,
 			Fields:		$3.(*ast.FieldList),
 			From:		$5.(*ast.TableRefsClause),
 			LockTp:		$11.(ast.SelectLockType),
@@ -3455,7 +3604,22 @@ JoinTable:
 		on := &ast.OnCondition{Expr: $7.(ast.ExprNode)}
 		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $5.(ast.ResultSetNode), Tp: $2.(ast.JoinType), On: on}
 	}
-	/* Support Using */
+|	TableRef CrossOpt TableRef "USING" '(' ColumnNameList ')'
+	{
+		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $3.(ast.ResultSetNode), Tp: ast.CrossJoin, Using: $6.([]*ast.ColumnName)}
+	}
+|	TableRef JoinType OuterOpt "JOIN" TableRef "USING" '(' ColumnNameList ')'
+	{
+		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $5.(ast.ResultSetNode), Tp: $2.(ast.JoinType), Using: $8.([]*ast.ColumnName)}
+	}
+|	TableRef "NATURAL" "JOIN" TableRef
+	{
+		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $4.(ast.ResultSetNode), Tp: ast.CrossJoin, NaturalJoin: true}
+	}
+|	TableRef "NATURAL" JoinType OuterOpt "JOIN" TableRef
+	{
+		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $6.(ast.ResultSetNode), Tp: $3.(ast.JoinType), NaturalJoin: true}
+	}
 
 JoinType:
 	"LEFT"
@@ -3521,10 +3685,36 @@ SelectStmtDistinct:
 	}
 
 SelectStmtOpts:
-	SelectStmtDistinct SelectStmtSQLCache SelectStmtCalcFoundRows
+	OptimizerHintsOpt SelectStmtDistinct SelectStmtResultSize SelectStmtSQLCache SelectStmtCalcFoundRows
 	{
 		// TODO: return calc_found_rows opt and support more other options
-		$$ = $1
+		$$ = &selectStmtOpts{
+			hints:      $1.([]*ast.OptimizerHint),
+			distinct:   $2.(bool),
+			resultSize: $3.(ast.SelectResultSize),
+		}
+	}
+
+OptimizerHintsOpt:
+	{
+		$$ = []*ast.OptimizerHint(nil)
+	}
+|	hintComment
+	{
+		$$ = parseOptimizerHints($1)
+	}
+
+SelectStmtResultSize:
+	{
+		$$ = ast.SelectResultSizeDefault
+	}
+|	"SQL_SMALL_RESULT"
+	{
+		$$ = ast.SelectResultSizeSmall
+	}
+|	"SQL_BIG_RESULT"
+	{
+		$$ = ast.SelectResultSizeBig
 	}
 
 SelectStmtCalcFoundRows:
@@ -3857,6 +4047,14 @@ AdminStmt:
 			Tables: $4.([]*ast.TableName),
 		}
 	}
+|	"ADMIN" "CHECK" "INDEX" TableName Identifier
+	{
+		$$ = &ast.AdminStmt{
+			Tp:	ast.AdminCheckIndex,
+			Tables: []*ast.TableName{$4.(*ast.TableName)},
+			Index:	$5,
+		}
+	}
 
 /****************************Show Statement*******************************/
 ShowStmt:
@@ -4078,6 +4276,7 @@ Statement:
 |	DropIndexStmt
 |	DropTableStmt
 |	GrantStmt
+|	HandlerStmt
 |	InsertIntoStmt
 |	PreparedStmt
 |	RollbackStmt
@@ -4738,39 +4937,41 @@ StringName:
  * See https://dev.mysql.com/doc/refman/5.7/en/update.html
  ***********************************************************************************/
 UpdateStmt:
-	"UPDATE" LowPriorityOptional IgnoreOptional TableRef "SET" AssignmentList WhereClauseOptional OrderByOptional LimitClause
+	"UPDATE" OptimizerHintsOpt LowPriorityOptional IgnoreOptional TableRef "SET" AssignmentList WhereClauseOptional OrderByOptional LimitClause
 	{
 		var refs *ast.Join
-		if x, ok := $4.(*ast.Join); ok {
+		if x, ok := $5.(*ast.Join); ok {
 			refs = x
 		} else {
-			refs = &ast.Join{Left: $4.(ast.ResultSetNode)}
+			refs = &ast.Join{Left: $5.(ast.ResultSetNode)}
 		}
 		st := &ast.UpdateStmt{
-			LowPriority:	$2.(bool),
+			Hints:		$2.([]*ast.OptimizerHint),
+			LowPriority:	$3.(bool),
 			TableRefs:	&ast.TableRefsClause{TableRefs: refs},
-			List:		$6.([]*ast.Assignment),
-		}
-		if $7 != nil {
-			st.Where = $7.(ast.ExprNode)
+			List:		$7.([]*ast.Assignment),
 		}
 		if $8 != nil {
-			st.Order = $8.(*ast.OrderByClause)
+			st.Where = $8.(ast.ExprNode)
 		}
 		if $9 != nil {
-			st.Limit = $9.(*ast.Limit)
+			st.Order = $9.(*ast.OrderByClause)
+		}
+		if $10 != nil {
+			st.Limit = $10.(*ast.Limit)
 		}
 		$$ = st
 	}
-|	"UPDATE" LowPriorityOptional IgnoreOptional TableRefs "SET" AssignmentList WhereClauseOptional
+|	"UPDATE" OptimizerHintsOpt LowPriorityOptional IgnoreOptional TableRefs "SET" AssignmentList WhereClauseOptional
 	{
 		st := &ast.UpdateStmt{
-			LowPriority:	$2.(bool),
-			TableRefs:	&ast.TableRefsClause{TableRefs: $4.(*ast.Join)},
-			List:		$6.([]*ast.Assignment),
+			Hints:		$2.([]*ast.OptimizerHint),
+			LowPriority:	$3.(bool),
+			TableRefs:	&ast.TableRefsClause{TableRefs: $5.(*ast.Join)},
+			List:		$7.([]*ast.Assignment),
 		}
-		if $7 != nil {
-			st.Where = $7.(ast.ExprNode)
+		if $8 != nil {
+			st.Where = $8.(ast.ExprNode)
 		}
 		$$ = st
 	}
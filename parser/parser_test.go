@@ -49,7 +49,7 @@ func (s *testParserSuite) TestSimple(c *C) {
 		"curtime", "variables", "dayname", "version", "btree", "hash", "row_format", "dynamic", "fixed", "compressed",
 		"compact", "redundant", "sql_no_cache sql_no_cache", "sql_cache sql_cache", "action", "round",
 		"enable", "disable", "reverse", "space", "privileges", "get_lock", "release_lock", "sleep", "no", "greatest",
-		"binlog", "hex",
+		"binlog", "hex", "grouping", "sets", "handler", "open", "close", "next", "prev", "last",
 	}
 	for _, kw := range unreservedKws {
 		src := fmt.Sprintf("SELECT %s FROM tbl;", kw)
@@ -247,6 +247,12 @@ func (s *testParserSuite) TestDMLStmt(c *C) {
 		{"SELECT * from t for update", true},
 		{"SELECT * from t lock in share mode", true},
 
+		// Select result size
+		{"SELECT SQL_SMALL_RESULT a, count(*) from t group by a", true},
+		{"SELECT DISTINCT SQL_BIG_RESULT SQL_NO_CACHE a from t", true},
+		{"SELECT SQL_BIG_RESULT SQL_SMALL_RESULT a from t", false},
+		{"SELECT SQL_CACHE SQL_BIG_RESULT a from t", false},
+
 		// For alter table
 		{"ALTER TABLE t ADD COLUMN a SMALLINT UNSIGNED", true},
 		{"ALTER TABLE t ADD COLUMN a SMALLINT UNSIGNED FIRST", true},
@@ -259,6 +265,14 @@ func (s *testParserSuite) TestDMLStmt(c *C) {
 		{"select * from t1 join t2 left join t3 on t2.id = t3.id", true},
 		{"select * from t1 right join t2 on t1.id = t2.id left join t3 on t3.id = t2.id", true},
 		{"select * from t1 right join t2 on t1.id = t2.id left join t3", false},
+		{"select * from t1 join t2 using (id)", true},
+		{"select * from t1 join t2 using (id, name) join t3 using (id)", true},
+		{"select * from t1 left join t2 using (id)", true},
+		{"select * from t1 right outer join t2 using (id)", true},
+		{"select * from t1 join t2 using ()", false},
+		{"select * from t1 natural join t2", true},
+		{"select * from t1 natural left join t2 natural right outer join t3", true},
+		{"select * from t1 natural join t2 on t1.id = t2.id", false},
 
 		// For show full columns
 		{"show columns in t;", true},
@@ -267,6 +281,9 @@ func (s *testParserSuite) TestDMLStmt(c *C) {
 		// For admin
 		{"admin show ddl;", true},
 		{"admin check table t1, t2;", true},
+		{"admin check index t1 idx;", true},
+		{"admin check index t1;", false},
+		{"admin check index t1, t2 idx;", false},
 
 		// For set names
 		{"set names utf8", true},
@@ -351,6 +368,26 @@ func (s *testParserSuite) TestDMLStmt(c *C) {
 
 		{`ANALYZE TABLE t`, true},
 
+		// For grouping sets
+		{`select a, b, count(*) from t group by grouping sets ((a), (b))`, true},
+		{`select a, b, grouping(a), grouping(b) from t group by grouping sets ((a, b), (a), ())`, true},
+		{`select a from t group by grouping sets (a)`, false},
+		{`select grouping(a, b) from t group by grouping sets ((a), (b))`, false},
+
+		// For handler
+		{"handler t open", true},
+		{"handler t close", true},
+		{"handler t read first", true},
+		{"handler t read next where a > 1 limit 2", true},
+		{"handler t read last", false},
+		{"handler t read idx next", true},
+		{"handler t read idx prev limit 1, 2", true},
+		{"handler t read idx = (1)", true},
+		{"handler t read idx >= (1, 'a') where b > 1 limit 3", true},
+		{"handler t read idx != (1)", false},
+		{"handler t read first first", true},
+		{"handler t read next = (1)", true},
+
 		// For Binlog stmt
 		{`BINLOG '
 BxSFVw8JAAAA8QAAAPUAAAAAAAQANS41LjQ0LU1hcmlhREItbG9nAAAAAAAAAAAAAAAAAAAAAAAA
@@ -983,6 +1020,66 @@ func (s *testParserSuite) TestIndexHint(c *C) {
 	s.RunTest(c, table)
 }
 
+func (s *testParserSuite) TestOptimizerHints(c *C) {
+	defer testleak.AfterTest(c)()
+	table := []testCase{
+		{`select /*+ MAX_EXECUTION_TIME(1000) */ * from t`, true},
+		{`select /*+ max_execution_time(1000) */ distinct c from t`, true},
+		{`select /*+ */ c from t`, true},
+		{`select c /*+ MAX_EXECUTION_TIME(1000) */ from t`, true},
+		{`select (select /*+ MAX_EXECUTION_TIME(1000) */ 1) from t`, true},
+		{`insert /*+ MAX_EXECUTION_TIME(1000) */ into t values (1)`, true},
+		{`update /*+ MAX_EXECUTION_TIME(1000) */ t set c = 1`, true},
+		{`delete /*+ MAX_EXECUTION_TIME(1000) */ from t`, true},
+		{`/*+ MAX_EXECUTION_TIME(1000) */ select c from t`, true},
+	}
+	s.RunTest(c, table)
+
+	hintTable := []struct {
+		src   string
+		names []string
+		args  [][]string
+	}{
+		{`select /*+ MAX_EXECUTION_TIME(1000) */ * from t`, []string{"max_execution_time"}, [][]string{{"1000"}}},
+		{`select /*+ MAX_EXECUTION_TIME( 10 ) , foo bar(a, b) */ * from t`, []string{"max_execution_time", "foo", "bar"}, [][]string{{"10"}, nil, {"a", "b"}}},
+		{`select /*+ MAX_EXECUTION_TIME(10 */ * from t`, nil, nil},
+		{`select /*+ */ * from t`, nil, nil},
+		// Hints only follow the first keyword of a statement.
+		{`/*+ MAX_EXECUTION_TIME(1000) */ select * from t`, nil, nil},
+	}
+	parser := New()
+	for _, t := range hintTable {
+		stmt, err := parser.ParseOneStmt(t.src, "", "")
+		c.Assert(err, IsNil, Commentf("source %v", t.src))
+		hints := stmt.(*ast.SelectStmt).Hints
+		c.Assert(hints, HasLen, len(t.names), Commentf("source %v", t.src))
+		for i, hint := range hints {
+			c.Assert(hint.Name.L, Equals, t.names[i])
+			c.Assert(hint.Args, DeepEquals, t.args[i])
+		}
+	}
+
+	for _, src := range []string{
+		`insert /*+ MAX_EXECUTION_TIME(1000) */ into t values (1)`,
+		`update /*+ MAX_EXECUTION_TIME(1000) */ t set c = 1`,
+		`delete /*+ MAX_EXECUTION_TIME(1000) */ from t`,
+	} {
+		stmt, err := parser.ParseOneStmt(src, "", "")
+		c.Assert(err, IsNil)
+		var hints []*ast.OptimizerHint
+		switch x := stmt.(type) {
+		case *ast.InsertStmt:
+			hints = x.Hints
+		case *ast.UpdateStmt:
+			hints = x.Hints
+		case *ast.DeleteStmt:
+			hints = x.Hints
+		}
+		c.Assert(hints, HasLen, 1, Commentf("source %v", src))
+		c.Assert(hints[0].Name.L, Equals, "max_execution_time")
+	}
+}
+
 func (s *testParserSuite) TestEscape(c *C) {
 	defer testleak.AfterTest(c)()
 	table := []testCase{
@@ -22,6 +22,7 @@ import (
 
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/terror"
 )
@@ -194,3 +195,60 @@ func toBit(l yyLexer, lval *yySymType, str string) int {
 	lval.item = b
 	return bitLit
 }
+
+// handlerTableRefs returns the table references of a HANDLER statement with the table, which is read through
+// the index if the index name isn't empty.
+func handlerTableRefs(tn *ast.TableName, indexName string) *ast.TableRefsClause {
+	if indexName != "" {
+		tn.IndexHints = append(tn.IndexHints, &ast.IndexHint{
+			IndexNames: []model.CIStr{model.NewCIStr(indexName)},
+			HintType:   ast.HintForce,
+			HintScope:  ast.HintForScan,
+		})
+	}
+	return &ast.TableRefsClause{TableRefs: &ast.Join{Left: &ast.TableSource{Source: tn}}}
+}
+
+// selectStmtOpts is the options following SELECT in a select statement.
+type selectStmtOpts struct {
+	hints      []*ast.OptimizerHint
+	distinct   bool
+	resultSize ast.SelectResultSize
+}
+
+// parseOptimizerHints parses the hints in the comment like /*+ NAME(arg, ...) NAME ... */. The hints are
+// separated by spaces or commas. The malformed hint and the ones after it are ignored, like MySQL does.
+func parseOptimizerHints(comment string) []*ast.OptimizerHint {
+	text := strings.TrimSuffix(strings.TrimPrefix(comment, "/*+"), "*/")
+	var hints []*ast.OptimizerHint
+	for {
+		text = strings.TrimLeft(text, " \t\r\n,")
+		if text == "" {
+			return hints
+		}
+		end := strings.IndexFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		if end == -1 {
+			end = len(text)
+		}
+		if end == 0 {
+			return hints
+		}
+		hint := &ast.OptimizerHint{Name: model.NewCIStr(text[:end])}
+		text = strings.TrimLeft(text[end:], " \t\r\n")
+		if strings.HasPrefix(text, "(") {
+			end = strings.IndexByte(text, ')')
+			if end == -1 {
+				return hints
+			}
+			for _, arg := range strings.Split(text[1:end], ",") {
+				if arg = strings.TrimSpace(arg); arg != "" {
+					hint.Args = append(hint.Args, arg)
+				}
+			}
+			text = text[end+1:]
+		}
+		hints = append(hints, hint)
+	}
+}
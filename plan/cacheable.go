@@ -0,0 +1,94 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import "github.com/pingcap/tidb/expression"
+
+// Cacheable checks if the physical plan of a prepared statement can be reused by its later executions. The
+// parameters and the system variables are read when the statement is built and folded into the plan as constants,
+// e.g. into the ranges of c = @@auto_increment_increment or the condition of b = ?, so the plan isn't cacheable if
+// any of its expressions contains one. The offset and the count of Limit are always literals, which can't be
+// parameters. Distinct, Expand and Cache don't have expressions of their own, but depend on their children, which
+// are checked like the inner plan of Apply.
+func Cacheable(p Plan) bool {
+	var exprs []expression.Expression
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		exprs = x.AccessCondition
+	case *PhysicalIndexScan:
+		exprs = x.AccessCondition
+	case *Selection:
+		exprs = x.Conditions
+	case *Projection:
+		exprs = x.Exprs
+	case *Aggregation:
+		exprs = x.GroupByItems
+		for _, agg := range x.AggFuncs {
+			exprs = append(exprs, agg.GetArgs()...)
+		}
+	case *NewSort:
+		for _, item := range x.ByItems {
+			exprs = append(exprs, item.Expr)
+		}
+	case *PhysicalHashJoin:
+		exprs = joinConditions(x.EqualConditions, x.LeftConditions, x.RightConditions, x.OtherConditions)
+	case *PhysicalHashSemiJoin:
+		exprs = joinConditions(x.EqualConditions, x.LeftConditions, x.RightConditions, x.OtherConditions)
+	case *PhysicalApply:
+		if x.Checker != nil {
+			exprs = []expression.Expression{x.Checker.Condition}
+		}
+		if !Cacheable(x.InnerPlan) {
+			return false
+		}
+	case *Distinct, *Expand, *PhysicalCache:
+		return Cacheable(x.GetChildByIndex(0))
+	}
+	if hasParameterized(exprs) {
+		return false
+	}
+	for _, child := range p.GetChildren() {
+		if !Cacheable(child) {
+			return false
+		}
+	}
+	return true
+}
+
+func joinConditions(eqConds []*expression.ScalarFunction, conds ...[]expression.Expression) []expression.Expression {
+	var exprs []expression.Expression
+	for _, cond := range eqConds {
+		exprs = append(exprs, cond)
+	}
+	for _, c := range conds {
+		exprs = append(exprs, c...)
+	}
+	return exprs
+}
+
+func hasParameterized(exprs []expression.Expression) bool {
+	for _, expr := range exprs {
+		switch x := expr.(type) {
+		case *expression.Constant:
+			if x.Parameterized {
+				return true
+			}
+		case *expression.ScalarFunction:
+			if hasParameterized(x.Args) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -146,6 +146,30 @@ func (p *Aggregation) PruneColumnsAndResolveIndices(parentUsedCols []*expression
 	return append(childOuterUsedCols, outerUsedCols...), nil
 }
 
+// PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
+// The columns appended by expand are always kept, and only the child's columns are pruned.
+func (p *Expand) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Column) ([]*expression.Column, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	extraCols := p.schema[len(child.GetSchema()):]
+	var childUsedCols []*expression.Column
+	for _, col := range parentUsedCols {
+		if child.GetSchema().GetIndex(col) != -1 {
+			childUsedCols = append(childUsedCols, col)
+		}
+	}
+	childUsedCols = append(childUsedCols, p.GroupingCols...)
+	outer, err := child.PruneColumnsAndResolveIndices(childUsedCols)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i, col := range p.GroupingCols {
+		p.GroupingCols[i] = child.GetSchema().RetrieveColumn(col)
+	}
+	p.schema = append(append(expression.Schema{}, child.GetSchema()...), extraCols...)
+	p.schema.InitIndices()
+	return outer, nil
+}
+
 // PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
 func (p *NewSort) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Column) ([]*expression.Column, error) {
 	child := p.GetChildByIndex(0).(LogicalPlan)
@@ -167,6 +191,15 @@ func (p *NewSort) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Col
 	return append(childOuterUsedCols, outerUsedCols...), nil
 }
 
+// PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
+// Distinct compares the whole rows, so all the columns of the child are used whatever the parent uses.
+func (p *Distinct) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Column) ([]*expression.Column, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	outer, err := child.PruneColumnsAndResolveIndices(child.GetSchema())
+	p.SetSchema(child.GetSchema())
+	return outer, errors.Trace(err)
+}
+
 // PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
 func (p *NewUnion) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Column) ([]*expression.Column, error) {
 	var outerUsedCols []*expression.Column
@@ -239,6 +272,20 @@ func (p *Insert) PruneColumnsAndResolveIndices(_ []*expression.Column) ([]*expre
 	return child.PruneColumnsAndResolveIndices(child.GetSchema())
 }
 
+// PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
+// All the expressions of DO statement must be evaluated, though their results are unused.
+func (p *Do) PruneColumnsAndResolveIndices(_ []*expression.Column) ([]*expression.Column, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	return child.PruneColumnsAndResolveIndices(child.GetSchema())
+}
+
+// PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
+// All the columns of the tables are written back, though only the assigned ones are changed.
+func (p *NewUpdate) PruneColumnsAndResolveIndices(_ []*expression.Column) ([]*expression.Column, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	return child.PruneColumnsAndResolveIndices(child.GetSchema())
+}
+
 // PruneColumnsAndResolveIndices implements LogicalPlan PruneColumnsAndResolveIndices interface.
 func (p *Join) PruneColumnsAndResolveIndices(parentUsedCols []*expression.Column) ([]*expression.Column, error) {
 	var outerUsedCols []*expression.Column
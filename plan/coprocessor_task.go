@@ -0,0 +1,132 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/ngaut/log"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/tablecodec"
+)
+
+// RegionEstimator supplies the numbers of regions that the tables and the indices are stored in, e.g. from
+// the region cache of the store.
+type RegionEstimator interface {
+	// RegionCount returns the number of regions that the rows of the table, or the entries of the index if
+	// indexID isn't 0, are stored in. The second return value is false if the count is unknown.
+	RegionCount(tableID, indexID int64) (int, bool)
+}
+
+// RegionCounter is implemented by the stores that know the regions the keys are stored in, e.g. from their
+// region caches.
+type RegionCounter interface {
+	// RegionCount returns the number of regions that the keys in [startKey, endKey) are stored in.
+	RegionCount(startKey, endKey kv.Key) (int, error)
+}
+
+// NewRegionEstimator returns the region estimator that counts the regions of the keys of the tables and the
+// indices in the store.
+func NewRegionEstimator(counter RegionCounter) RegionEstimator {
+	return &storeRegionEstimator{counter: counter}
+}
+
+type storeRegionEstimator struct {
+	counter RegionCounter
+}
+
+// RegionCount implements RegionEstimator RegionCount interface.
+func (e *storeRegionEstimator) RegionCount(tableID, indexID int64) (int, bool) {
+	prefix := tablecodec.GenTableRecordPrefix(tableID)
+	if indexID != 0 {
+		prefix = tablecodec.EncodeTableIndexPrefix(tableID, indexID)
+	}
+	n, err := e.counter.RegionCount(prefix, prefix.PrefixNext())
+	if err != nil {
+		log.Warnf("[plan] count the regions of table %d index %d failed: %v", tableID, indexID, err)
+		return 0, false
+	}
+	return n, true
+}
+
+// regionEstimatorKeyType is a dummy type to avoid naming collision in context.
+type regionEstimatorKeyType int
+
+// String defines a Stringer function for debugging and pretty printing.
+func (k regionEstimatorKeyType) String() string {
+	return "region_estimator"
+}
+
+const regionEstimatorKey regionEstimatorKeyType = 0
+
+// BindRegionEstimator binds the region estimator consulted when estimating the coprocessor tasks of the plans
+// in the context.
+func BindRegionEstimator(ctx context.Context, estimator RegionEstimator) {
+	ctx.SetValue(regionEstimatorKey, estimator)
+}
+
+// CoprocessorTaskEstimate estimates the number of coprocessor requests that the plan sends, summed over its
+// scans. The inner plan of an apply is counted once, though it's executed for every outer row.
+// If no region estimator is bound to the context, every table and index is regarded as stored in one region.
+func CoprocessorTaskEstimate(ctx context.Context, p Plan) int {
+	estimator, _ := ctx.Value(regionEstimatorKey).(RegionEstimator)
+	return coprocessorTaskEstimate(estimator, p)
+}
+
+func coprocessorTaskEstimate(estimator RegionEstimator, p Plan) int {
+	tasks := 0
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		points := 0
+		for _, rg := range x.Ranges {
+			if rg.LowVal == rg.HighVal {
+				points++
+			}
+		}
+		tasks = scanTaskEstimate(estimator, x.Table.ID, 0, len(x.Ranges), points)
+	case *PhysicalIndexScan:
+		points := 0
+		for _, rg := range x.Ranges {
+			if rg.IsPoint() {
+				points++
+			}
+		}
+		tasks = scanTaskEstimate(estimator, x.Table.ID, x.Index.ID, len(x.Ranges), points)
+		if x.DoubleRead && tasks > 0 {
+			// The rows are read by their handles, which may be in any region of the table.
+			tasks += scanTaskEstimate(estimator, x.Table.ID, 0, 1, 0)
+		}
+	case *PhysicalApply:
+		tasks = coprocessorTaskEstimate(estimator, x.InnerPlan)
+	}
+	for _, child := range p.GetChildren() {
+		tasks += coprocessorTaskEstimate(estimator, child)
+	}
+	return tasks
+}
+
+// scanTaskEstimate estimates the number of requests of a scan on the ranges. A point range is in one region, and
+// the other ranges are assumed to span all the regions. The ranges in the same region are sent in one request,
+// so there are no more requests than the regions.
+func scanTaskEstimate(estimator RegionEstimator, tableID, indexID int64, ranges, points int) int {
+	regions := 1
+	if estimator != nil {
+		if n, ok := estimator.RegionCount(tableID, indexID); ok && n > 0 {
+			regions = n
+		}
+	}
+	if points < ranges || points > regions {
+		return regions
+	}
+	return points
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// DescribePlanDOT describes the physical plan as a Graphviz DOT digraph, which is easier to read than ToString
+// for a large plan. Each operator is a node labeled by its type and estimated row count, with the edges
+// from the parents to the children. The inner plan of an apply is connected by an edge labeled "inner".
+func DescribePlanDOT(p Plan) (string, error) {
+	if p == nil {
+		return "", errors.New("can't describe an empty plan")
+	}
+	d := &dotDescriber{}
+	d.buf.WriteString("digraph Plan {\n")
+	d.describe(p)
+	d.buf.WriteString("}\n")
+	return d.buf.String(), nil
+}
+
+type dotDescriber struct {
+	buf   bytes.Buffer
+	nodes int
+}
+
+// describe writes the node of p and the ones of its descendants, and returns the name of p's node.
+func (d *dotDescriber) describe(p Plan) string {
+	name := fmt.Sprintf("n%d", d.nodes)
+	d.nodes++
+	fmt.Fprintf(&d.buf, "\t%s [label=\"%s\\nrows: %.0f\"];\n", name, dotEscaper.Replace(dotLabel(p)), p.RowCount())
+	for _, child := range p.GetChildren() {
+		fmt.Fprintf(&d.buf, "\t%s -> %s;\n", name, d.describe(child))
+	}
+	if apply, ok := p.(*PhysicalApply); ok {
+		fmt.Fprintf(&d.buf, "\t%s -> %s [label=\"inner\"];\n", name, d.describe(apply.InnerPlan))
+	}
+	return name
+}
+
+// dotEscaper escapes the quotes and the backslashes in a quoted DOT string, e.g. in a quoted table name.
+var dotEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+
+func dotLabel(p Plan) string {
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		return fmt.Sprintf("TableScan(%s)", x.Table.Name.L)
+	case *PhysicalIndexScan:
+		return fmt.Sprintf("IndexScan(%s.%s)", x.Table.Name.L, x.Index.Name.L)
+	case *PhysicalHashJoin:
+		return "HashJoin"
+	case *PhysicalHashSemiJoin:
+		return "HashSemiJoin"
+	case *PhysicalApply:
+		return "Apply"
+	case *PhysicalCache:
+		return "Cache"
+	case *Aggregation:
+		if x.Streamed {
+			return "StreamAgg"
+		}
+		if x.StreamedDistinct {
+			return "StreamDistinctAgg"
+		}
+		if x.GroupSkip {
+			return "GroupSkipAgg"
+		}
+		return "HashAgg"
+	case *Distinct:
+		if x.Streamed {
+			return "StreamDistinct"
+		}
+		return "HashDistinct"
+	}
+	// The other operators are named by their types, which prefix their ids.
+	if id := p.GetID(); id != "" {
+		return strings.SplitN(id, "_", 2)[0]
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", p), "*plan.")
+}
@@ -79,13 +79,14 @@ func getRowArg(e expression.Expression, idx int) expression.Expression {
 	return &expression.Constant{Value: d, RetType: c.GetType()}
 }
 
-// constructBinaryOpFunctions converts (a0,a1,a2) op (b0,b1,b2) to (a0 op b0) and (a1 op b1) and (a2 op b2).
+// constructBinaryOpFunctions converts (a0,a1,a2) op (b0,b1,b2) to (a0 op b0) and (a1 op b1) and (a2 op b2),
+// except that (a0,a1,a2) != (b0,b1,b2) is converted to (a0 != b0) or (a1 != b1) or (a2 != b2).
 func constructBinaryOpFunction(l expression.Expression, r expression.Expression, op string) (expression.Expression, error) {
 	lLen, rLen := getRowLen(l), getRowLen(r)
 	if lLen == 1 && rLen == 1 {
 		return expression.NewFunction(op, types.NewFieldType(mysql.TypeTiny), l, r)
 	} else if rLen != lLen {
-		return nil, errors.Errorf("Operand should contain %d column(s)", lLen)
+		return nil, ErrSameColumns.Gen("Operand should contain %d column(s)", lLen)
 	}
 	funcs := make([]expression.Expression, lLen)
 	for i := 0; i < lLen; i++ {
@@ -95,6 +96,9 @@ func constructBinaryOpFunction(l expression.Expression, r expression.Expression,
 			return nil, errors.Trace(err)
 		}
 	}
+	if op == ast.NE {
+		return expression.ComposeDNFCondition(funcs), nil
+	}
 	return expression.ComposeCNFCondition(funcs), nil
 }
 
@@ -135,7 +139,14 @@ func (er *expressionRewriter) Enter(inNode ast.Node) (ast.Node, bool) {
 	case *ast.CompareSubqueryExpr:
 		return er.handleCompareSubquery(v)
 	case *ast.ExistsSubqueryExpr:
-		return er.handleExistSubquery(v)
+		return er.handleExistSubquery(v, false)
+	case *ast.UnaryOperationExpr:
+		// not exists (subq) is built as an anti semi join, so it's negated here instead of in Leave.
+		if exists, ok := v.V.(*ast.ExistsSubqueryExpr); ok && v.Op == opcode.Not {
+			er.handleExistSubquery(exists, true)
+			return inNode, true
+		}
+		er.asScalar = true
 	case *ast.PatternInExpr:
 		if v.Sel != nil {
 			return er.handleInSubquery(v)
@@ -171,7 +182,7 @@ func (er *expressionRewriter) handleCompareSubquery(v *ast.CompareSubqueryExpr)
 		return v, true
 	}
 	if getRowLen(lexpr) != len(np.GetSchema()) {
-		er.err = errors.Errorf("Operand should contain %d column(s)", getRowLen(lexpr))
+		er.err = ErrSameColumns.Gen("Operand should contain %d column(s)", getRowLen(lexpr))
 		return v, true
 	}
 	var checkCondition expression.Expression
@@ -190,7 +201,7 @@ func (er *expressionRewriter) handleCompareSubquery(v *ast.CompareSubqueryExpr)
 		}
 	}
 	switch v.Op {
-	// Only EQ, NE and NullEQ can be composed with and.
+	// Only EQ, NE and NullEQ can be composed of the comparisons of the columns.
 	case opcode.EQ, opcode.NE, opcode.NullEQ:
 		checkCondition, er.err = constructBinaryOpFunction(lexpr, rexpr, opcode.Ops[v.Op])
 		if er.err != nil {
@@ -215,7 +226,8 @@ func (er *expressionRewriter) handleCompareSubquery(v *ast.CompareSubqueryExpr)
 	return v, true
 }
 
-func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr) (ast.Node, bool) {
+// handleExistSubquery rewrites exists (subq), or not exists (subq) if not is true.
+func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr, not bool) (ast.Node, bool) {
 	subq, ok := v.Sel.(*ast.SubqueryExpr)
 	if !ok {
 		er.err = errors.Errorf("Unknown exists type %T.", v.Sel)
@@ -226,48 +238,53 @@ func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr) (as
 		return v, true
 	}
 	np = er.b.buildExists(np)
-	if np.IsCorrelated() {
-		if sel, ok := np.GetChildByIndex(0).(*Selection); ok && !sel.GetChildByIndex(0).IsCorrelated() {
-			er.p = er.b.buildSemiJoin(er.p, sel.GetChildByIndex(0).(LogicalPlan), sel.Conditions, er.asScalar, false)
+	if np.IsCorrelated() || er.b.inSetValue {
+		// Without an equal correlation, e.g. "exists (select * from s where s.a < t.a)", the semi join has no join key
+		// and has to compare every pair, so it's built as an apply that evaluates the filtered inner plan for each row.
+		if sel, ok := np.GetChildByIndex(0).(*Selection); ok && !sel.GetChildByIndex(0).IsCorrelated() &&
+			hasEqualCorrelation(sel.Conditions, er.p) {
+			er.p = er.b.buildSemiJoin(er.p, sel.GetChildByIndex(0).(LogicalPlan), sel.Conditions, er.asScalar, not, false)
 			if !er.asScalar {
 				return v, true
 			}
-		} else {
-			// Can't be built as semi-join
-			er.p = er.b.buildApply(er.p, np, outerSchema, nil)
+			if er.p.IsCorrelated() {
+				er.correlated = true
+			}
+			er.ctxStack = append(er.ctxStack, er.p.GetSchema()[len(er.p.GetSchema())-1])
+			return v, true
 		}
+		// Can't be built as semi-join
+		er.p = er.b.buildApply(er.p, np, outerSchema, nil)
 		if er.p.IsCorrelated() {
 			er.correlated = true
 		}
-		er.ctxStack = append(er.ctxStack, er.p.GetSchema()[len(er.p.GetSchema())-1])
+		er.pushExistResult(er.p.GetSchema()[len(er.p.GetSchema())-1], not)
 	} else {
-		_, np, er.err = np.PredicatePushDown(nil)
-		if er.err != nil {
-			return v, true
-		}
-		_, err := np.PruneColumnsAndResolveIndices(np.GetSchema())
+		phyPlan, err := physicalOptimize(er.b.ctx, np)
 		if err != nil {
 			er.err = errors.Trace(err)
 			return v, true
 		}
-		_, res, _, err := np.convert2PhysicalPlan(nil)
-		if err != nil {
-			er.err = errors.Trace(err)
-			return v, true
-		}
-		phyPlan := res.p.PushLimit(nil)
 		d, err := EvalSubquery(phyPlan, er.b.is, er.b.ctx)
 		if err != nil {
 			er.err = errors.Trace(err)
 			return v, true
 		}
-		er.ctxStack = append(er.ctxStack, &expression.Constant{
+		er.pushExistResult(&expression.Constant{
 			Value:   d[0],
-			RetType: np.GetSchema()[0].GetType()})
+			RetType: np.GetSchema()[0].GetType()}, not)
 	}
 	return v, true
 }
 
+// pushExistResult pushes the result of exists, which is negated for not exists.
+func (er *expressionRewriter) pushExistResult(result expression.Expression, not bool) {
+	if not {
+		result, er.err = expression.NewFunction(ast.UnaryNot, types.NewFieldType(mysql.TypeTiny), result)
+	}
+	er.ctxStack = append(er.ctxStack, result)
+}
+
 func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node, bool) {
 	asScalar := er.asScalar
 	er.asScalar = true
@@ -286,7 +303,12 @@ func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node,
 		return v, true
 	}
 	if getRowLen(lexpr) != len(np.GetSchema()) {
-		er.err = errors.Errorf("Operand should contain %d column(s)", getRowLen(lexpr))
+		er.err = ErrSameColumns.Gen("Operand should contain %d column(s)", getRowLen(lexpr))
+		return v, true
+	}
+	// a in (select 5) is rewritten as a = 5, which can be used to build ranges.
+	if row := constantRow(np); row != nil {
+		er.ctxStack[len(er.ctxStack)-1], er.err = er.inSingleValue(lexpr, row, v.Not)
 		return v, true
 	}
 	var rexpr expression.Expression
@@ -307,7 +329,11 @@ func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node,
 	// a not in (subq) will be rewrited as a != all(subq).
 	checkCondition, err := constructBinaryOpFunction(lexpr, rexpr, ast.EQ)
 	if !np.IsCorrelated() {
-		er.p = er.b.buildSemiJoin(er.p, np, splitCNFItems(checkCondition), asScalar, v.Not)
+		er.p = er.b.buildSemiJoin(er.p, np, splitCNFItems(checkCondition), asScalar, v.Not, true)
+		// The inner plan can be shared only if no condition will be pushed into it.
+		if join := er.p.(*Join); len(join.RightConditions) == 0 {
+			join.sharedInner = er.b.shareSubquery(np)
+		}
 		if asScalar {
 			col := er.p.GetSchema()[len(er.p.GetSchema())-1]
 			er.ctxStack[len(er.ctxStack)-1] = col
@@ -333,13 +359,54 @@ func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node,
 
 }
 
+// constantRow returns the only row of the subquery if it selects constants without FROM, like SELECT 5.
+func constantRow(p LogicalPlan) []expression.Expression {
+	proj, ok := p.(*Projection)
+	if !ok {
+		return nil
+	}
+	if _, ok = proj.GetChildByIndex(0).(*NewTableDual); !ok {
+		return nil
+	}
+	for _, expr := range proj.Exprs {
+		if _, ok = expr.(*expression.Constant); !ok {
+			return nil
+		}
+	}
+	return proj.Exprs
+}
+
+// inSingleValue converts a in (x) to a = x, and a not in (x) to a != x. The value may be a row, like
+// (a, b) in ((1, 2)).
+func (er *expressionRewriter) inSingleValue(lexpr expression.Expression, value []expression.Expression, not bool) (expression.Expression, error) {
+	rexpr := value[0]
+	if len(value) > 1 {
+		var err error
+		rexpr, err = expression.NewFunction(ast.RowFunc, nil, value...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	op := ast.EQ
+	if not {
+		op = ast.NE
+	}
+	cond, err := constructBinaryOpFunction(lexpr, rexpr, op)
+	return cond, errors.Trace(err)
+}
+
 func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (ast.Node, bool) {
 	np, outerSchema := er.buildSubquery(v)
 	if er.err != nil {
 		return v, true
 	}
-	np = er.b.buildMaxOneRow(np)
-	if np.IsCorrelated() {
+	if np.IsCorrelated() || er.b.inSetValue {
+		if p := er.b.decorrelateAggSubquery(er.p, np); p != nil {
+			er.p = p
+			er.ctxStack = append(er.ctxStack, er.p.GetSchema()[len(er.p.GetSchema())-1])
+			return v, true
+		}
+		np = er.b.buildMaxOneRow(np)
 		er.p = er.b.buildApply(er.p, np, outerSchema, nil)
 		if er.p.IsCorrelated() {
 			er.correlated = true
@@ -360,21 +427,16 @@ func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (ast.Nod
 		}
 		return v, true
 	}
-	_, np, er.err = np.PredicatePushDown(nil)
-	if er.err != nil {
-		return v, true
-	}
-	_, err := np.PruneColumnsAndResolveIndices(np.GetSchema())
-	if err != nil {
-		er.err = errors.Trace(err)
-		return v, true
+	// A non-correlated subquery which always returns exactly one row, e.g. a scalar aggregation,
+	// doesn't need to be checked by MaxOneRow.
+	if !alwaysReturnOneRow(np) {
+		np = er.b.buildMaxOneRow(np)
 	}
-	_, res, _, err := np.convert2PhysicalPlan(nil)
+	phyPlan, err := physicalOptimize(er.b.ctx, np)
 	if err != nil {
 		er.err = errors.Trace(err)
 		return v, true
 	}
-	phyPlan := res.p.PushLimit(nil)
 	d, err := EvalSubquery(phyPlan, er.b.is, er.b.ctx)
 	if err != nil {
 		er.err = errors.Trace(err)
@@ -415,7 +477,7 @@ func (er *expressionRewriter) Leave(inNode ast.Node) (retNode ast.Node, ok bool)
 		value := &expression.Constant{Value: v.Datum, RetType: v.Type}
 		er.ctxStack = append(er.ctxStack, value)
 	case *ast.ParamMarkerExpr:
-		value := &expression.Constant{Value: v.Datum, RetType: v.Type}
+		value := &expression.Constant{Value: v.Datum, RetType: v.Type, Parameterized: true}
 		er.ctxStack = append(er.ctxStack, value)
 	case *ast.VariableExpr:
 		er.rewriteVariable(v)
@@ -424,7 +486,9 @@ func (er *expressionRewriter) Leave(inNode ast.Node) (retNode ast.Node, ok bool)
 	case *ast.ColumnName:
 		er.toColumn(v)
 	case *ast.UnaryOperationExpr:
-		er.unaryOpToExpression(v)
+		if _, ok := v.V.(*ast.ExistsSubqueryExpr); !ok || v.Op != opcode.Not {
+			er.unaryOpToExpression(v)
+		}
 	case *ast.BinaryOperationExpr:
 		er.binaryOpToExpression(v)
 	case *ast.BetweenExpr:
@@ -475,7 +539,7 @@ func (er *expressionRewriter) rewriteVariable(v *ast.VariableExpr) {
 				er.ctxStack[stkLen-1])
 			return
 		}
-		if _, ok := sessionVars.Users[name]; ok {
+		if _, ok := sessionVars.Users[name]; ok || er.b.inSetValue {
 			f, err := expression.NewFunction(ast.GetVar,
 				// TODO: Here is wrong, the sessionVars should store a name -> Datum map. Will fix it later.
 				types.NewFieldType(mysql.TypeString),
@@ -486,8 +550,8 @@ func (er *expressionRewriter) rewriteVariable(v *ast.VariableExpr) {
 			}
 			er.ctxStack = append(er.ctxStack, f)
 		} else {
-			// select null user vars is permitted.
-			er.ctxStack = append(er.ctxStack, &expression.Constant{RetType: types.NewFieldType(mysql.TypeNull)})
+			// select null user vars is permitted. The variable may be set before the next execution.
+			er.ctxStack = append(er.ctxStack, &expression.Constant{RetType: types.NewFieldType(mysql.TypeNull), Parameterized: true})
 		}
 		return
 	}
@@ -509,7 +573,9 @@ func (er *expressionRewriter) rewriteVariable(v *ast.VariableExpr) {
 			er.err = errors.Trace(err)
 			return
 		}
-		er.ctxStack = append(er.ctxStack, datumToConstant(types.NewDatum(value), mysql.TypeString))
+		con := datumToConstant(types.NewDatum(value), mysql.TypeString)
+		con.Parameterized = true
+		er.ctxStack = append(er.ctxStack, con)
 		return
 	}
 	d := sessionVars.GetSystemVar(name)
@@ -531,7 +597,10 @@ func (er *expressionRewriter) rewriteVariable(v *ast.VariableExpr) {
 			}
 		}
 	}
-	er.ctxStack = append(er.ctxStack, datumToConstant(d, mysql.TypeString))
+	// The value is read when the statement is executed, it's folded into the plan like a parameter.
+	con := datumToConstant(d, mysql.TypeString)
+	con.Parameterized = true
+	er.ctxStack = append(er.ctxStack, con)
 	return
 }
 
@@ -565,6 +634,13 @@ func (er *expressionRewriter) binaryOpToExpression(v *ast.BinaryOperationExpr) {
 	case opcode.EQ, opcode.NE, opcode.NullEQ:
 		function, er.err = constructBinaryOpFunction(er.ctxStack[stkLen-2], er.ctxStack[stkLen-1],
 			opcode.Ops[v.Op])
+	case opcode.LT, opcode.LE, opcode.GT, opcode.GE:
+		// The rows are compared as row datums, which must have the same number of columns.
+		if lLen := getRowLen(er.ctxStack[stkLen-2]); lLen != getRowLen(er.ctxStack[stkLen-1]) {
+			er.err = ErrSameColumns.Gen("Operand should contain %d column(s)", lLen)
+			return
+		}
+		function, er.err = expression.NewFunction(opcode.Ops[v.Op], v.Type, er.ctxStack[stkLen-2:]...)
 	default:
 		function, er.err = expression.NewFunction(opcode.Ops[v.Op], v.Type, er.ctxStack[stkLen-2:]...)
 	}
@@ -631,6 +707,12 @@ func (er *expressionRewriter) inToExpression(v *ast.PatternInExpr) {
 	}
 	stkLen := len(er.ctxStack)
 	lLen := len(v.List)
+	if lLen == 1 {
+		var function expression.Expression
+		function, er.err = er.inSingleValue(er.ctxStack[stkLen-2], er.ctxStack[stkLen-1:], v.Not)
+		er.ctxStack = append(er.ctxStack[:stkLen-2], function)
+		return
+	}
 	function := er.notToExpression(v.Not, ast.In, v.Type, er.ctxStack[stkLen-lLen-1:stkLen]...)
 	er.ctxStack = er.ctxStack[:stkLen-lLen-1]
 	er.ctxStack = append(er.ctxStack, function)
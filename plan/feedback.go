@@ -0,0 +1,95 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/expression"
+)
+
+// CardinalityFeedback supplies the factors that correct the estimated row counts, e.g. the factors learned
+// from the actual row counts of the executed plans by a background process.
+type CardinalityFeedback interface {
+	// CorrectionFactor returns the factor that the estimated row count of the table filtered by the predicates
+	// with the signature is multiplied by. The second return value is false if there is no feedback.
+	CorrectionFactor(tableID int64, signature string) (float64, bool)
+}
+
+// feedbackKeyType is a dummy type to avoid naming collision in context.
+type feedbackKeyType int
+
+// String defines a Stringer function for debugging and pretty printing.
+func (k feedbackKeyType) String() string {
+	return "cardinality_feedback"
+}
+
+const feedbackKey feedbackKeyType = 0
+
+// BindCardinalityFeedback binds the cardinality feedback store, which is consulted when estimating the row counts
+// of the statements planned in the context.
+func BindCardinalityFeedback(ctx context.Context, feedback CardinalityFeedback) {
+	ctx.SetValue(feedbackKey, feedback)
+}
+
+// getCardinalityFeedback gets the cardinality feedback store bound to the context. Nil means no feedback.
+func getCardinalityFeedback(ctx context.Context) CardinalityFeedback {
+	v, ok := ctx.Value(feedbackKey).(CardinalityFeedback)
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// predicateSignature returns the shape of the predicates, in which the constants are replaced by "?",
+// so the predicates that differ only in constants have the same signature, e.g. >(c,?,) and =(d,?,).
+func predicateSignature(conditions []expression.Expression) string {
+	shapes := make([]string, 0, len(conditions))
+	for _, cond := range conditions {
+		shapes = append(shapes, expressionShape(cond))
+	}
+	sort.Strings(shapes)
+	return strings.Join(shapes, " and ")
+}
+
+func expressionShape(expr expression.Expression) string {
+	switch x := expr.(type) {
+	case *expression.Column:
+		return x.ColName.L
+	case *expression.ScalarFunction:
+		result := x.FuncName.L + "("
+		for _, arg := range x.Args {
+			result += expressionShape(arg) + ","
+		}
+		return result + ")"
+	}
+	return "?"
+}
+
+// correctRowCount applies the correction factor of the feedback to the row count estimated for the table filtered
+// by all the conditions pushed down to the data source. The conditions are identified by their signature as a
+// whole, since the factor of a subset of them doesn't tell how many rows the others filter out.
+func (p *DataSource) correctRowCount(rowCount uint64) uint64 {
+	sel, ok := p.GetParentByIndex(0).(*Selection)
+	if p.feedback == nil || !ok || len(sel.Conditions) == 0 {
+		return rowCount
+	}
+	factor, ok := p.feedback.CorrectionFactor(p.Table.ID, predicateSignature(sel.Conditions))
+	if !ok || factor <= 0 {
+		return rowCount
+	}
+	return uint64(float64(rowCount) * factor)
+}
@@ -0,0 +1,231 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// HandlerName returns the name of the handler that HANDLER OPEN opens on the table, which is the lower case table name.
+func HandlerName(h *ast.HandlerStmt) string {
+	return h.TableRefs.TableRefs.Left.(*ast.TableSource).Source.(*ast.TableName).Name.L
+}
+
+// buildHandler builds the plan of a HANDLER statement. HANDLER READ is planned as a scan of the index in the
+// direction it reads from the starting key, which is forced by the parser, followed by a sort on the index
+// columns that the scan satisfies and a limit, which is 1 by default. The rows of a table whose primary key is
+// the handle are read in the order of the primary key if no index is specified. HANDLER OPEN and CLOSE only
+// check the table, the handler is opened and closed by the executor.
+// NEXT and PREV start from the key of the last row read from the same index, which the HandlerRead plan keeps in the
+// handler with the handle of the row, or from the first and the last rows if no row has been read from the index yet.
+// The rows of a non-unique index with an equal key are read in the order of their handles, so HandlerRead skips the
+// rows up to the last one by comparing (key, handle) with the last key and applies the limit itself.
+func (b *planBuilder) buildHandler(h *ast.HandlerStmt) Plan {
+	if h.Tp != ast.HandlerRead {
+		return b.buildSimple(h)
+	}
+	if !UseNewPlanner {
+		b.err = ErrUnsupportedType.Gen("HANDLER READ is only supported by the new planner")
+		return nil
+	}
+	handler, ok := variable.GetSessionVars(b.ctx).Handlers[HandlerName(h)]
+	if !ok {
+		b.err = ErrUnknownTable.Gen("Unknown table '%s' in HANDLER", HandlerName(h))
+		return nil
+	}
+	p := b.buildResultSetNode(h.TableRefs.TableRefs)
+	if b.err != nil {
+		return nil
+	}
+	ds := p.(*DataSource)
+	indexName := h.IndexName
+	if indexName.L == "" && ds.Table.PKIsHandle {
+		indexName = model.NewCIStr("primary")
+	}
+	var keyCols []*expression.Column
+	if indexName.L != "" {
+		keyCols = b.handlerKeyColumns(ds, indexName)
+		if b.err != nil {
+			return nil
+		}
+	}
+	var (
+		keyConds []expression.Expression
+		lastKey  []types.Datum
+	)
+	switch h.ReadTp {
+	case ast.HandlerReadKey:
+		values := b.handlerKeyValues(p, keyCols, h.Values)
+		if b.err != nil {
+			return nil
+		}
+		keyConds = handlerKeyConditions(keyCols, h.Op, values)
+	case ast.HandlerReadNext, ast.HandlerReadPrev:
+		if len(keyCols) == 0 || handler.IndexName != indexName.L || handler.LastKey == nil {
+			break
+		}
+		values := make([]expression.Expression, 0, len(keyCols))
+		for i, col := range keyCols {
+			values = append(values, &expression.Constant{Value: handler.LastKey[i], RetType: col.RetType})
+		}
+		op := opcode.GE
+		if h.ReadTp == ast.HandlerReadPrev {
+			op = opcode.LE
+		}
+		keyConds = handlerKeyConditions(keyCols, op, values)
+		lastKey = handler.LastKey
+	}
+	if h.Where != nil {
+		p = b.buildSelection(p, h.Where, nil)
+		if b.err != nil {
+			return nil
+		}
+	}
+	if len(keyConds) > 0 {
+		if sel, ok := p.(*Selection); ok {
+			sel.Conditions = append(keyConds, sel.Conditions...)
+		} else {
+			sel = &Selection{baseLogicalPlan: newBaseLogicalPlan(Sel, b.allocator), Conditions: keyConds}
+			sel.initID()
+			sel.SetSchema(p.GetSchema().DeepCopy())
+			addChild(sel, p)
+			p = sel
+		}
+	}
+	if len(keyCols) > 0 {
+		desc := h.ReadTp == ast.HandlerReadPrev || h.ReadTp == ast.HandlerReadLast ||
+			(h.ReadTp == ast.HandlerReadKey && (h.Op == opcode.LT || h.Op == opcode.LE))
+		sort := &NewSort{baseLogicalPlan: newBaseLogicalPlan(Srt, b.allocator)}
+		sort.initID()
+		for _, col := range keyCols {
+			sort.ByItems = append(sort.ByItems, &ByItems{Expr: col, Desc: desc})
+		}
+		addChild(sort, p)
+		sort.SetSchema(p.GetSchema().DeepCopy())
+		p = sort
+		b.handlerRead = &HandlerRead{Handler: handler, IndexName: indexName.L, KeyColumns: keyCols}
+	}
+	limit := h.Limit
+	if limit == nil {
+		limit = &ast.Limit{Count: 1}
+	}
+	if lastKey != nil {
+		b.handlerRead.LastKey = lastKey
+		b.handlerRead.Desc = h.ReadTp == ast.HandlerReadPrev
+		b.handlerRead.Offset = limit.Offset
+		b.handlerRead.Count = limit.Count
+		return p
+	}
+	return b.buildNewLimit(p, limit)
+}
+
+// handlerKeyColumns returns the columns of the index to read in the data source. The PRIMARY index of a table
+// whose primary key is the handle is the primary key column.
+func (b *planBuilder) handlerKeyColumns(ds *DataSource, indexName model.CIStr) []*expression.Column {
+	var names []model.CIStr
+	if idx := findIndexByName(ds.Table.Indices, indexName); idx != nil {
+		for _, idxCol := range idx.Columns {
+			names = append(names, idxCol.Name)
+		}
+	} else if indexName.L == "primary" && ds.Table.PKIsHandle {
+		for _, col := range ds.Table.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				names = append(names, col.Name)
+			}
+		}
+	} else {
+		b.err = ErrKeyDoesNotExist.Gen("Key '%s' doesn't exist in table '%s'", indexName, ds.Table.Name)
+		return nil
+	}
+	cols := make([]*expression.Column, 0, len(names))
+	for _, name := range names {
+		for _, col := range ds.GetSchema() {
+			if col.ColName.L == name.L {
+				cols = append(cols, col)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+// handlerKeyValues rewrites the values that the index key is compared with.
+func (b *planBuilder) handlerKeyValues(p LogicalPlan, keyCols []*expression.Column, values []ast.ExprNode) []expression.Expression {
+	if len(values) > len(keyCols) {
+		b.err = ErrTooManyKeyParts.Gen("Too many key parts specified; max %d parts allowed", len(keyCols))
+		return nil
+	}
+	consts := make([]expression.Expression, 0, len(values))
+	for _, val := range values {
+		expr, _, _, err := b.rewrite(val, p, nil, true)
+		if err != nil {
+			b.err = err
+			return nil
+		}
+		consts = append(consts, expr)
+	}
+	return consts
+}
+
+// handlerKeyConditions returns the conditions that the index key is compared with the values by the operator,
+// which compares the key parts in order, e.g. (c, d) >= (1, 2) is c > 1 or c = 1 and d >= 2. The range of
+// the first key part, like c >= 1, is added, so the scan starts from the key.
+func handlerKeyConditions(keyCols []*expression.Column, op opcode.Op, consts []expression.Expression) []expression.Expression {
+	newFunction := func(op opcode.Op, col, val expression.Expression) expression.Expression {
+		f, _ := expression.NewFunction(opcode.Ops[op], types.NewFieldType(mysql.TypeTiny), col.DeepCopy(), val.DeepCopy())
+		return f
+	}
+	if op == opcode.EQ {
+		conds := make([]expression.Expression, 0, len(consts))
+		for i, val := range consts {
+			conds = append(conds, newFunction(opcode.EQ, keyCols[i], val))
+		}
+		return conds
+	}
+	strictOp, firstOp := op, op
+	switch op {
+	case opcode.GE:
+		strictOp = opcode.GT
+	case opcode.GT:
+		firstOp = opcode.GE
+	case opcode.LE:
+		strictOp = opcode.LT
+	case opcode.LT:
+		firstOp = opcode.LE
+	}
+	var cond expression.Expression
+	for i := len(consts) - 1; i >= 0; i-- {
+		partOp := strictOp
+		if i == len(consts)-1 {
+			partOp = op
+		}
+		part := newFunction(partOp, keyCols[i], consts[i])
+		if cond != nil {
+			eq := newFunction(opcode.EQ, keyCols[i], consts[i])
+			and, _ := expression.NewFunction(ast.AndAnd, types.NewFieldType(mysql.TypeTiny), eq, cond)
+			part, _ = expression.NewFunction(ast.OrOr, types.NewFieldType(mysql.TypeTiny), part, and)
+		}
+		cond = part
+	}
+	if len(consts) == 1 {
+		return []expression.Expression{cond}
+	}
+	return []expression.Expression{newFunction(firstOp, keyCols[0], consts[0]), cond}
+}
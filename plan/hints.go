@@ -0,0 +1,76 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/sessionctx/variable"
+)
+
+const hintMaxExecutionTime = "max_execution_time"
+
+// maxExecutionTime returns the maximum execution time in milliseconds hinted by MAX_EXECUTION_TIME(N) of the
+// statement, 0 means no limit. Like MySQL, the hint is only honored by top-level SELECT statements, so the
+// hints of subqueries aren't looked at, and a warning is appended for the hint that is ignored, e.g. the one
+// of a DML statement, the one whose argument isn't an integer, or the duplicated one.
+func maxExecutionTime(ctx context.Context, node ast.Node) uint64 {
+	var hints []*ast.OptimizerHint
+	selectOnly := false
+	switch x := node.(type) {
+	case *ast.SelectStmt:
+		hints = x.Hints
+	case *ast.InsertStmt:
+		hints, selectOnly = x.Hints, true
+	case *ast.UpdateStmt:
+		hints, selectOnly = x.Hints, true
+	case *ast.DeleteStmt:
+		hints, selectOnly = x.Hints, true
+	}
+	sessionVars := variable.GetSessionVars(ctx)
+	warn := func(err error) {
+		if sessionVars != nil {
+			sessionVars.AppendWarning(err)
+		}
+	}
+	var ms uint64
+	found := false
+	for _, hint := range hints {
+		if hint.Name.L != hintMaxExecutionTime {
+			continue
+		}
+		if selectOnly {
+			warn(ErrUnsupportedHint.Gen("MAX_EXECUTION_TIME hint is supported by top-level standalone SELECT statements only"))
+			continue
+		}
+		if found {
+			warn(ErrConflictingHint.Gen("Hint MAX_EXECUTION_TIME(%s) is ignored as conflicting/duplicated", strings.Join(hint.Args, ", ")))
+			continue
+		}
+		if len(hint.Args) != 1 {
+			warn(ErrInvalidHint.Gen("Optimizer hint syntax error at MAX_EXECUTION_TIME(%s)", strings.Join(hint.Args, ", ")))
+			continue
+		}
+		val, err := strconv.ParseUint(hint.Args[0], 10, 64)
+		if err != nil {
+			warn(ErrInvalidHint.Gen("Optimizer hint syntax error at MAX_EXECUTION_TIME(%s)", hint.Args[0]))
+			continue
+		}
+		ms, found = val, true
+	}
+	return ms
+}
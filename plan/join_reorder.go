@@ -22,17 +22,21 @@ import (
 )
 
 // tryToGetJoinGroup tries to fetch a whole join group, which all joins is cartesian join.
-func tryToGetJoinGroup(j *Join) ([]LogicalPlan, bool) {
+// It also returns the conditions of these joins, which only refer to one side.
+func tryToGetJoinGroup(j *Join) ([]LogicalPlan, []expression.Expression, bool) {
 	if j.reordered || !j.cartesianJoin {
-		return nil, false
+		return nil, nil, false
 	}
 	lChild := j.GetChildByIndex(0).(LogicalPlan)
 	rChild := j.GetChildByIndex(1).(LogicalPlan)
+	conds := make([]expression.Expression, 0, len(j.LeftConditions)+len(j.RightConditions))
+	conds = append(conds, j.LeftConditions...)
+	conds = append(conds, j.RightConditions...)
 	if nj, ok := lChild.(*Join); ok {
-		plans, valid := tryToGetJoinGroup(nj)
-		return append(plans, rChild), valid
+		plans, childConds, valid := tryToGetJoinGroup(nj)
+		return append(plans, rChild), append(childConds, conds...), valid
 	}
-	return []LogicalPlan{lChild, rChild}, true
+	return []LogicalPlan{lChild, rChild}, conds, true
 }
 
 func findColumnIndexByGroup(groups []LogicalPlan, col *expression.Column) int {
@@ -15,19 +15,26 @@ package plan
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/meta"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/plan/statistics"
+	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/util/types"
 )
 
 // UseNewPlanner means if use the new planner.
 var UseNewPlanner = true
 
+// idAllocator allocates the ids of the plans in one statement.
+// The ids only depend on the order in which the plans are constructed, so plan building and all the
+// optimizing rules must visit children and expressions in a fixed order (e.g. never by iterating a map),
+// which keeps the ids of the same query stable across runs.
 type idAllocator struct {
 	id int
 }
@@ -40,10 +47,12 @@ func (a *idAllocator) allocID() string {
 func (b *planBuilder) buildAggregation(p LogicalPlan, aggFuncList []*ast.AggregateFuncExpr, gby []expression.Expression, correlated bool) LogicalPlan {
 	agg := &Aggregation{
 		AggFuncs:        make([]expression.AggregationFunction, 0, len(aggFuncList)),
+		groupLimit:      b.hashAggGroupLimit(),
 		baseLogicalPlan: newBaseLogicalPlan(Agg, b.allocator)}
 	agg.initID()
 	agg.correlated = p.IsCorrelated() || correlated
 	addChild(agg, p)
+	expand, _ := p.(*Expand)
 	schema := make([]*expression.Column, 0, len(aggFuncList))
 	for i, aggFunc := range aggFuncList {
 		var newArgList []expression.Expression
@@ -57,7 +66,16 @@ func (b *planBuilder) buildAggregation(p LogicalPlan, aggFuncList []*ast.Aggrega
 			agg.correlated = correlated || agg.correlated
 			newArgList = append(newArgList, newArg)
 		}
-		agg.AggFuncs = append(agg.AggFuncs, expression.NewAggFunction(aggFunc.F, newArgList, aggFunc.Distinct))
+		name := aggFunc.F
+		if expand != nil {
+			name, newArgList = b.substituteGroupingCols(expand, name, newArgList)
+		} else if strings.ToLower(name) == ast.AggFuncGrouping {
+			b.err = ErrInvalidGroupFuncUse.Gen("GROUPING function is only allowed with GROUPING SETS")
+		}
+		if b.err != nil {
+			return nil
+		}
+		agg.AggFuncs = append(agg.AggFuncs, expression.NewAggFunction(name, newArgList, aggFunc.Distinct))
 		schema = append(schema, &expression.Column{FromID: agg.id,
 			ColName:     model.NewCIStr(fmt.Sprintf("%s_col_%d", agg.id, i)),
 			Position:    i,
@@ -69,6 +87,94 @@ func (b *planBuilder) buildAggregation(p LogicalPlan, aggFuncList []*ast.Aggrega
 	return agg
 }
 
+// buildExpand builds the expand plan for the grouping sets, and returns the group by items of the aggregation,
+// which are the padded grouping columns and the grouping set id.
+func (b *planBuilder) buildExpand(p LogicalPlan, gby []expression.Expression, groupingSets [][]int) (LogicalPlan, []expression.Expression) {
+	expand := &Expand{baseLogicalPlan: newBaseLogicalPlan(Expd, b.allocator)}
+	expand.initID()
+	expand.correlated = p.IsCorrelated()
+	addChild(expand, p)
+	for _, offsets := range groupingSets {
+		set := make([]int, 0, len(offsets))
+		for _, offset := range offsets {
+			col, ok := gby[offset].(*expression.Column)
+			if !ok || col.Correlated {
+				b.err = ErrUnsupportedType.Gen("Only columns are supported in GROUPING SETS")
+				return nil, nil
+			}
+			idx := -1
+			for i, gcol := range expand.GroupingCols {
+				if gcol.Equal(col) {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				idx = len(expand.GroupingCols)
+				expand.GroupingCols = append(expand.GroupingCols, col)
+			}
+			set = append(set, idx)
+		}
+		expand.GroupingSets = append(expand.GroupingSets, set)
+	}
+	schema := append(expression.Schema{}, p.GetSchema()...)
+	gbyCols := make([]expression.Expression, 0, len(expand.GroupingCols)+1)
+	for i, col := range expand.GroupingCols {
+		tp := *col.RetType
+		tp.Flag &^= mysql.NotNullFlag
+		padded := &expression.Column{FromID: expand.id,
+			ColName:  model.NewCIStr(fmt.Sprintf("%s_gcol_%d", expand.id, i)),
+			Position: i,
+			RetType:  &tp}
+		schema = append(schema, padded)
+		gbyCols = append(gbyCols, padded)
+	}
+	n := len(expand.GroupingCols)
+	for i := 0; i <= n; i++ {
+		name := fmt.Sprintf("%s_flag_%d", expand.id, i)
+		if i == n {
+			name = fmt.Sprintf("%s_gid", expand.id)
+		}
+		schema = append(schema, &expression.Column{FromID: expand.id,
+			ColName:  model.NewCIStr(name),
+			Position: n + i,
+			RetType:  types.NewFieldType(mysql.TypeLonglong)})
+	}
+	gbyCols = append(gbyCols, schema[len(schema)-1])
+	expand.SetSchema(schema)
+	return expand, gbyCols
+}
+
+// substituteGroupingCols makes the aggregation over expand read the padded grouping columns. The first row
+// of a grouping column is replaced by its padded column, and GROUPING(col) by the first row of its flag.
+func (b *planBuilder) substituteGroupingCols(expand *Expand, name string, args []expression.Expression) (string, []expression.Expression) {
+	name = strings.ToLower(name)
+	if name != ast.AggFuncFirstRow && name != ast.AggFuncGrouping {
+		return name, args
+	}
+	idx := -1
+	if col, ok := args[0].(*expression.Column); ok {
+		for i, gcol := range expand.GroupingCols {
+			if gcol.Equal(col) {
+				idx = i
+				break
+			}
+		}
+	}
+	childLen := len(expand.GetSchema()) - 2*len(expand.GroupingCols) - 1
+	switch {
+	case idx == -1 && name == ast.AggFuncGrouping:
+		b.err = ErrInvalidGroupFuncUse.Gen("Argument of GROUPING function is not in GROUP BY")
+	case idx == -1:
+	case name == ast.AggFuncFirstRow:
+		args = []expression.Expression{expand.GetSchema()[childLen+idx]}
+	default:
+		args = []expression.Expression{expand.GetSchema()[childLen+len(expand.GroupingCols)+idx]}
+		name = ast.AggFuncFirstRow
+	}
+	return name, args
+}
+
 func (b *planBuilder) buildResultSetNode(node ast.ResultSetNode) LogicalPlan {
 	switch x := node.(type) {
 	case *ast.Join:
@@ -168,6 +274,49 @@ func extractOnCondition(conditions []expression.Expression, left LogicalPlan, ri
 	return
 }
 
+// simplifyJoinConditions removes the join conditions that are always true, e.g. a constant true condition
+// or a <=> a. A condition like a = a is only false when a is null, so it's rewritten to not(isnull(a)),
+// or removed if a can't be null.
+func simplifyJoinConditions(conditions []expression.Expression) ([]expression.Expression, error) {
+	result := make([]expression.Expression, 0, len(conditions))
+	for _, cond := range conditions {
+		switch x := cond.(type) {
+		case *expression.Constant:
+			if !x.Value.IsNull() {
+				val, err := x.Value.ToBool()
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if val == 1 {
+					continue
+				}
+			}
+		case *expression.ScalarFunction:
+			if x.FuncName.L != ast.EQ && x.FuncName.L != ast.NullEQ {
+				break
+			}
+			lCol, lOK := x.Args[0].(*expression.Column)
+			rCol, rOK := x.Args[1].(*expression.Column)
+			if !lOK || !rOK || !lCol.Equal(rCol) {
+				break
+			}
+			if x.FuncName.L == ast.NullEQ || (lCol.RetType != nil && mysql.HasNotNullFlag(lCol.RetType.Flag)) {
+				continue
+			}
+			isNull, err := expression.NewFunction(ast.IsNull, types.NewFieldType(mysql.TypeTiny), lCol)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			cond, err = expression.NewFunction(ast.UnaryNot, types.NewFieldType(mysql.TypeTiny), isNull)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		result = append(result, cond)
+	}
+	return result, nil
+}
+
 // CNF means conjunctive normal form, e.g. a and b and c.
 func splitCNFItems(onExpr expression.Expression) []expression.Expression {
 	switch v := onExpr.(type) {
@@ -194,7 +343,13 @@ func (b *planBuilder) buildNewJoin(join *ast.Join) LogicalPlan {
 	joinPlan.initID()
 	joinPlan.SetSchema(newSchema)
 	joinPlan.correlated = leftPlan.IsCorrelated() || rightPlan.IsCorrelated()
-	if join.On != nil {
+	if len(join.Using) > 0 || join.NaturalJoin {
+		usingCond := b.coalesceJoinColumns(joinPlan, leftPlan, rightPlan, join)
+		if b.err != nil {
+			return nil
+		}
+		joinPlan.EqualConditions, _, _, _ = extractOnCondition(usingCond, leftPlan, rightPlan)
+	} else if join.On != nil {
 		onExpr, _, correlated, err := b.rewrite(join.On.Expr, joinPlan, nil, false)
 		if err != nil {
 			b.err = err
@@ -203,14 +358,16 @@ func (b *planBuilder) buildNewJoin(join *ast.Join) LogicalPlan {
 		if correlated {
 			b.err = errors.New("On condition doesn't support subqueries yet.")
 		}
-		onCondition := splitCNFItems(onExpr)
+		onCondition, err := simplifyJoinConditions(splitCNFItems(pushDownNot(onExpr, false)))
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
 		eqCond, leftCond, rightCond, otherCond := extractOnCondition(onCondition, leftPlan, rightPlan)
 		joinPlan.EqualConditions = eqCond
 		joinPlan.LeftConditions = leftCond
 		joinPlan.RightConditions = rightCond
 		joinPlan.OtherConditions = otherCond
-	} else if joinPlan.JoinType == InnerJoin {
-		joinPlan.cartesianJoin = true
 	}
 	if join.Tp == ast.LeftJoin {
 		joinPlan.JoinType = LeftOuterJoin
@@ -218,12 +375,99 @@ func (b *planBuilder) buildNewJoin(join *ast.Join) LogicalPlan {
 		joinPlan.JoinType = RightOuterJoin
 	} else {
 		joinPlan.JoinType = InnerJoin
+		// An inner join without any condition relating the two sides is a cross product.
+		joinPlan.cartesianJoin = len(joinPlan.EqualConditions) == 0 && len(joinPlan.OtherConditions) == 0
+	}
+	if joinPlan.wildcardSchema == nil {
+		joinPlan.wildcardSchema = append(wildcardSchema(leftPlan).DeepCopy(), wildcardSchema(rightPlan).DeepCopy()...)
 	}
 	addChild(joinPlan, leftPlan)
 	addChild(joinPlan, rightPlan)
 	return joinPlan
 }
 
+// coalesceJoinColumns returns the equal conditions of the columns in the USING clause, or of the columns with
+// the same names for a NATURAL join. The columns are taken from the left side, or from the right side for a
+// right join, and the ones on the other side are redundant. As in MySQL, the wildcard expands to the coalesced
+// columns in the order of the side they're taken from, then the other columns of that side and the other side.
+func (b *planBuilder) coalesceJoinColumns(joinPlan *Join, leftPlan, rightPlan LogicalPlan, join *ast.Join) []expression.Expression {
+	first, second := wildcardSchema(leftPlan), wildcardSchema(rightPlan)
+	if join.Tp == ast.RightJoin {
+		first, second = second, first
+	}
+	var names []model.CIStr
+	if join.NaturalJoin {
+		for _, col := range first {
+			if findColumnByName(second, col.ColName) != -1 {
+				names = append(names, col.ColName)
+			}
+		}
+	} else {
+		for _, name := range join.Using {
+			names = append(names, name.Name)
+		}
+	}
+	var conditions []expression.Expression
+	var firstCols, secondCols []*expression.Column
+	for _, name := range names {
+		firstIdx, secondIdx := findColumnByName(first, name), findColumnByName(second, name)
+		if firstIdx == -1 || secondIdx == -1 {
+			b.err = ErrUnknownColumn.Gen("Unknown column '%s' in 'from clause'", name)
+			return nil
+		}
+		if firstIdx == -2 || secondIdx == -2 {
+			b.err = ErrAmbiguousColumn.Gen("Column '%s' in from clause is ambiguous", name)
+			return nil
+		}
+		firstCol := joinPlan.GetSchema().RetrieveColumn(first[firstIdx])
+		secondCol := joinPlan.GetSchema().RetrieveColumn(second[secondIdx])
+		secondCol.Redundant = true
+		firstCols = append(firstCols, firstCol)
+		secondCols = append(secondCols, secondCol)
+		cond, _ := expression.NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), firstCol, secondCol)
+		conditions = append(conditions, cond)
+	}
+	for _, col := range first {
+		if findColumn(firstCols, col) != -1 {
+			joinPlan.wildcardSchema = append(joinPlan.wildcardSchema, joinPlan.GetSchema().RetrieveColumn(col))
+		}
+	}
+	for _, col := range first {
+		if findColumn(firstCols, col) == -1 {
+			joinPlan.wildcardSchema = append(joinPlan.wildcardSchema, joinPlan.GetSchema().RetrieveColumn(col))
+		}
+	}
+	for _, col := range second {
+		if findColumn(secondCols, col) == -1 {
+			joinPlan.wildcardSchema = append(joinPlan.wildcardSchema, joinPlan.GetSchema().RetrieveColumn(col))
+		}
+	}
+	return conditions
+}
+
+// wildcardSchema returns the columns that an unqualified wildcard over the plan expands to.
+func wildcardSchema(p LogicalPlan) expression.Schema {
+	if join, ok := p.(*Join); ok {
+		return join.wildcardSchema
+	}
+	return p.GetSchema()
+}
+
+// findColumnByName returns the index of the column with the name in the schema, or -1 if there is none and
+// -2 if there are more than one.
+func findColumnByName(schema expression.Schema, name model.CIStr) int {
+	idx := -1
+	for i, col := range schema {
+		if col.ColName.L == name.L {
+			if idx != -1 {
+				return -2
+			}
+			idx = i
+		}
+	}
+	return idx
+}
+
 func (b *planBuilder) buildSelection(p LogicalPlan, where ast.ExprNode, AggMapper map[*ast.AggregateFuncExpr]int) LogicalPlan {
 	conditions := splitWhere(where)
 	expressions := make([]expression.Expression, 0, len(conditions))
@@ -239,7 +483,8 @@ func (b *planBuilder) buildSelection(p LogicalPlan, where ast.ExprNode, AggMappe
 		p = np
 		selection.correlated = selection.correlated || correlated
 		if expr != nil {
-			expressions = append(expressions, splitCNFItems(expr)...)
+			// Normalize the NOT operators so that the conditions can be used to build ranges.
+			expressions = append(expressions, splitCNFItems(pushDownNot(expr, false))...)
 		}
 	}
 	if len(expressions) == 0 {
@@ -721,7 +966,11 @@ func (b *planBuilder) unfoldWildStar(p LogicalPlan, selectFields []*ast.SelectFi
 		}
 		dbName := field.WildCard.Schema
 		tblName := field.WildCard.Table
-		for _, col := range p.GetSchema() {
+		schema := p.GetSchema()
+		if tblName.L == "" {
+			schema = wildcardSchema(p)
+		}
+		for _, col := range schema {
 			if (dbName.L == "" || dbName.L == col.DBName.L) &&
 				(tblName.L == "" || tblName.L == col.TblName.L) {
 				colName := &ast.ColumnNameExpr{
@@ -777,6 +1026,12 @@ func (b *planBuilder) buildNewSelect(sel *ast.SelectStmt) LogicalPlan {
 	if sel.LockTp != ast.SelectLockNone {
 		p = b.buildSelectLock(p, sel.LockTp)
 	}
+	if sel.GroupBy != nil && sel.GroupBy.GroupingSets != nil {
+		p, gbyCols = b.buildExpand(p, gbyCols, sel.GroupBy.GroupingSets)
+		if b.err != nil {
+			return nil
+		}
+	}
 	if hasAgg {
 		aggFuncs, totalMap = b.extractAggFuncs(sel.Fields.Fields)
 		if b.err != nil {
@@ -786,6 +1041,7 @@ func (b *planBuilder) buildNewSelect(sel *ast.SelectStmt) LogicalPlan {
 		if b.err != nil {
 			return nil
 		}
+		p.(*Aggregation).ResultSize = sel.ResultSize
 	}
 	var oldLen int
 	p, oldLen = b.buildProjection(p, sel.Fields.Fields, totalMap)
@@ -803,6 +1059,7 @@ func (b *planBuilder) buildNewSelect(sel *ast.SelectStmt) LogicalPlan {
 		if b.err != nil {
 			return nil
 		}
+		p.(*Distinct).ResultSize = sel.ResultSize
 	}
 	if sel.OrderBy != nil {
 		p = b.buildNewSort(p, sel.OrderBy.Items, orderMap)
@@ -822,6 +1079,170 @@ func (b *planBuilder) buildNewSelect(sel *ast.SelectStmt) LogicalPlan {
 	return p
 }
 
+// buildDo builds the plan for DO statement. The expressions are evaluated by a projection on the dual table,
+// so the subqueries in them are planned as in the select fields.
+func (b *planBuilder) buildDo(do *ast.DoStmt) LogicalPlan {
+	fields := make([]*ast.SelectField, 0, len(do.Exprs))
+	for _, expr := range do.Exprs {
+		fields = append(fields, &ast.SelectField{Expr: expr})
+	}
+	p, _ := b.buildProjection(b.buildNewTableDual(), fields, nil)
+	if b.err != nil {
+		return nil
+	}
+	doPlan := &Do{}
+	addChild(doPlan, p)
+	return doPlan
+}
+
+// buildSet builds the plan of a SET statement with subqueries. Each value with subqueries is built as a projection
+// over a dual table, whose subqueries are applied instead of evaluated when they are built.
+func (b *planBuilder) buildSet(set *ast.SetStmt) Plan {
+	setPlan := &Set{Stmt: set, Values: make(map[*ast.VariableAssignment]Plan)}
+	b.inSetValue = true
+	defer func() {
+		b.inSetValue = false
+	}()
+	for _, v := range set.Variables {
+		if v.Value == nil || !exprHasSubquery(v.Value) {
+			continue
+		}
+		p, _ := b.buildProjection(b.buildNewTableDual(), []*ast.SelectField{{Expr: v.Value}}, nil)
+		if b.err != nil {
+			return nil
+		}
+		setPlan.Values[v] = p
+	}
+	return setPlan
+}
+
+// subqueryDetector checks if the visited expressions contain a subquery.
+type subqueryDetector struct {
+	found bool
+}
+
+func (d *subqueryDetector) Enter(in ast.Node) (ast.Node, bool) {
+	if _, ok := in.(*ast.SubqueryExpr); ok {
+		d.found = true
+	}
+	return in, d.found
+}
+
+func (d *subqueryDetector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// buildNewUpdate builds the plan of an UPDATE statement with subqueries in the assigned values. The rows to update
+// are read as in a select statement, and the projection over them computes the new values from the old ones.
+func (b *planBuilder) buildNewUpdate(update *ast.UpdateStmt) LogicalPlan {
+	p := b.buildResultSetNode(update.TableRefs.TableRefs)
+	if b.err != nil {
+		return nil
+	}
+	tableOffsets := make(map[*model.CIStr]int)
+	collectTableOffsets(p, p.GetSchema(), tableOffsets)
+	if update.Where != nil {
+		p = b.buildSelection(p, update.Where, nil)
+		if b.err != nil {
+			return nil
+		}
+	}
+	if update.Order != nil {
+		p = b.buildNewSort(p, update.Order.Items, nil)
+		if b.err != nil {
+			return nil
+		}
+	}
+	if update.Limit != nil {
+		p = b.buildNewLimit(p, update.Limit)
+		if b.err != nil {
+			return nil
+		}
+	}
+	// The schema of the tables has the same columns as their result fields.
+	orderedList := b.buildUpdateLists(update.List, update.TableRefs.TableRefs.GetResultFields())
+	if b.err != nil {
+		return nil
+	}
+	tableCols := p.GetSchema()
+	proj := &Projection{baseLogicalPlan: newBaseLogicalPlan(Proj, b.allocator)}
+	proj.initID()
+	proj.correlated = p.IsCorrelated()
+	schema := make(expression.Schema, 0, len(tableCols)+len(update.List))
+	for _, col := range tableCols {
+		proj.Exprs = append(proj.Exprs, col.DeepCopy())
+		schema = append(schema, &expression.Column{
+			FromID:   proj.id,
+			TblName:  col.TblName,
+			ColName:  col.ColName,
+			DBName:   col.DBName,
+			RetType:  col.RetType,
+			Position: len(schema) + 1})
+	}
+	for _, assign := range orderedList {
+		if assign == nil {
+			continue
+		}
+		newExpr, np, correlated, err := b.rewrite(assign.Expr, p, nil, true)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
+		p = np
+		proj.correlated = proj.correlated || correlated
+		proj.Exprs = append(proj.Exprs, newExpr)
+		schema = append(schema, &expression.Column{
+			FromID:   proj.id,
+			ColName:  assign.Column.Name,
+			RetType:  newExpr.GetType(),
+			Position: len(schema) + 1})
+	}
+	proj.SetSchema(schema)
+	addChild(proj, p)
+	updatePlan := &NewUpdate{OrderedList: orderedList, TableOffsets: tableOffsets}
+	addChild(updatePlan, proj)
+	return updatePlan
+}
+
+// collectTableOffsets finds the offsets of the first columns of the data sources in the schema.
+func collectTableOffsets(p LogicalPlan, schema expression.Schema, offsets map[*model.CIStr]int) {
+	if ds, ok := p.(*DataSource); ok {
+		for i, col := range schema {
+			if col.FromID == ds.id {
+				offsets[ds.TableAsName] = i
+				break
+			}
+		}
+		return
+	}
+	for _, child := range p.GetChildren() {
+		collectTableOffsets(child.(LogicalPlan), schema, offsets)
+	}
+}
+
+func hasSubquery(set *ast.SetStmt) bool {
+	for _, v := range set.Variables {
+		if v.Value != nil && exprHasSubquery(v.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func exprHasSubquery(expr ast.ExprNode) bool {
+	d := &subqueryDetector{}
+	expr.Accept(d)
+	return d.found
+}
+
+func assignmentsHaveSubquery(list []*ast.Assignment) bool {
+	d := &subqueryDetector{}
+	for _, assign := range list {
+		assign.Expr.Accept(d)
+	}
+	return d.found
+}
+
 func (b *planBuilder) buildTrim(p LogicalPlan, len int) LogicalPlan {
 	trim := &Trim{baseLogicalPlan: newBaseLogicalPlan(Trm, b.allocator)}
 	trim.initID()
@@ -842,16 +1263,76 @@ func (b *planBuilder) getTableStats(table *model.TableInfo) *statistics.Table {
 	return statistics.PseudoTable(table)
 }
 
+// hashAggGroupLimit returns the tidb_hash_agg_group_limit of the session.
+func (b *planBuilder) hashAggGroupLimit() uint64 {
+	if b.ctx == nil {
+		return variable.DefHashAggGroupLimit
+	}
+	sessionVars := variable.GetSessionVars(b.ctx)
+	if sessionVars == nil {
+		return variable.DefHashAggGroupLimit
+	}
+	return sessionVars.HashAggGroupLimit
+}
+
+// isFullScanForbidden checks if the table exceeds the full scan row limit of the session.
+// An empty USE INDEX () hint asks for a table scan explicitly, so it is always allowed.
+// The row count is the one recorded by ANALYZE TABLE, the statistics used for planning are pseudo ones, whose
+// count doesn't depend on the table. A table that hasn't been analyzed is taken to have the pseudo row count.
+func (b *planBuilder) isFullScanForbidden(tn *ast.TableName, statsTbl *statistics.Table) bool {
+	if b.ctx == nil {
+		return false
+	}
+	sessionVars := variable.GetSessionVars(b.ctx)
+	if sessionVars == nil || sessionVars.FullScanRowLimit <= 0 {
+		return false
+	}
+	for _, hint := range tn.IndexHints {
+		if hint.HintType == ast.HintUse && len(hint.IndexNames) == 0 {
+			return false
+		}
+	}
+	count, err := b.analyzedRowCount(tn.TableInfo)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return false
+	}
+	if count < 0 {
+		count = statsTbl.Count
+	}
+	return count > sessionVars.FullScanRowLimit
+}
+
+// analyzedRowCount returns the row count of the table recorded by ANALYZE TABLE, or -1 if the table hasn't
+// been analyzed.
+func (b *planBuilder) analyzedRowCount(table *model.TableInfo) (int64, error) {
+	txn, err := b.ctx.GetTxn(false)
+	if err != nil || txn == nil {
+		return -1, errors.Trace(err)
+	}
+	tpb, err := meta.NewMeta(txn).GetTableStats(table.ID)
+	if err != nil || tpb == nil {
+		return -1, errors.Trace(err)
+	}
+	return tpb.GetCount(), nil
+}
+
 func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
 	statisticTable := b.getTableStats(tn.TableInfo)
 	if b.err != nil {
 		return nil
 	}
+	forbidFullScan := b.isFullScanForbidden(tn, statisticTable)
+	if b.err != nil {
+		return nil
+	}
 	p := &DataSource{
 		table:           tn,
 		Table:           tn.TableInfo,
 		baseLogicalPlan: newBaseLogicalPlan(Ts, b.allocator),
 		statisticTable:  statisticTable,
+		forbidFullScan:  forbidFullScan,
+		feedback:        getCardinalityFeedback(b.ctx),
 	}
 	p.initID()
 	// Equal condition contains a column from previous joined table.
@@ -934,7 +1415,9 @@ out:
 		switch p.(type) {
 		// This can be removed when in exists clause,
 		// e.g. exists(select count(*) from t order by a) is equal to exists t.
-		case *Trim, *Projection, *NewSort, *Aggregation:
+		// A distinct removes no row that makes the result exist, e.g. exists(select distinct b from t) is equal
+		// to exists t, and removing it lets a correlated subquery be decorrelated into a semi join.
+		case *Trim, *Projection, *NewSort, *Aggregation, *Distinct:
 			p = p.GetChildByIndex(0).(LogicalPlan)
 			p.SetParents()
 		default:
@@ -962,6 +1445,165 @@ func (b *planBuilder) buildMaxOneRow(p LogicalPlan) LogicalPlan {
 	return maxOneRow
 }
 
+// alwaysReturnOneRow checks if the plan is guaranteed to return exactly one row.
+func alwaysReturnOneRow(p LogicalPlan) bool {
+	switch x := p.(type) {
+	case *Aggregation:
+		return len(x.GroupByItems) == 0
+	case *Projection, *Trim:
+		return alwaysReturnOneRow(p.GetChildByIndex(0).(LogicalPlan))
+	}
+	return false
+}
+
+// decorrelateAggSubquery tries to convert a correlated scalar subquery like
+// "select (select count(*) from s where s.a = t.a) from t" to a left outer join between
+// t and "select count(*), s.a from s group by s.a" on t.a = s.a.
+// The inner plan must be a projection over an aggregation without group by items, and the correlated conditions
+// must be equal conditions between the inner columns and the columns of outerPlan of the same type.
+// It returns nil if the subquery can't be decorrelated.
+func (b *planBuilder) decorrelateAggSubquery(outerPlan, innerPlan LogicalPlan) LogicalPlan {
+	proj, ok := innerPlan.(*Projection)
+	if !ok || len(proj.GetSchema()) != 1 {
+		return nil
+	}
+	agg, ok := proj.GetChildByIndex(0).(*Aggregation)
+	if !ok || len(agg.GroupByItems) > 0 {
+		return nil
+	}
+	sel, ok := agg.GetChildByIndex(0).(*Selection)
+	if !ok || sel.GetChildByIndex(0).IsCorrelated() {
+		return nil
+	}
+	for _, expr := range proj.Exprs {
+		if _, outerCols := extractColumn(expr, nil, nil); len(outerCols) > 0 {
+			return nil
+		}
+	}
+	for _, aggFunc := range agg.AggFuncs {
+		switch aggFunc.GetName() {
+		case ast.AggFuncCount, ast.AggFuncSum, ast.AggFuncAvg, ast.AggFuncMax, ast.AggFuncMin:
+		default:
+			return nil
+		}
+		for _, arg := range aggFunc.GetArgs() {
+			if _, outerCols := extractColumn(arg, nil, nil); len(outerCols) > 0 {
+				return nil
+			}
+		}
+	}
+	var innerCols, outerCols []*expression.Column
+	var conditions []expression.Expression
+	for _, cond := range sel.Conditions {
+		_, corCols := extractColumn(cond, nil, nil)
+		if len(corCols) == 0 {
+			conditions = append(conditions, cond)
+			continue
+		}
+		f, ok := cond.(*expression.ScalarFunction)
+		if !ok || f.FuncName.L != ast.EQ {
+			return nil
+		}
+		lCol, lOK := f.Args[0].(*expression.Column)
+		rCol, rOK := f.Args[1].(*expression.Column)
+		if !lOK || !rOK || lCol.Correlated == rCol.Correlated {
+			return nil
+		}
+		if lCol.Correlated {
+			lCol, rCol = rCol, lCol
+		}
+		if outerPlan.GetSchema().GetIndex(rCol) == -1 {
+			return nil
+		}
+		// The join keys are converted to the same type, which may make the values of different types equal.
+		lType, rType := lCol.GetType(), rCol.GetType()
+		if lType == nil || rType == nil || lType.Tp != rType.Tp {
+			return nil
+		}
+		innerCols = append(innerCols, lCol)
+		outerCols = append(outerCols, rCol)
+	}
+
+	child := sel.GetChildByIndex(0).(LogicalPlan)
+	child.SetParents()
+	if len(conditions) > 0 {
+		newSel := &Selection{
+			Conditions:      conditions,
+			baseLogicalPlan: newBaseLogicalPlan(Sel, b.allocator),
+		}
+		newSel.initID()
+		newSel.SetSchema(child.GetSchema().DeepCopy())
+		addChild(newSel, child)
+		child = newSel
+	}
+	newAgg := &Aggregation{
+		AggFuncs:        agg.AggFuncs,
+		groupLimit:      agg.groupLimit,
+		baseLogicalPlan: newBaseLogicalPlan(Agg, b.allocator),
+	}
+	newAgg.initID()
+	addChild(newAgg, child)
+	// The group by columns are output by firstrow functions so that they can be used as join keys.
+	aggSchema := agg.GetSchema().DeepCopy()
+	joinKeys := make([]*expression.Column, 0, len(innerCols))
+	for i, col := range innerCols {
+		newAgg.GroupByItems = append(newAgg.GroupByItems, col)
+		newAgg.AggFuncs = append(newAgg.AggFuncs, expression.NewAggFunction(ast.AggFuncFirstRow, []expression.Expression{col}, false))
+		joinKey := &expression.Column{
+			FromID:   newAgg.id,
+			ColName:  model.NewCIStr(fmt.Sprintf("%s_col_%d", newAgg.id, i)),
+			Position: len(aggSchema),
+			RetType:  col.GetType(),
+		}
+		aggSchema = append(aggSchema, joinKey)
+		joinKeys = append(joinKeys, joinKey)
+	}
+	newAgg.SetSchema(aggSchema)
+
+	joinPlan := &Join{
+		JoinType:        LeftOuterJoin,
+		baseLogicalPlan: newBaseLogicalPlan(Jn, b.allocator),
+	}
+	joinPlan.initID()
+	joinPlan.correlated = outerPlan.IsCorrelated()
+	for i, outerCol := range outerCols {
+		outerCol = outerCol.DeepCopy().(*expression.Column)
+		outerCol.Correlated = false
+		eqCond, _ := expression.NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), outerCol, joinKeys[i].DeepCopy())
+		joinPlan.EqualConditions = append(joinPlan.EqualConditions, eqCond.(*expression.ScalarFunction))
+	}
+	joinPlan.SetSchema(append(outerPlan.GetSchema().DeepCopy(), aggSchema.DeepCopy()...))
+	addChild(joinPlan, outerPlan)
+	addChild(joinPlan, newAgg)
+
+	// If there is no matched row, count returns 0 while the other aggregation functions return null.
+	newExprs := make([]expression.Expression, 0, len(agg.GetSchema()))
+	for i, col := range agg.GetSchema() {
+		var expr expression.Expression = col.DeepCopy()
+		if agg.AggFuncs[i].GetName() == ast.AggFuncCount {
+			zero := &expression.Constant{Value: types.NewIntDatum(0), RetType: types.NewFieldType(mysql.TypeLonglong)}
+			var err error
+			expr, err = expression.NewFunction(ast.Ifnull, col.GetType(), expr, zero)
+			if err != nil {
+				b.err = errors.Trace(err)
+				return nil
+			}
+		}
+		newExprs = append(newExprs, expr)
+	}
+	exprs := make([]expression.Expression, 0, len(outerPlan.GetSchema())+1)
+	for _, col := range outerPlan.GetSchema() {
+		exprs = append(exprs, col.DeepCopy())
+	}
+	proj.Exprs = append(exprs, columnSubstitute(proj.Exprs[0], agg.GetSchema(), newExprs))
+	proj.SetSchema(append(outerPlan.GetSchema().DeepCopy(), proj.GetSchema()...))
+	proj.SetParents()
+	proj.SetChildren()
+	proj.correlated = joinPlan.correlated
+	addChild(proj, joinPlan)
+	return proj
+}
+
 // tryDecorrelated tries to remove the correlated column that can be found in the outerPlan's schema.
 func tryDecorrelated(expr expression.Expression, outerPlan Plan) bool {
 	correlated := false
@@ -976,7 +1618,171 @@ func tryDecorrelated(expr expression.Expression, outerPlan Plan) bool {
 	return correlated
 }
 
-func (b *planBuilder) buildSemiJoin(outerPlan, innerPlan LogicalPlan, onCondition []expression.Expression, asScalar bool, not bool) LogicalPlan {
+// hasEqualCorrelation checks if there is an equal condition between an inner column and a column of outerPlan,
+// which can be the key of the join that the subquery is decorrelated into.
+func hasEqualCorrelation(conditions []expression.Expression, outerPlan Plan) bool {
+	for _, cond := range conditions {
+		f, ok := cond.(*expression.ScalarFunction)
+		if !ok || f.FuncName.L != ast.EQ {
+			continue
+		}
+		lCol, lOK := f.Args[0].(*expression.Column)
+		rCol, rOK := f.Args[1].(*expression.Column)
+		if !lOK || !rOK || lCol.Correlated == rCol.Correlated {
+			continue
+		}
+		if lCol.Correlated {
+			lCol, rCol = rCol, lCol
+		}
+		if outerPlan.GetSchema().GetIndex(rCol) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedSubquery records the occurrences of the same non-correlated subquery in a statement.
+// When it's referenced more than once and worth it, the result is materialized once and shared.
+type sharedSubquery struct {
+	// id identifies the shared result among the ones of the statement, it starts from 1.
+	id   int
+	p    LogicalPlan
+	refs int
+}
+
+// shareSubquery registers an occurrence of the non-correlated subquery whose plan is p.
+// Subqueries are regarded as identical if their plans are the same, since a non-correlated subquery
+// doesn't refer to any outer column.
+func (b *planBuilder) shareSubquery(p LogicalPlan) *sharedSubquery {
+	for _, share := range b.sharedSubqueries {
+		if isSamePlan(share.p, p) {
+			share.refs++
+			return share
+		}
+	}
+	share := &sharedSubquery{id: len(b.sharedSubqueries) + 1, p: p, refs: 1}
+	b.sharedSubqueries = append(b.sharedSubqueries, share)
+	return share
+}
+
+// isSamePlan checks if the two logical plans have the same operators over the same tables and expressions,
+// so they return the same rows. It's conservative and returns false for the plans it doesn't know how to compare.
+func isSamePlan(a, b Plan) bool {
+	if fmt.Sprintf("%T", a) != fmt.Sprintf("%T", b) || len(a.GetChildren()) != len(b.GetChildren()) ||
+		len(a.GetSchema()) != len(b.GetSchema()) {
+		return false
+	}
+	for i, child := range a.GetChildren() {
+		if !isSamePlan(child, b.GetChildren()[i]) {
+			return false
+		}
+	}
+	// The expressions of a and b refer to the columns of their children, which are matched by the positions.
+	c := &exprComparer{}
+	for i, child := range a.GetChildren() {
+		c.aSchema = append(c.aSchema, child.GetSchema()...)
+		c.bSchema = append(c.bSchema, b.GetChildren()[i].GetSchema()...)
+	}
+	switch x := a.(type) {
+	case *DataSource:
+		y := b.(*DataSource)
+		if x.Table.ID != y.Table.ID || x.DBName.L != y.DBName.L || x.Desc != y.Desc {
+			return false
+		}
+		for i, col := range x.GetSchema() {
+			if col.ColName.L != y.GetSchema()[i].ColName.L {
+				return false
+			}
+		}
+		return true
+	case *Selection:
+		return c.sameExprs(x.Conditions, b.(*Selection).Conditions)
+	case *Projection:
+		return c.sameExprs(x.Exprs, b.(*Projection).Exprs)
+	case *Aggregation:
+		y := b.(*Aggregation)
+		if len(x.AggFuncs) != len(y.AggFuncs) || !c.sameExprs(x.GroupByItems, y.GroupByItems) {
+			return false
+		}
+		for i, agg := range x.AggFuncs {
+			if agg.GetName() != y.AggFuncs[i].GetName() || agg.IsDistinct() != y.AggFuncs[i].IsDistinct() ||
+				!c.sameExprs(agg.GetArgs(), y.AggFuncs[i].GetArgs()) {
+				return false
+			}
+		}
+		return true
+	case *Join:
+		y := b.(*Join)
+		if x.JoinType != y.JoinType || x.anti != y.anti || x.nullAware != y.nullAware ||
+			len(x.EqualConditions) != len(y.EqualConditions) {
+			return false
+		}
+		for i, cond := range x.EqualConditions {
+			if !cond.Equal(c.mapColumns(y.EqualConditions[i])) {
+				return false
+			}
+		}
+		return c.sameExprs(x.LeftConditions, y.LeftConditions) && c.sameExprs(x.RightConditions, y.RightConditions) &&
+			c.sameExprs(x.OtherConditions, y.OtherConditions)
+	case *Limit:
+		y := b.(*Limit)
+		return x.Offset == y.Offset && x.Count == y.Count
+	case *NewSort:
+		y := b.(*NewSort)
+		if len(x.ByItems) != len(y.ByItems) {
+			return false
+		}
+		for i, item := range x.ByItems {
+			if item.Desc != y.ByItems[i].Desc || !item.Expr.Equal(c.mapColumns(y.ByItems[i].Expr)) {
+				return false
+			}
+		}
+		return true
+	case *Distinct, *Trim, *NewUnion, *MaxOneRow, *Exists, *NewTableDual:
+		return true
+	}
+	return false
+}
+
+// exprComparer compares the expressions of two plans, whose columns are matched by their positions in the schemas
+// of the plans' children instead of their names, since the columns produced by the plans are named by the plan ids.
+type exprComparer struct {
+	aSchema expression.Schema
+	bSchema expression.Schema
+}
+
+func (c *exprComparer) sameExprs(a, b []expression.Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, expr := range a {
+		if !expr.Equal(c.mapColumns(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// mapColumns replaces the columns of b's expression with the columns of a at the same positions, so it can be
+// compared with a's expression by Equal.
+func (c *exprComparer) mapColumns(expr expression.Expression) expression.Expression {
+	switch x := expr.(type) {
+	case *expression.Column:
+		if i := c.bSchema.GetIndex(x); i != -1 {
+			return c.aSchema[i]
+		}
+	case *expression.ScalarFunction:
+		f := *x
+		f.Args = make([]expression.Expression, 0, len(x.Args))
+		for _, arg := range x.Args {
+			f.Args = append(f.Args, c.mapColumns(arg))
+		}
+		return &f
+	}
+	return expr
+}
+
+func (b *planBuilder) buildSemiJoin(outerPlan, innerPlan LogicalPlan, onCondition []expression.Expression, asScalar, not, nullAware bool) LogicalPlan {
 	joinPlan := &Join{baseLogicalPlan: newBaseLogicalPlan(Jn, b.allocator)}
 	joinPlan.initID()
 	joinPlan.correlated = outerPlan.IsCorrelated() || innerPlan.IsCorrelated()
@@ -1001,6 +1807,7 @@ func (b *planBuilder) buildSemiJoin(outerPlan, innerPlan LogicalPlan, onConditio
 		joinPlan.JoinType = SemiJoin
 	}
 	joinPlan.anti = not
+	joinPlan.nullAware = nullAware
 	joinPlan.SetChildren(outerPlan, innerPlan)
 	outerPlan.SetParents(joinPlan)
 	innerPlan.SetParents(joinPlan)
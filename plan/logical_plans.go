@@ -45,6 +45,16 @@ type Join struct {
 	anti          bool
 	reordered     bool
 	cartesianJoin bool
+	// rightUnique means every left row matches at most one right row by the equal conditions.
+	rightUnique bool
+	// nullAware is set for the semi join of IN, which is unknown rather than false if a key is null, but not EXISTS.
+	nullAware bool
+	// sharedInner is set for a semi join whose inner plan is a non-correlated subquery that may appear
+	// several times in the statement.
+	sharedInner *sharedSubquery
+	// wildcardSchema is the columns that an unqualified wildcard over the join expands to, in which the columns
+	// coalesced by USING or NATURAL appear once and first.
+	wildcardSchema expression.Schema
 
 	EqualConditions []*expression.ScalarFunction
 	LeftConditions  []expression.Expression
@@ -61,9 +71,36 @@ type Projection struct {
 // Aggregation represents an aggregate plan.
 type Aggregation struct {
 	baseLogicalPlan
-	// TODO: implement hash aggregation and streamed aggreagtion
 	AggFuncs     []expression.AggregationFunction
 	GroupByItems []expression.Expression
+
+	// Streamed means the child is sorted by the group by columns, so the groups are aggregated one by one
+	// instead of in a hash table.
+	Streamed bool
+	// StreamedDistinct means the child is sorted by the arguments of the only COUNT(DISTINCT), so the distinct
+	// arguments are found by comparing them with the last ones instead of in a hash set.
+	StreamedDistinct bool
+	// GroupSkip means the child is an index scan, whose columns are the group by columns followed by the
+	// argument of MIN or MAX, so only the first entry of every group is read, by seeking past the last group.
+	GroupSkip bool
+	// ResultSize is the size of the result hinted by SQL_SMALL_RESULT or SQL_BIG_RESULT, which makes the
+	// aggregation hashed or streamed regardless of the estimated group count.
+	ResultSize ast.SelectResultSize
+
+	// groupLimit is the estimated group count above which the aggregation is streamed, see tidb_hash_agg_group_limit.
+	groupLimit uint64
+}
+
+// Expand outputs each row of the child once for every grouping set, for GROUP BY GROUPING SETS.
+// Its schema is the child's schema followed by the padded grouping columns, which are NULL when the
+// column is absent in the set, one flag per grouping column telling if it's padded and the set id.
+type Expand struct {
+	baseLogicalPlan
+
+	// GroupingCols are the distinct columns used by the grouping sets.
+	GroupingCols []*expression.Column
+	// GroupingSets are the offsets into GroupingCols of each grouping set.
+	GroupingSets [][]int
 }
 
 // Selection means a filter.
@@ -102,6 +139,24 @@ type NewTableDual struct {
 	baseLogicalPlan
 }
 
+// Do represents DO statement, it executes the child for the side effects and discards the rows.
+type Do struct {
+	baseLogicalPlan
+}
+
+// NewUpdate represents UPDATE statement whose assigned values contain subqueries. The child is a projection of the
+// columns of the tables followed by the values of the assignments in OrderedList, which has the same offset as the
+// columns, so the subqueries are planned as in the select fields, e.g. a correlated one is decorrelated into a join.
+type NewUpdate struct {
+	baseLogicalPlan
+
+	OrderedList []*ast.Assignment
+	// TableOffsets are the offsets of the columns of the updated tables in the rows, keyed by the TableAsName of
+	// their data sources, which is the alias of the table source in the statement. The rows of the tables read by
+	// the subqueries aren't updated.
+	TableOffsets map[*model.CIStr]int
+}
+
 // DataSource represents a tablescan without condition push down.
 type DataSource struct {
 	baseLogicalPlan
@@ -117,6 +172,10 @@ type DataSource struct {
 	LimitCount *int64
 
 	statisticTable *statistics.Table
+	// forbidFullScan means the table is too large to be fully scanned, see tidb_full_scan_row_limit.
+	forbidFullScan bool
+	// feedback supplies the factors that correct the estimated row counts, it's nil if there is no feedback.
+	feedback CardinalityFeedback
 }
 
 // Trim trims child's rows.
@@ -18,19 +18,57 @@ import (
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/mock"
 	"github.com/pingcap/tidb/util/testleak"
 	"github.com/pingcap/tidb/util/types"
 )
 
+// newTestBuilder returns a plan builder with a mock context.
+func newTestBuilder() *planBuilder {
+	return &planBuilder{
+		allocator: new(idAllocator),
+		ctx:       mock.NewContext(),
+		colMapper: make(map[*ast.ColumnNameExpr]int),
+	}
+}
+
+// buildTestPlan parses the statement, resolves it against the mock tables and builds its plan with the builder.
+func (s *testPlanSuite) buildTestPlan(c *C, sql string, builder *planBuilder) (Plan, error) {
+	comment := Commentf("for %s", sql)
+	stmt, err := s.ParseOneStmt(sql, "", "")
+	c.Assert(err, IsNil, comment)
+	ast.SetFlag(stmt)
+	err = newMockResolve(stmt)
+	c.Assert(err, IsNil, comment)
+	p := builder.build(stmt)
+	return p, builder.err
+}
+
+// optimizeTestPlan converts the logical plan to the best physical plan like Optimize, but the selections
+// above joins are kept.
+func optimizeTestPlan(c *C, lp LogicalPlan, comment CommentInterface) PhysicalPlan {
+	_, lp, err := lp.PredicatePushDown(nil)
+	c.Assert(err, IsNil, comment)
+	_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
+	c.Assert(err, IsNil, comment)
+	_, res, _, err := lp.convert2PhysicalPlan(nil)
+	c.Assert(err, IsNil, comment)
+	return res.p.PushLimit(nil)
+}
+
 func newMockResolve(node ast.Node) error {
 	indices := []*model.IndexInfo{
 		{
+			ID:   1,
 			Name: model.NewCIStr("c_d_e"),
 			Columns: []*model.IndexColumn{
 				{
@@ -75,7 +113,97 @@ func newMockResolve(node ast.Node) error {
 		Name:       model.NewCIStr("t"),
 		PKIsHandle: true,
 	}
-	is := infoschema.MockInfoSchema([]*model.TableInfo{table})
+	// Table s has a primary key which is not the handle, so it's stored as a unique index.
+	sPKColumn := &model.ColumnInfo{
+		State:  model.StatePublic,
+		Name:   model.NewCIStr("a"),
+		Flag:   mysql.PriKeyFlag,
+		Offset: 0,
+	}
+	sCol0 := &model.ColumnInfo{
+		State:  model.StatePublic,
+		Name:   model.NewCIStr("b"),
+		Offset: 1,
+	}
+	sIndices := []*model.IndexInfo{
+		{
+			ID:   1,
+			Name: model.NewCIStr("PRIMARY"),
+			Columns: []*model.IndexColumn{
+				{
+					Name:   model.NewCIStr("a"),
+					Length: types.UnspecifiedLength,
+					Offset: 0,
+				},
+			},
+			Unique:  true,
+			Primary: true,
+		},
+		{
+			ID:   2,
+			Name: model.NewCIStr("b"),
+			Columns: []*model.IndexColumn{
+				{
+					Name:   model.NewCIStr("b"),
+					Length: types.UnspecifiedLength,
+					Offset: 1,
+				},
+			},
+		},
+	}
+	sTable := &model.TableInfo{
+		ID:      1,
+		Columns: []*model.ColumnInfo{sPKColumn, sCol0},
+		Indices: sIndices,
+		Name:    model.NewCIStr("s"),
+	}
+	// Table r has a composite primary key.
+	rColumns := []*model.ColumnInfo{
+		{
+			State:  model.StatePublic,
+			Name:   model.NewCIStr("a"),
+			Flag:   mysql.PriKeyFlag,
+			Offset: 0,
+		},
+		{
+			State:  model.StatePublic,
+			Name:   model.NewCIStr("b"),
+			Flag:   mysql.PriKeyFlag,
+			Offset: 1,
+		},
+		{
+			State:  model.StatePublic,
+			Name:   model.NewCIStr("c"),
+			Offset: 2,
+		},
+	}
+	rIndices := []*model.IndexInfo{
+		{
+			ID:   1,
+			Name: model.NewCIStr("PRIMARY"),
+			Columns: []*model.IndexColumn{
+				{
+					Name:   model.NewCIStr("a"),
+					Length: types.UnspecifiedLength,
+					Offset: 0,
+				},
+				{
+					Name:   model.NewCIStr("b"),
+					Length: types.UnspecifiedLength,
+					Offset: 1,
+				},
+			},
+			Unique:  true,
+			Primary: true,
+		},
+	}
+	rTable := &model.TableInfo{
+		ID:      2,
+		Columns: rColumns,
+		Indices: rIndices,
+		Name:    model.NewCIStr("r"),
+	}
+	is := infoschema.MockInfoSchema([]*model.TableInfo{table, sTable, rTable})
 	ctx := mock.NewContext()
 	variable.BindSessionVars(ctx)
 	return MockResolveName(node, is, "test", ctx)
@@ -109,6 +237,36 @@ func (s *testPlanSuite) TestPredicatePushDown(c *C) {
 			first: "DataScan(t)->Selection->Projection->Selection->Projection",
 			best:  "DataScan(t)->Selection->Projection->Projection",
 		},
+		{
+			sql:   "select * from (select c as x, count(*) as cnt from t group by c) k where k.x > 1 and k.cnt > 1",
+			first: "DataScan(t)->Aggr->Projection->Selection->Projection",
+			best:  "DataScan(t)->Selection->Aggr->Selection->Projection->Projection",
+		},
+		{
+			sql:   "select * from (select y, cnt from (select c as y, sum(d) as cnt from t group by c, e) k1) k2 where k2.y = 1",
+			first: "DataScan(t)->Aggr->Projection->Projection->Selection->Projection",
+			best:  "DataScan(t)->Selection->Aggr->Projection->Projection->Projection",
+		},
+		{
+			sql:   "select * from (select d as x, count(*) as cnt from t group by c) k where k.x > 1",
+			first: "DataScan(t)->Aggr->Projection->Selection->Projection",
+			best:  "DataScan(t)->Aggr->Selection->Projection->Projection",
+		},
+		{
+			sql:   "select * from (select c, count(*) from t group by c having rand() < 0.5) k",
+			first: "DataScan(t)->Aggr->Projection->Selection->Projection",
+			best:  "DataScan(t)->Aggr->Selection->Projection->Projection",
+		},
+		{
+			sql:   "select * from (select c, count(*) from t group by c having c > rand()) k",
+			first: "DataScan(t)->Aggr->Projection->Selection->Trim->Projection",
+			best:  "DataScan(t)->Aggr->Selection->Projection->Trim->Projection",
+		},
+		{
+			sql:   "select * from (select count(*) as cnt from t) k where 1 = 0",
+			first: "DataScan(t)->Aggr->Projection->Selection->Projection",
+			best:  "DataScan(t)->Aggr->Selection->Projection->Projection",
+		},
 		{
 			sql:   "select * from t ta, t tb where (ta.d, ta.a) = (tb.b, tb.c)",
 			first: "Join{DataScan(t)->DataScan(t)}->Selection->Projection",
@@ -141,11 +299,41 @@ func (s *testPlanSuite) TestPredicatePushDown(c *C) {
 		},
 		{
 			sql:   "select (select count(*) from t where t.a = k.a) from t k",
+			first: "Join{DataScan(t)->DataScan(t)->Aggr}->Projection->Projection",
+			best:  "Join{DataScan(t)->DataScan(t)->Aggr}->Projection->Projection",
+		},
+		{
+			sql:   "select k.a, (select max(b) from t where t.c = k.c and t.d > 1) from t k",
+			first: "Join{DataScan(t)->DataScan(t)->Selection->Aggr}->Projection->Projection",
+			best:  "Join{DataScan(t)->DataScan(t)->Selection->Aggr}->Projection->Projection",
+		},
+		{
+			sql:   "select * from t k where k.b > (select avg(b) from t where t.c = k.c)",
+			first: "Join{DataScan(t)->DataScan(t)->Aggr}->Projection->Selection->Projection",
+			best:  "Join{DataScan(t)->DataScan(t)->Aggr}->Selection->Projection->Projection",
+		},
+		{
+			sql:   "select (select count(*) from t where t.a > k.a) from t k",
 			first: "DataScan(t)->Apply(DataScan(t)->Selection->Aggr->Projection->MaxOneRow)->Projection",
 			best:  "DataScan(t)->Apply(DataScan(t)->Selection->Aggr->Projection->MaxOneRow)->Projection",
 		},
 		{
 			sql:   "select a from t where exists(select 1 from t as x where x.a < t.a)",
+			first: "DataScan(t)->Apply(DataScan(t)->Selection->Exists)->Selection->Projection",
+			best:  "DataScan(t)->Apply(DataScan(t)->Selection->Exists)->Selection->Projection",
+		},
+		{
+			sql:   "select a from t where exists(select 1 from t as x where x.a < t.a) and t.b > 1",
+			first: "DataScan(t)->Apply(DataScan(t)->Selection->Exists)->Selection->Projection",
+			best:  "DataScan(t)->Selection->Apply(DataScan(t)->Selection->Exists)->Selection->Projection",
+		},
+		{
+			sql:   "select a from t where t.c < (select count(*) from t as x where x.a > t.a) and t.b > 1",
+			first: "DataScan(t)->Apply(DataScan(t)->Selection->Aggr->Projection->MaxOneRow)->Selection->Projection",
+			best:  "DataScan(t)->Selection->Apply(DataScan(t)->Selection->Aggr->Projection->MaxOneRow)->Selection->Projection",
+		},
+		{
+			sql:   "select a from t where exists(select 1 from t as x where x.a = t.a and x.b < t.b)",
 			first: "Join{DataScan(t)->DataScan(t)}->Projection",
 			best:  "Join{DataScan(t)->DataScan(t)}->Projection",
 		},
@@ -177,6 +365,7 @@ func (s *testPlanSuite) TestPredicatePushDown(c *C) {
 		builder := &planBuilder{
 			allocator: new(idAllocator),
 			ctx:       mock.NewContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
 		}
 		p := builder.build(stmt)
 		c.Assert(builder.err, IsNil)
@@ -192,6 +381,57 @@ func (s *testPlanSuite) TestPredicatePushDown(c *C) {
 	UseNewPlanner = false
 }
 
+func (s *testPlanSuite) TestNotNormalization(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql   string
+		conds []string
+	}{
+		{
+			sql:   "select * from t where not (a = 5)",
+			conds: []string{"!=(test.t.a,5,)"},
+		},
+		{
+			sql:   "select * from t where not (a < 5)",
+			conds: []string{">=(test.t.a,5,)"},
+		},
+		{
+			sql:   "select * from t where not not (b >= c)",
+			conds: []string{">=(test.t.b,test.t.c,)"},
+		},
+		{
+			sql:   "select * from t where not (a > 1 and b <= 2)",
+			conds: []string{"||(<=(test.t.a,1,),>(test.t.b,2,),)"},
+		},
+		{
+			sql:   "select * from t where not (a > 1 or b != 2)",
+			conds: []string{"<=(test.t.a,1,)", "=(test.t.b,2,)"},
+		},
+		{
+			sql:   "select * from t where not (b like 'abc')",
+			conds: []string{"!(like(test.t.b,abc,92,),)"},
+		},
+		{
+			sql:   "select * from t where not (a = 1 or b like 'abc')",
+			conds: []string{"!=(test.t.a,1,)", "!(like(test.t.b,abc,92,),)"},
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		sel, ok := p.GetChildByIndex(0).(*Selection)
+		c.Assert(ok, IsTrue, comment)
+		conds := make([]string, 0, len(sel.Conditions))
+		for _, cond := range sel.Conditions {
+			conds = append(conds, cond.ToString())
+		}
+		c.Assert(conds, DeepEquals, ca.conds, comment)
+	}
+	UseNewPlanner = false
+}
+
 func (s *testPlanSuite) TestJoinReOrder(c *C) {
 	UseNewPlanner = true
 	defer testleak.AfterTest(c)()
@@ -213,11 +453,11 @@ func (s *testPlanSuite) TestJoinReOrder(c *C) {
 		},
 		{
 			sql:  "select * from t t1, t t2, t t3, t t4, t t5 where t1.a = t5.a and t5.a = t4.a and t4.a = t3.a and t3.a = t2.a and t2.a = t1.a and t1.a = t3.a and t2.a = t4.a and t3.b = 1 and t4.a = 1",
-			best: "LeftHashJoin{LeftHashJoin{LeftHashJoin{LeftHashJoin{Table(t)->Selection->Table(t)}(t3.a,t4.a)->Table(t)}(t4.a,t5.a)->Table(t)}(t5.a,t1.a)(t3.a,t1.a)->Table(t)}(t3.a,t2.a)(t1.a,t2.a)(t4.a,t2.a)->Projection",
+			best: "RightHashJoin{RightHashJoin{RightHashJoin{LeftHashJoin{Table(t)->Selection->Table(t)}(t3.a,t4.a)->Table(t)}(t4.a,t5.a)->Table(t)}(t5.a,t1.a)(t3.a,t1.a)->Table(t)}(t3.a,t2.a)(t1.a,t2.a)(t4.a,t2.a)->Projection",
 		},
 		{
 			sql:  "select * from t o where o.b in (select t3.c from t t1, t t2, t t3 where t1.a = t3.a and t2.a = t3.a and t2.a = o.a)",
-			best: "Table(t)->Apply(LeftHashJoin{RightHashJoin{Table(t)->Selection->Table(t)}(t2.a,t3.a)->Table(t)}(t3.a,t1.a)->Projection)->Selection->Projection",
+			best: "Table(t)->Apply(LeftHashJoin{RightHashJoin{Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Table(t)}(t2.a,t3.a)->Table(t)}(t3.a,t1.a)->Projection)->Selection->Projection",
 		},
 	}
 	for _, ca := range cases {
@@ -250,6 +490,118 @@ func (s *testPlanSuite) TestJoinReOrder(c *C) {
 	UseNewPlanner = false
 }
 
+func (s *testPlanSuite) TestJoinConditionSimplification(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql       string
+		cartesian bool
+		best      string
+	}{
+		{
+			sql:       "select * from t t1 join t t2 on 1",
+			cartesian: true,
+			best:      "LeftHashJoin{Table(t)->Table(t)}->Projection",
+		},
+		{
+			sql:       "select * from t t1 join t t2 on 1 and t1.a = t2.a",
+			cartesian: false,
+			best:      "LeftHashJoin{Table(t)->Table(t)}(t1.a,t2.a)->Projection",
+		},
+		{
+			sql:       "select * from t t1 join t t2 on t1.b <=> t1.b and t1.c = t2.c",
+			cartesian: false,
+			best:      "LeftHashJoin{Table(t)->Table(t)}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:       "select * from t t1 join t t2 on t1.b = t1.b",
+			cartesian: true,
+			best:      "RightHashJoin{Table(t)->Selection->Table(t)}->Projection",
+		},
+		{
+			sql:       "select * from t t1 join t t2 on t1.c = 1",
+			cartesian: true,
+			best:      "RightHashJoin{Index(t.c_d_e)[[1,1]]->Table(t)}->Projection",
+		},
+		{
+			sql:       "select * from t t1 left join t t2 on 1",
+			cartesian: false,
+			best:      "LeftHashJoin{Table(t)->Table(t)}->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+		join := findJoin(lp)
+		c.Assert(join, NotNil, comment)
+		c.Assert(join.cartesianJoin, Equals, ca.cartesian, comment)
+
+		p = optimizeTestPlan(c, lp, comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestPushSelectionIntoJoin(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql    string
+		before string
+		best   string
+		others int
+	}{
+		{
+			sql:    "select * from t a join t b on a.a = b.a where a.c = 1 and b.d > 2 and a.e + b.e > 3",
+			before: "LeftHashJoin{Table(t)->Table(t)}(a.a,b.a)->Selection->Projection",
+			best:   "LeftHashJoin{Table(t)->Selection->Table(t)->Selection}(a.a,b.a)->Projection",
+			others: 1,
+		},
+		{
+			sql:    "select * from t a left join t b on a.a = b.a where a.c = 1 and b.d > 2",
+			before: "LeftHashJoin{Table(t)->Table(t)}(a.a,b.a)->Selection->Projection",
+			best:   "LeftHashJoin{Table(t)->Selection->Table(t)}(a.a,b.a)->Selection->Projection",
+			others: 0,
+		},
+		{
+			sql:    "select * from t a right join t b on a.a = b.a where a.c = 1 and b.d > 2",
+			before: "RightHashJoin{Table(t)->Table(t)}(a.a,b.a)->Selection->Projection",
+			best:   "RightHashJoin{Table(t)->Table(t)->Selection}(a.a,b.a)->Selection->Projection",
+			others: 0,
+		},
+		{
+			sql:    "select * from t a join t b on a.a = b.a join t c on b.b = c.b where a.c = 1 and c.d = 1",
+			before: "LeftHashJoin{LeftHashJoin{Table(t)->Table(t)}(a.a,b.a)->Table(t)}(b.b,c.b)->Selection->Projection",
+			best:   "LeftHashJoin{LeftHashJoin{Table(t)->Selection->Table(t)}(a.a,b.a)->Table(t)->Selection}(b.b,c.b)->Projection",
+			others: 0,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		// The predicates aren't pushed down in the logical plan, so the selection is left above the join.
+		_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
+		c.Assert(err, IsNil)
+		_, res, _, err := lp.convert2PhysicalPlan(nil)
+		c.Assert(err, IsNil)
+		c.Assert(ToString(res.p), Equals, ca.before, comment)
+		p, err = pushSelectionIntoJoin(res.p)
+		c.Assert(err, IsNil)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		join, ok := p.GetChildByIndex(0).(*PhysicalHashJoin)
+		if !ok {
+			join = p.GetChildByIndex(0).GetChildByIndex(0).(*PhysicalHashJoin)
+		}
+		c.Assert(join.OtherConditions, HasLen, ca.others, comment)
+	}
+	UseNewPlanner = false
+}
+
 func (s *testPlanSuite) TestCBO(c *C) {
 	UseNewPlanner = true
 	defer testleak.AfterTest(c)()
@@ -257,6 +609,18 @@ func (s *testPlanSuite) TestCBO(c *C) {
 		sql  string
 		best string
 	}{
+		{
+			sql:  "select (select count(*) from (select b, count(*) as c from t group by b) x where x.c > k.a) from t k",
+			best: "Table(t)->Apply(Table(t)->Aggr->Cache->Selection->Projection->Aggr->Limit->Projection->MaxOneRow)->Projection",
+		},
+		{
+			sql:  "select (select count(*) from t, (select b, max(a) as m from s group by b) x where t.b = x.m and t.c > k.c) from t k",
+			best: "Table(t)->Apply(LeftHashJoin{Table(t)->Selection->Table(s)->Aggr->Projection->Cache}(test.t.b,x.m)->Aggr->Limit->Projection->MaxOneRow)->Projection",
+		},
+		{
+			sql:  "select (select count(*) from t where t.c > k.c and t.b > 1) from t k",
+			best: "Table(t)->Apply(Table(t)->Selection->Aggr->Limit->Projection->MaxOneRow)->Projection",
+		},
 		{
 			sql:  "select * from t a where a.c = 1 order by a.d limit 2",
 			best: "Index(t.c_d_e)[[1,1]]->Projection",
@@ -278,56 +642,1850 @@ func (s *testPlanSuite) TestCBO(c *C) {
 			best: "LeftHashJoin{Table(t)->Projection->Table(t)->Projection}->Projection->Sort",
 		},
 		{
-			sql:  "select * from (select * from t) a right outer join (select * from t) b on 1 order by a.c",
-			best: "RightHashJoin{Table(t)->Projection->Table(t)->Projection}->Projection->Sort",
+			sql:  "select * from (select * from t) a right outer join (select * from t) b on 1 order by a.c",
+			best: "RightHashJoin{Table(t)->Projection->Table(t)->Projection}->Projection->Sort",
+		},
+		{
+			sql:  "select * from (select * from t) a right outer join (select * from t) b on 1 order by b.c",
+			best: "RightHashJoin{Table(t)->Projection->Index(t.c_d_e)[[<nil>,<nil>]]->Projection}->Projection",
+		},
+		{
+			sql:  "select * from t a where exists(select * from t b where a.a = b.a) and a.c = 1 order by a.d limit 3",
+			best: "SemiJoin{Index(t.c_d_e)[[1,1]]->Table(t)}->Limit->Projection",
+		},
+		{
+			sql:  "select exists(select * from t b where a.a = b.a and b.c = 1) from t a order by a.c limit 3",
+			best: "SemiJoinWithAux{Index(t.c_d_e)[[<nil>,<nil>]]->Index(t.c_d_e)[[1,1]]}->Projection->Trim",
+		},
+		{
+			sql:  "select * from t a where exists(select * from t b where b.a < a.a) order by a.c limit 3",
+			best: "Table(t)->Apply(Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Limit->Exists)->Selection->Projection->Sort + Limit(3) + Offset(0)",
+		},
+		{
+			sql:  "select exists(select * from t b where b.a < a.a) from t a order by a.c limit 3",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Apply(Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Limit->Exists)->Projection->Trim",
+		},
+		{
+			sql:  "select * from (select t.a from t union select t.d from t where t.c = 1 union select t.c from t) k order by a limit 1",
+			best: "UnionAll{Table(t)->Projection->Index(t.c_d_e)[[1,1]]->Projection->Index(t.c_d_e)[[<nil>,<nil>]]->Projection}->Distinct->Limit->Projection",
+		},
+		{
+			sql:  "select * from (select t.a from t union select t.d from t union select t.c from t) k order by a limit 1",
+			best: "UnionAll{Table(t)->Projection->Table(t)->Projection->Table(t)->Projection}->Distinct->Projection->Sort + Limit(1) + Offset(0)",
+		},
+		{
+			sql:  "select * from t ignore index for order by (c_d_e) where c = 1 order by d limit 2",
+			best: "Index(t.c_d_e)[[1,1]]->Projection->Sort + Limit(2) + Offset(0)",
+		},
+		{
+			sql:  "select * from t ignore index for order by (c_d_e) order by c limit 2",
+			best: "Table(t)->Projection->Sort + Limit(2) + Offset(0)",
+		},
+		{
+			sql:  "select * from t use index for order by () where c < 10000 order by a limit 2",
+			best: "Table(t)->Selection->Limit->Projection",
+		},
+		{
+			sql:  "select * from t use index for order by (c_d_e) where c < 10000 order by a limit 2",
+			best: "Index(t.c_d_e)[[<nil>,10000)]->Projection->Sort + Limit(2) + Offset(0)",
+		},
+		{
+			sql:  "select * from t force index for order by (c_d_e) where c < 10000 order by a limit 2",
+			best: "Index(t.c_d_e)[[<nil>,10000)]->Projection->Sort + Limit(2) + Offset(0)",
+		},
+		{
+			sql:  "select * from t use index for group by (c_d_e) where c < 10000 order by a limit 2",
+			best: "Table(t)->Selection->Limit->Projection",
+		},
+		{
+			sql:  "select * from t ignore index for group by (c_d_e) order by c limit 2",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection",
+		},
+		{
+			sql:  "select * from t ignore index for join (c_d_e) where c = 1 order by d limit 2",
+			best: "Table(t)->Selection->Projection->Sort + Limit(2) + Offset(0)",
+		},
+		{
+			sql:  "select * from t use index for join () where c = 1",
+			best: "Table(t)->Selection->Projection",
+		},
+		{
+			sql:  "select * from t ignore index for join (c_d_e) join s on t.c = s.b where t.c = 1",
+			best: "LeftHashJoin{Table(t)->Selection->Index(s.b)[[1,1]]}(test.t.c,test.s.b)->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		stmt, err := s.ParseOneStmt(ca.sql, "", "")
+		c.Assert(err, IsNil, comment)
+		ast.SetFlag(stmt)
+
+		err = newMockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mock.NewContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+		}
+		p := builder.build(stmt)
+		c.Assert(builder.err, IsNil)
+		lp := p.(LogicalPlan)
+
+		_, lp, err = lp.PredicatePushDown(nil)
+		c.Assert(err, IsNil)
+		_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
+		c.Assert(err, IsNil)
+		_, res, _, err := lp.convert2PhysicalPlan(nil)
+		c.Assert(err, IsNil)
+		p = res.p.PushLimit(nil)
+		c.Assert(ToString(p), Equals, ca.best, Commentf("for %s", ca.sql))
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestSemiJoin(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql       string
+		best      string
+		nullAware bool
+	}{
+		{
+			sql:  "select * from t where exists (select * from s where s.b = t.b)",
+			best: "SemiJoin{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:  "select * from t where not exists (select * from s where s.b = t.b)",
+			best: "AntiSemiJoin{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:       "select * from t where b in (select b from s)",
+			best:      "SemiJoin{Table(t)->Table(s)->Projection}->Projection",
+			nullAware: true,
+		},
+		{
+			sql:       "select * from t where b not in (select b from s)",
+			best:      "AntiSemiJoin{Table(t)->Table(s)->Projection}->Projection",
+			nullAware: true,
+		},
+		{
+			sql:  "select * from t where exists (select distinct b from s where s.b = t.b)",
+			best: "SemiJoin{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:  "select * from t where not exists (select distinct a from s where s.b = t.b order by a)",
+			best: "AntiSemiJoin{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:  "select exists (select * from s where s.b = t.b) from t",
+			best: "SemiJoinWithAux{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:  "select not exists (select * from s where s.b = t.b) from t",
+			best: "AntiSemiJoinWithAux{Table(t)->Table(s)}->Projection",
+		},
+		{
+			sql:       "select b in (select b from s) from t",
+			best:      "SemiJoinWithAux{Table(t)->Table(s)->Projection}->Projection",
+			nullAware: true,
+		},
+		{
+			sql:       "select * from t where b not in (select b from s) or c = 1",
+			best:      "AntiSemiJoinWithAux{Table(t)->Table(s)->Projection}->Selection->Projection",
+			nullAware: true,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		for len(p.GetChildren()) == 1 {
+			p = p.GetChildByIndex(0)
+		}
+		join, ok := p.(*PhysicalHashSemiJoin)
+		c.Assert(ok, IsTrue, comment)
+		c.Assert(join.NullAware, Equals, ca.nullAware, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestScalarSubquery(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	var subPlans []string
+	oldEvalSubquery := EvalSubquery
+	EvalSubquery = func(p PhysicalPlan, is infoschema.InfoSchema, ctx context.Context) ([]types.Datum, error) {
+		subPlans = append(subPlans, ToString(p))
+		return []types.Datum{types.NewIntDatum(1)}, nil
+	}
+	defer func() {
+		EvalSubquery = oldEvalSubquery
+	}()
+	cases := []struct {
+		sql  string
+		sub  string
+		best string
+	}{
+		{
+			sql:  "select * from t where b > (select avg(b) from t)",
+			sub:  "Table(t)->Aggr->Projection",
+			best: "Table(t)->Selection->Projection",
+		},
+		{
+			sql:  "select * from t where b > (select max(b) from t where c = 1)",
+			sub:  "Index(t.c_d_e)[[1,1]]->Aggr->Projection",
+			best: "Table(t)->Selection->Projection",
+		},
+		{
+			sql:  "select * from t where b > (select b from t)",
+			sub:  "Table(t)->Projection->MaxOneRow",
+			best: "Table(t)->Selection->Projection",
+		},
+		{
+			sql:  "select * from t where b > (select max(b) from t group by c)",
+			sub:  "Table(t)->Aggr->Limit->Projection->MaxOneRow",
+			best: "Table(t)->Selection->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		subPlans = subPlans[:0]
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		// The subquery is evaluated only once when building the plan.
+		c.Assert(subPlans, DeepEquals, []string{ca.sub}, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		c.Assert(subPlans, HasLen, 1, comment)
+
+		// The outer predicate references the result of the subquery as a constant.
+		sel := p.GetChildByIndex(0).(*Selection)
+		c.Assert(sel.Conditions, HasLen, 1, comment)
+		args := sel.Conditions[0].(*expression.ScalarFunction).Args
+		c.Assert(args, HasLen, 2, comment)
+		_, ok := args[1].(*expression.Constant)
+		c.Assert(ok, IsTrue, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestRowSubquery(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	var subPlans []string
+	oldEvalSubquery := EvalSubquery
+	EvalSubquery = func(p PhysicalPlan, is infoschema.InfoSchema, ctx context.Context) ([]types.Datum, error) {
+		subPlans = append(subPlans, ToString(p))
+		row := make([]types.Datum, 0, len(p.GetSchema()))
+		for range p.GetSchema() {
+			row = append(row, types.NewIntDatum(1))
+		}
+		return row, nil
+	}
+	defer func() {
+		EvalSubquery = oldEvalSubquery
+	}()
+	cases := []struct {
+		sql   string
+		subs  []string
+		best  string
+		conds int
+	}{
+		{
+			sql:   "select * from t where (c, d) = (select a, b from s where b = 1)",
+			subs:  []string{"Index(s.b)[[1,1]]->Projection->MaxOneRow"},
+			best:  "Index(t.c_d_e)[[1 1,1 1]]->Projection",
+			conds: 0,
+		},
+		{
+			// (b, c) != (1, 1) is b != 1 or c != 1.
+			sql:   "select * from t where (b, c) != (select a, b from s limit 1)",
+			subs:  []string{"Table(s)->Projection->MaxOneRow"},
+			best:  "Table(t)->Selection->Projection",
+			conds: 1,
+		},
+		{
+			sql:   "select * from t where (b, c) = (select a, b from s where s.b = t.d)",
+			subs:  nil,
+			best:  "Table(t)->Apply(Table(s)->Selection->Limit->Projection->MaxOneRow)->Selection->Projection",
+			conds: 2,
+		},
+		{
+			sql:   "select * from t where (select a, b from s where s.b = t.d) < (b, c)",
+			subs:  nil,
+			best:  "Table(t)->Apply(Table(s)->Selection->Limit->Projection->MaxOneRow)->Selection->Projection",
+			conds: 1,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		subPlans = nil
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		c.Assert(subPlans, DeepEquals, ca.subs, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		if sel, ok := p.GetChildByIndex(0).(*Selection); ok {
+			c.Assert(sel.Conditions, HasLen, ca.conds, comment)
+		} else {
+			c.Assert(ca.conds, Equals, 0, comment)
+		}
+	}
+
+	errCases := []string{
+		"select * from t where (b, c) = (select a from s limit 1)",
+		"select * from t where b = (select a, b from s limit 1)",
+		"select * from t where (b, c) = (select a from s where s.b = t.d)",
+		"select * from t where (b, c) > (select a, b, b from s limit 1)",
+		"select * from t where (b, c) <=> (1, 2, 3)",
+	}
+	for _, sql := range errCases {
+		_, err := s.buildTestPlan(c, sql, newTestBuilder())
+		c.Assert(terror.ErrorEqual(err, ErrSameColumns), IsTrue, Commentf("for %s, err %v", sql, err))
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestInSingleValue(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql string
+		// funcs are the names of the functions of the conditions.
+		funcs []string
+	}{
+		{
+			sql:   "select * from t where b in (5)",
+			funcs: []string{ast.EQ},
+		},
+		{
+			sql:   "select * from t where b in (select 5)",
+			funcs: []string{ast.EQ},
+		},
+		{
+			sql:   "select * from t where b not in (5)",
+			funcs: []string{ast.NE},
+		},
+		{
+			sql:   "select * from t where b not in (select 5)",
+			funcs: []string{ast.NE},
+		},
+		{
+			sql:   "select * from t where (b, c) in ((5, 6))",
+			funcs: []string{ast.EQ, ast.EQ},
+		},
+		{
+			sql:   "select * from t where (b, c) in (select 5, 6)",
+			funcs: []string{ast.EQ, ast.EQ},
+		},
+		{
+			sql:   "select * from t where b in (d)",
+			funcs: []string{ast.EQ},
+		},
+		{
+			sql:   "select * from t where b in (5, 6)",
+			funcs: []string{ast.In},
+		},
+		{
+			sql:   "select * from t where b not in (5, 6)",
+			funcs: []string{ast.UnaryNot},
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		c.Assert(ToString(p), Equals, "DataScan(t)->Selection->Projection", comment)
+		sel := p.GetChildByIndex(0).(*Selection)
+		funcs := make([]string, 0, len(sel.Conditions))
+		for _, cond := range sel.Conditions {
+			funcs = append(funcs, cond.(*expression.ScalarFunction).FuncName.L)
+		}
+		c.Assert(funcs, DeepEquals, ca.funcs, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestDo(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	var subPlans []string
+	oldEvalSubquery := EvalSubquery
+	EvalSubquery = func(p PhysicalPlan, is infoschema.InfoSchema, ctx context.Context) ([]types.Datum, error) {
+		subPlans = append(subPlans, ToString(p))
+		return []types.Datum{types.NewIntDatum(1)}, nil
+	}
+	defer func() {
+		EvalSubquery = oldEvalSubquery
+	}()
+	cases := []struct {
+		sql  string
+		subs []string
+		best string
+	}{
+		{
+			sql:  "do 1, 2",
+			best: "Dual->Projection->Do",
+		},
+		{
+			sql:  "do 1, (select count(*) from t)",
+			subs: []string{"Table(t)->Aggr->Projection"},
+			best: "Dual->Projection->Do",
+		},
+		{
+			sql:  "do (select b from t where a = 1) + 1, exists (select * from s where b > 1)",
+			subs: []string{"Table(t)->Projection->MaxOneRow", "Index(s.b)[(1,<nil>]]->Exists"},
+			best: "Dual->Projection->Do",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		subPlans = nil
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		c.Assert(subPlans, DeepEquals, ca.subs, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		// DO statement returns no result set.
+		c.Assert(p.GetSchema(), HasLen, 0, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestSetSubquery(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	var subPlans []string
+	oldEvalSubquery := EvalSubquery
+	EvalSubquery = func(p PhysicalPlan, is infoschema.InfoSchema, ctx context.Context) ([]types.Datum, error) {
+		subPlans = append(subPlans, ToString(p))
+		return []types.Datum{types.NewIntDatum(1)}, nil
+	}
+	defer func() {
+		EvalSubquery = oldEvalSubquery
+	}()
+	cases := []struct {
+		sql string
+		// values are the plans of the values with subqueries, by the positions of the assignments.
+		values map[int]string
+	}{
+		{
+			sql:    "set @a = (select count(*) from t)",
+			values: map[int]string{0: "Dual->Apply(Table(t)->Aggr->Limit->Projection->MaxOneRow->Cache)->Projection"},
+		},
+		{
+			sql:    "set @a = 1, @b = (select b from t where c > 1) + 1, names utf8",
+			values: map[int]string{1: "Dual->Apply(Table(t)->Selection->Limit->Projection->MaxOneRow->Cache)->Projection"},
+		},
+		{
+			sql:    "set @a = exists (select * from s where b > 1), autocommit = default",
+			values: map[int]string{0: "Dual->Apply(Index(s.b)[(1,<nil>]]->Exists->Cache)->Projection"},
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		subPlans = nil
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		set := p.(*Set)
+		c.Assert(set.Values, HasLen, len(ca.values), comment)
+		for i, best := range ca.values {
+			value, ok := set.Values[set.Stmt.Variables[i]]
+			c.Assert(ok, IsTrue, comment)
+			phyPlan, err := physicalOptimize(mock.NewContext(), value.(LogicalPlan))
+			c.Assert(err, IsNil, comment)
+			c.Assert(ToString(phyPlan), Equals, best, comment)
+		}
+		// The subqueries are evaluated when the values are executed, after the earlier variables are set.
+		c.Assert(subPlans, IsNil, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestUpdateSubquery(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	oldEvalSubquery := EvalSubquery
+	EvalSubquery = func(p PhysicalPlan, is infoschema.InfoSchema, ctx context.Context) ([]types.Datum, error) {
+		return []types.Datum{types.NewIntDatum(1)}, nil
+	}
+	defer func() {
+		EvalSubquery = oldEvalSubquery
+	}()
+	cases := []struct {
+		sql      string
+		assigned []int
+		best     string
+	}{
+		// The correlated aggregation is decorrelated into an outer join, which pads the unmatched rows with null.
+		{
+			sql:      "update t set b = (select max(b) from s where s.a = t.a)",
+			assigned: []int{1},
+			best:     "LeftHashJoin{Table(t)->Table(s)->Aggr}(test.t.a,aggregation_7_col_0)->Projection->Projection->Update",
+		},
+		{
+			sql:      "update t set b = (select b from s where s.a = t.a), c = 1 where d > 1",
+			assigned: []int{1, 2},
+			best:     "Table(t)->Selection->Apply(Table(s)->Selection->Limit->Projection->MaxOneRow)->Projection->Update",
+		},
+		{
+			sql:      "update t set e = exists (select * from s where s.a = t.a)",
+			assigned: []int{4},
+			best:     "SemiJoinWithAux{Table(t)->Table(s)}->Projection->Update",
+		},
+		{
+			sql:      "update t set b = (select max(b) from s) + 1 order by c limit 1",
+			assigned: []int{1},
+			best:     "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Update",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		var assigned []int
+		for i, assign := range p.(*NewUpdate).OrderedList {
+			if assign != nil {
+				assigned = append(assigned, i)
+			}
+		}
+		c.Assert(assigned, DeepEquals, ca.assigned, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestPrimaryKeyAccess(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql        string
+		best       string
+		doubleRead bool
+	}{
+		{
+			sql:  "select * from t where a = 1",
+			best: "Table(t)->Projection",
+		},
+		{
+			sql:  "select * from s where a = 1",
+			best: "Index(s.primary)[[1,1]]->Projection",
+			// Column b can only be fetched from the row.
+			doubleRead: true,
+		},
+		{
+			sql:  "select a from s where a = 1",
+			best: "Index(s.primary)[[1,1]]->Projection",
+		},
+		{
+			sql:  "select a, b from s where b = 1",
+			best: "Index(s.b)[[1,1]]->Projection",
+			// The primary key is not the handle, so index b doesn't cover it.
+			doubleRead: true,
+		},
+		{
+			sql:  "select a, c from t where c = 1",
+			best: "Index(t.c_d_e)[[1,1]]->Projection",
+			// The primary key is the handle, so every index covers it.
+		},
+		{
+			sql:        "select b, c from t where c = 1",
+			best:       "Index(t.c_d_e)[[1,1]]->Projection",
+			doubleRead: true,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		if is, ok := p.GetChildByIndex(0).(*PhysicalIndexScan); ok {
+			c.Assert(is.DoubleRead, Equals, ca.doubleRead, comment)
+		} else {
+			c.Assert(ca.doubleRead, IsFalse, comment)
+		}
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestLimitElimination(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+		// limit is the limit count pushed to the scan, -1 means no limit is pushed.
+		limit int64
+	}{
+		{
+			sql:   "select * from t where a = 1 limit 1",
+			best:  "Table(t)->Projection",
+			limit: -1,
+		},
+		{
+			sql:   "select * from t where a in (1, 2) limit 5",
+			best:  "Table(t)->Projection",
+			limit: -1,
+		},
+		{
+			sql:   "select * from t where a in (1, 2, 3) limit 2",
+			best:  "Table(t)->Projection",
+			limit: 2,
+		},
+		{
+			sql:   "select * from s where a = 1 limit 3",
+			best:  "Index(s.primary)[[1,1]]->Projection",
+			limit: -1,
+		},
+		{
+			sql:   "select * from s where a is null limit 3",
+			best:  "Index(s.primary)[[<nil>,<nil>]]->Projection",
+			limit: 3,
+		},
+		{
+			sql:   "select * from t where a = 1 limit 1, 1",
+			best:  "Table(t)->Limit->Projection",
+			limit: 2,
+		},
+		{
+			sql:   "select count(*) from t limit 1",
+			best:  "Table(t)->Aggr->Projection",
+			limit: -1,
+		},
+		{
+			sql:   "select * from t where c = 1 limit 1",
+			best:  "Index(t.c_d_e)[[1,1]]->Projection",
+			limit: 1,
+		},
+		{
+			// The outer limit is merged into the inner one, instead of replacing it.
+			sql:   "select * from (select * from t where c = 1 limit 1) k limit 2",
+			best:  "Index(t.c_d_e)[[1,1]]->Projection->Projection",
+			limit: 1,
+		},
+		{
+			sql:   "select * from (select * from t where c = 1 limit 2, 10) k limit 1, 5",
+			best:  "Index(t.c_d_e)[[1,1]]->Limit->Projection->Projection",
+			limit: 8,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+
+		for len(p.GetChildren()) > 0 {
+			p = p.GetChildByIndex(0)
+		}
+		var limitCount *int64
+		switch x := p.(type) {
+		case *PhysicalTableScan:
+			limitCount = x.LimitCount
+		case *PhysicalIndexScan:
+			limitCount = x.LimitCount
+		}
+		if ca.limit == -1 {
+			c.Assert(limitCount, IsNil, comment)
+		} else {
+			c.Assert(limitCount, NotNil, comment)
+			c.Assert(*limitCount, Equals, ca.limit, comment)
+		}
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestIndexTopN(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+		// limit is the limit count pushed to the index scan, which bounds the rows looked up by the handles,
+		// -1 means no limit is pushed.
+		limit int64
+	}{
+		{
+			sql:   "select b from t order by c limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Trim",
+			limit: 5,
+		},
+		{
+			sql:   "select b from t order by c desc limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Trim",
+			limit: 5,
+		},
+		{
+			sql:   "select b, c + 1 from t order by c, d limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Trim",
+			limit: 5,
+		},
+		{
+			sql:   "select b from t where c > 1 order by c limit 5",
+			best:  "Index(t.c_d_e)[(1,<nil>]]->Projection->Trim",
+			limit: 5,
+		},
+		{
+			sql:   "select b from t order by c limit 2, 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Limit->Projection->Trim",
+			limit: 7,
+		},
+		{
+			sql:   "select b from (select b, c from t) k order by c limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection->Trim",
+			limit: 5,
+		},
+		{
+			sql:   "select * from (select b, c from t order by c) k limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+			limit: 5,
+		},
+		{
+			sql:   "select * from s order by b desc limit 5",
+			best:  "Index(s.b)[[<nil>,<nil>]]->Projection",
+			limit: 5,
+		},
+		{
+			// The rows are filtered after they are looked up, so the limit can't be pushed to the index.
+			sql:   "select b from t where b > 1 order by c limit 5",
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Limit->Projection->Trim",
+			limit: -1,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+
+		for len(p.GetChildren()) > 0 {
+			p = p.GetChildByIndex(0)
+		}
+		is, ok := p.(*PhysicalIndexScan)
+		c.Assert(ok, IsTrue, comment)
+		c.Assert(is.DoubleRead, IsTrue, comment)
+		if ca.limit == -1 {
+			c.Assert(is.LimitCount, IsNil, comment)
+		} else {
+			c.Assert(is.LimitCount, NotNil, comment)
+			c.Assert(*is.LimitCount, Equals, ca.limit, comment)
+		}
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestFullScanForbidden(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	// The mock tables aren't analyzed, so they are taken to have the pseudo row count, which is 10000.
+	cases := []struct {
+		sql       string
+		limit     int64
+		forbidden bool
+	}{
+		{
+			sql:       "select * from t where b = 1",
+			limit:     100,
+			forbidden: true,
+		},
+		{
+			sql:       "select * from t where b = 1",
+			limit:     0,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t where b = 1",
+			limit:     10000,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t use index () where b = 1",
+			limit:     100,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t where a = 1",
+			limit:     100,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t where c = 1",
+			limit:     100,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t where c is not null",
+			limit:     100,
+			forbidden: true,
+		},
+		{
+			sql:       "select * from t, s where t.a = 1 and s.a = 1",
+			limit:     100,
+			forbidden: false,
+		},
+		{
+			sql:       "select * from t, s where t.a = 1 and s.b = t.b",
+			limit:     100,
+			forbidden: true,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		ctx := mock.NewContext()
+		variable.BindSessionVars(ctx)
+		err := variable.GetSessionVars(ctx).SetSystemVar(variable.TiDBFullScanRowLimit, types.NewIntDatum(ca.limit))
+		c.Assert(err, IsNil)
+		builder := newTestBuilder()
+		builder.ctx = ctx
+		p, err := s.buildTestPlan(c, ca.sql, builder)
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		err = checkFullScan(p)
+		if ca.forbidden {
+			c.Assert(ErrFullScanForbidden.Equal(err), IsTrue, comment)
+		} else {
+			c.Assert(err, IsNil, comment)
+		}
+	}
+	UseNewPlanner = false
+}
+
+type mockGlobalVars map[string]string
+
+func (m mockGlobalVars) GetGlobalSysVar(_ context.Context, name string) (string, error) {
+	return m[name], nil
+}
+
+func (m mockGlobalVars) SetGlobalSysVar(_ context.Context, name string, value string) error {
+	m[name] = value
+	return nil
+}
+
+func (s *testPlanSuite) TestCacheable(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql       string
+		best      string
+		cacheable bool
+	}{
+		{
+			sql:       "select a from t where c = @@auto_increment_increment",
+			best:      "Index(t.c_d_e)[[2,2]]->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where a > @@auto_increment_increment + 1",
+			best:      "Table(t)->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = @@global.auto_increment_increment",
+			best:      "Index(t.c_d_e)[[3,3]]->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 and b = @@auto_increment_increment",
+			best:      "Index(t.c_d_e)[[1,1]]->Selection->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 and b = ?",
+			best:      "Index(t.c_d_e)[[1,1]]->Selection->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a + ? from t where c = 1",
+			best:      "Index(t.c_d_e)[[1,1]]->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 order by b + ? limit 1",
+			best:      "Index(t.c_d_e)[[1,1]]->Projection->Sort + Limit(1) + Offset(0)->Trim",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 order by b limit 1",
+			best:      "Index(t.c_d_e)[[1,1]]->Projection->Sort + Limit(1) + Offset(0)->Trim",
+			cacheable: true,
+		},
+		{
+			sql:       "select a from t where c = @a",
+			best:      "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
+			cacheable: true,
+		},
+		{
+			sql:       "select a from t where c = 1 and b = @b",
+			best:      "Index(t.c_d_e)[[1,1]]->Selection->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 and d = ?",
+			best:      "Index(t.c_d_e)[]->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select a from t where c = 1 and d > 2",
+			best:      "Index(t.c_d_e)[(1 2,1 <nil>]]->Projection",
+			cacheable: true,
+		},
+		{
+			sql:       "select distinct b from t where c = 1 and d = ?",
+			best:      "Index(t.c_d_e)[]->Projection->Distinct",
+			cacheable: false,
+		},
+		{
+			sql:       "select distinct b from t where c = 1",
+			best:      "Index(t.c_d_e)[[1,1]]->Projection->Distinct",
+			cacheable: true,
+		},
+		{
+			sql:       "select b, count(*) from t where c = ? group by grouping sets ((b), ())",
+			best:      "Index(t.c_d_e)[]->Expand->Aggr->Projection",
+			cacheable: false,
+		},
+		{
+			sql:       "select (select count(*) from (select b, count(*) as c from t where d = ? group by b) x where x.c > k.a) from t k",
+			best:      "Table(t)->Apply(Table(t)->Selection->Aggr->Cache->Selection->Projection->Aggr->Limit->Projection->MaxOneRow)->Projection",
+			cacheable: false,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		ctx := mock.NewContext()
+		variable.BindSessionVars(ctx)
+		sessionVars := variable.GetSessionVars(ctx)
+		err := sessionVars.SetSystemVar("auto_increment_increment", types.NewStringDatum("2"))
+		c.Assert(err, IsNil)
+		sessionVars.Users["a"] = "1"
+		variable.BindGlobalVarAccessor(ctx, mockGlobalVars{"auto_increment_increment": "3"})
+		builder := newTestBuilder()
+		builder.ctx = ctx
+		p, err := s.buildTestPlan(c, ca.sql, builder)
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		c.Assert(Cacheable(p), Equals, ca.cacheable, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestGroupingSets(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+		err  bool
+	}{
+		{
+			sql:  "select b, c, count(*) from t group by grouping sets ((b), (c))",
+			best: "Table(t)->Expand->Aggr->Projection",
+		},
+		{
+			sql:  "select b, c, grouping(b), grouping(c) from t where d > 1 group by grouping sets ((b, c), (b), ())",
+			best: "Table(t)->Selection->Expand->Aggr->Projection",
+		},
+		{
+			sql:  "select b, sum(c) from t group by grouping sets ((b), ()) having b > 1",
+			best: "Table(t)->Expand->Selection->Aggr->Projection->Trim",
+		},
+		{
+			sql:  "select b, count(*) from t group by grouping sets ((b), ()) order by grouping(b), b",
+			best: "Table(t)->Expand->Aggr->Projection->Sort->Trim",
+		},
+		{
+			sql: "select b + 1, count(*) from t group by grouping sets ((b + 1), ())",
+			err: true,
+		},
+		{
+			sql: "select grouping(c) from t group by grouping sets ((b), ())",
+			err: true,
+		},
+		{
+			sql: "select grouping(b) from t group by b",
+			err: true,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		if ca.err {
+			c.Assert(err, NotNil, comment)
+			continue
+		}
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func findJoin(p Plan) *Join {
+	if join, ok := p.(*Join); ok {
+		return join
+	}
+	for _, child := range p.GetChildren() {
+		if join := findJoin(child); join != nil {
+			return join
+		}
+	}
+	return nil
+}
+
+func (s *testPlanSuite) TestRightUniqueJoin(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql    string
+		unique bool
+	}{
+		{
+			sql:    "select * from t left join (select b, count(*) as c from t group by b) x on t.b = x.b",
+			unique: true,
+		},
+		{
+			sql:    "select * from t left join (select b, c from t group by b, c) x on t.b = x.b",
+			unique: false,
+		},
+		{
+			sql:    "select * from t left join (select b, c from t group by b, c) x on t.b = x.b and t.c = x.c",
+			unique: true,
+		},
+		{
+			sql:    "select * from t left join (select distinct b from s) x on t.c = x.b",
+			unique: true,
+		},
+		{
+			sql:    "select * from t left join s on t.b = s.b",
+			unique: false,
+		},
+		{
+			sql:    "select * from t left join s on t.b = s.a",
+			unique: true,
+		},
+		{
+			sql:    "select * from s left join t on s.b = t.a and t.b > 1",
+			unique: true,
+		},
+		{
+			sql:    "select * from t left join s on t.b > s.a",
+			unique: false,
+		},
+		{
+			sql:    "select * from t join s on t.b = s.a",
+			unique: false,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		stmt, err := s.ParseOneStmt(ca.sql, "", "")
+		c.Assert(err, IsNil, comment)
+		err = newMockResolve(stmt)
+		c.Assert(err, IsNil, comment)
+		err = InferType(stmt)
+		c.Assert(err, IsNil, comment)
+
+		builder := newTestBuilder()
+		lp := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil, comment)
+		_, lp, err = lp.PredicatePushDown(nil)
+		c.Assert(err, IsNil)
+		join := findJoin(lp)
+		c.Assert(join, NotNil, comment)
+		c.Assert(join.rightUnique, Equals, ca.unique, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestSharedSubquery(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql    string
+		best   string
+		shared bool
+	}{
+		{
+			sql:    "select * from t where a in (select b from s where a > 1 group by b having count(*) > 1) and b in (select b from s where a > 1 group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim->Cache}->Table(s)->Selection->Aggr->Selection->Projection->Trim->Cache}->Projection",
+			shared: true,
+		},
+		{
+			sql:    "select * from t where a in (select b from s where a > 1 group by b having count(*) > 1) and b in (SELECT s.b FROM s WHERE s.a > 1 GROUP BY s.b HAVING COUNT(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim->Cache}->Table(s)->Selection->Aggr->Selection->Projection->Trim->Cache}->Projection",
+			shared: true,
+		},
+		{
+			sql:    "select * from t where a in (select b from s where a > 1 group by b having count(*) > 1) and b in (select b from s where a > 2 group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Projection",
+			shared: false,
+		},
+		{
+			sql:    "select * from t where a in (select b from s) and b in (select b from s)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Projection}->Table(s)->Projection}->Projection",
+			shared: false,
+		},
+		{
+			sql:    "select * from t where 1 in (select b from s where a > 1 group by b having count(*) > 1) and b in (select b from s where a > 1 group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Index(s.b)[[1,1]]->Selection->Aggr->Selection->Projection->Trim}->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Projection",
+			shared: false,
+		},
+		// The results of rand() are different.
+		{
+			sql:    "select * from t where a in (select b from s where a > rand() group by b having count(*) > 1) and b in (select b from s where a > rand() group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Projection",
+			shared: false,
+		},
+		// The results of the other functions in DynamicFuncs may be different too.
+		{
+			sql:    "select * from t where a in (select b from s where a > connection_id() group by b having count(*) > 1) and b in (select b from s where a > connection_id() group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Projection",
+			shared: false,
+		},
+		// The constants of different types aren't the same.
+		{
+			sql:    "select * from t where a in (select b from s where a > 1 group by b having count(*) > 1) and b in (select b from s where a > '1' group by b having count(*) > 1)",
+			best:   "SemiJoin{SemiJoin{Table(t)->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Table(s)->Selection->Aggr->Selection->Projection->Trim}->Projection",
+			shared: false,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+
+		var caches []Plan
+		findCaches(p, &caches)
+		if ca.shared {
+			// Each occurrence has its own cache, which share the result by the share id.
+			c.Assert(caches, HasLen, 2, comment)
+			c.Assert(caches[0], Not(Equals), caches[1], comment)
+			c.Assert(caches[0].(*PhysicalCache).ShareID, Equals, 1, comment)
+			c.Assert(caches[1].(*PhysicalCache).ShareID, Equals, 1, comment)
+		} else {
+			c.Assert(caches, HasLen, 0, comment)
+		}
+	}
+	UseNewPlanner = false
+}
+
+func findCaches(p Plan, caches *[]Plan) {
+	if _, ok := p.(*PhysicalCache); ok {
+		*caches = append(*caches, p)
+	}
+	for _, child := range p.GetChildren() {
+		findCaches(child, caches)
+	}
+}
+
+type mockFeedback struct {
+	tableID    int64
+	signature  string
+	factor     float64
+	signatures []string
+}
+
+func (f *mockFeedback) CorrectionFactor(tableID int64, signature string) (float64, bool) {
+	f.signatures = append(f.signatures, signature)
+	if tableID != f.tableID || signature != f.signature {
+		return 0, false
+	}
+	return f.factor, true
+}
+
+func (s *testPlanSuite) TestCardinalityFeedback(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql       string
+		signature string
+		factor    float64
+		best      string
+		count     uint64
+	}{
+		{
+			sql:       "select * from t where c > 1",
+			signature: ">(c,?,)",
+			factor:    1,
+			best:      "Table(t)->Selection->Projection",
+			count:     8000,
+		},
+		{
+			sql:       "select * from t where c > 1",
+			signature: ">(c,?,)",
+			factor:    0.5,
+			best:      "Index(t.c_d_e)[(1,<nil>]]->Projection",
+			count:     4000,
+		},
+		{
+			sql:       "select * from t where c > 1",
+			signature: "<(c,?,)",
+			factor:    0.5,
+			best:      "Table(t)->Selection->Projection",
+			count:     8000,
+		},
+		// The factor of a part of the conditions isn't applied.
+		{
+			sql:       "select * from t where c > 1 and d > 1",
+			signature: ">(c,?,)",
+			factor:    0.5,
+			best:      "Table(t)->Selection->Projection",
+			count:     8000,
+		},
+		// The filtered rows of the selection are corrected, but the rows scanned for the conditions on the index
+		// ranges aren't.
+		{
+			sql:       "select * from t where d > 1 and c > 1",
+			signature: ">(c,?,) and >(d,?,)",
+			factor:    0.5,
+			best:      "Table(t)->Selection->Projection",
+			count:     4000,
+		},
+		{
+			sql:       "select * from t where a > 1",
+			signature: ">(a,?,)",
+			factor:    0.5,
+			best:      "Table(t)->Projection",
+			count:     4000,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		feedback := &mockFeedback{signature: ca.signature, factor: ca.factor}
+		builder := newTestBuilder()
+		BindCardinalityFeedback(builder.ctx, feedback)
+		p, err := s.buildTestPlan(c, ca.sql, builder)
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		_, lp, err = lp.PredicatePushDown(nil)
+		c.Assert(err, IsNil, comment)
+		_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
+		c.Assert(err, IsNil, comment)
+		_, res, count, err := lp.convert2PhysicalPlan(nil)
+		c.Assert(err, IsNil, comment)
+		c.Assert(ToString(res.p.PushLimit(nil)), Equals, ca.best, comment)
+		c.Assert(count, Equals, ca.count, comment)
+		c.Assert(feedback.signatures, Not(HasLen), 0, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestHashAggGroupLimit(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql   string
+		limit uint64
+		best  string
+	}{
+		{
+			sql:   "select count(*) from t group by b",
+			limit: 0,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t group by b",
+			limit: 10000,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t group by b",
+			limit: 1000,
+			best:  "Table(t)->Sort->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t group by c, d",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t where c < 10000 group by a",
+			limit: 1000,
+			best:  "Table(t)->Selection->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t use index for group by (c_d_e) where c < 10000 group by a",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,10000)]->Sort->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t force index for group by (c_d_e) where c < 10000 group by a",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,10000)]->Sort->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t use index for order by (c_d_e) where c < 10000 group by a",
+			limit: 1000,
+			best:  "Table(t)->Selection->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t ignore index for order by (c_d_e) group by c, d",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t ignore index for group by (c_d_e) group by c, d",
+			limit: 1000,
+			best:  "Table(t)->Sort->StreamAggr->Projection",
+		},
+		{
+			sql:   "select count(*) from t group by b + 1",
+			limit: 1000,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select c, min(d) from t group by c",
+			limit: 0,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->GroupSkipAggr->Projection",
+		},
+		{
+			sql:   "select max(d), c from t group by c",
+			limit: 0,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->GroupSkipAggr->Projection",
+		},
+		{
+			sql:   "select c, min(d), max(d) from t group by c",
+			limit: 0,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select d, min(e) from t group by d",
+			limit: 0,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select c, min(e) from t group by c",
+			limit: 0,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select b, min(a) from s group by b",
+			limit: 0,
+			best:  "Table(s)->Aggr->Projection",
+		},
+		{
+			sql:   "select sql_small_result c, min(d) from t group by c",
+			limit: 0,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select sql_big_result count(*) from t group by b",
+			limit: 0,
+			best:  "Table(t)->Sort->StreamAggr->Projection",
+		},
+		{
+			sql:   "select sql_big_result count(*) from t group by c",
+			limit: 10000,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->StreamAggr->Projection",
+		},
+		{
+			sql:   "select sql_small_result count(*) from t group by b",
+			limit: 1000,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select sql_small_result count(distinct c, d) from t",
+			limit: 1000,
+			best:  "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:   "select distinct sql_big_result b from t",
+			limit: 1000,
+			best:  "Table(t)->Projection->Sort->StreamDistinct",
+		},
+		{
+			sql:   "select distinct sql_big_result c, d from t",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->StreamDistinct",
+		},
+		{
+			sql:   "select distinct sql_big_result c from t order by c",
+			limit: 1000,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->StreamDistinct",
+		},
+		{
+			sql:   "select distinct sql_small_result c, d from t",
+			limit: 1000,
+			best:  "Table(t)->Projection->Distinct",
+		},
+		{
+			sql:   "select distinct c, d from t",
+			limit: 1000,
+			best:  "Table(t)->Projection->Distinct",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		builder := newTestBuilder()
+		variable.BindSessionVars(builder.ctx)
+		err := variable.GetSessionVars(builder.ctx).SetSystemVar(variable.TiDBHashAggGroupLimit, types.NewUintDatum(ca.limit))
+		c.Assert(err, IsNil)
+		p, err := s.buildTestPlan(c, ca.sql, builder)
+		c.Assert(err, IsNil, comment)
+		best := optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(best), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestStreamDistinctCount(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select count(distinct c, d) from t",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->StreamDistinctAggr->Projection",
+		},
+		{
+			sql:  "select count(distinct d, c) from t where c > 1",
+			best: "Index(t.c_d_e)[(1,<nil>]]->StreamDistinctAggr->Projection",
+		},
+		{
+			sql:  "select count(distinct c, d) from t where a in (1, 2, 3)",
+			best: "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:  "select count(distinct b, e) from t",
+			best: "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:  "select count(distinct c + 1, d) from t",
+			best: "Table(t)->Aggr->Projection",
+		},
+		{
+			sql:  "select count(distinct c, d) from t group by b",
+			best: "Table(t)->Aggr->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		best := optimizeTestPlan(c, lp, comment)
+		c.Assert(ToString(best), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestProjectionOrder(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select x, y from (select c as x, d as y from t) k order by x, y limit 2",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+		},
+		{
+			sql:  "select * from (select c as x, d as y, e from t) k order by x desc, y desc",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+		},
+		{
+			sql:  "select * from (select c as x, d + 1 as y from t) k order by x",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+		},
+		{
+			sql:  "select * from (select c as x, d + 1 as y from t) k order by x, y",
+			best: "Table(t)->Projection->Projection->Sort",
+		},
+		{
+			sql:  "select * from (select c + 1 as x, d as y from t) k order by x",
+			best: "Table(t)->Projection->Projection->Sort",
+		},
+		{
+			sql:  "select * from (select c as x, c as y, d from t) k order by x, y, d",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+		},
+		{
+			sql:  "select * from (select c as x, 1 as y, d from t) k order by x, y, d",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection",
+		},
+		{
+			sql:  "select x from (select y as x from (select c as y from t) k1) k2 order by x",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Projection->Projection",
+		},
+		{
+			sql:  "select (select k.c as x from t order by x, t.c limit 1) from t k",
+			best: "Table(t)->Apply(Index(t.c_d_e)[[<nil>,<nil>]]->Projection->Trim->MaxOneRow)->Projection",
+		},
+		{
+			sql:  "select * from (select c as x, d as y from t) k order by y",
+			best: "Table(t)->Projection->Projection->Sort",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		best := optimizeTestPlan(c, lp, comment)
+		c.Assert(ToString(best), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+// mockRegionCounter counts the regions that the key space is split into by the keys.
+type mockRegionCounter []kv.Key
+
+func (m mockRegionCounter) RegionCount(startKey, endKey kv.Key) (int, error) {
+	regions := 1
+	for _, key := range m {
+		if key.Cmp(startKey) > 0 && key.Cmp(endKey) < 0 {
+			regions++
+		}
+	}
+	return regions, nil
+}
+
+func (s *testPlanSuite) TestCoprocessorTaskEstimate(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	// The rows of table t are in 4 regions, the rows and the primary key entries of table s are in 2 regions.
+	ctx := mock.NewContext()
+	BindRegionEstimator(ctx, NewRegionEstimator(mockRegionCounter{
+		tablecodec.EncodeRowKeyWithHandle(0, 10),
+		tablecodec.EncodeRowKeyWithHandle(0, 20),
+		tablecodec.EncodeRowKeyWithHandle(0, 30),
+		tablecodec.EncodeRowKeyWithHandle(1, 10),
+		tablecodec.EncodeIndexSeekKey(1, 1, []byte{0x80}),
+	}))
+	cases := []struct {
+		sql              string
+		best             string
+		tasks            int
+		noEstimatorTasks int
+	}{
+		{
+			sql:              "select * from t where a = 1",
+			best:             "Table(t)->Projection",
+			tasks:            1,
+			noEstimatorTasks: 1,
+		},
+		{
+			sql:              "select * from t where a in (1, 2, 3)",
+			best:             "Table(t)->Projection",
+			tasks:            3,
+			noEstimatorTasks: 1,
+		},
+		{
+			sql:              "select * from t where a > 1",
+			best:             "Table(t)->Projection",
+			tasks:            4,
+			noEstimatorTasks: 1,
+		},
+		{
+			sql:              "select * from t, s where t.a = s.a",
+			best:             "LeftHashJoin{Table(t)->Table(s)}(test.t.a,test.s.a)->Projection",
+			tasks:            6,
+			noEstimatorTasks: 2,
+		},
+		{
+			sql:              "select * from s where b = 1",
+			best:             "Index(s.b)[[1,1]]->Projection",
+			tasks:            3,
+			noEstimatorTasks: 2,
+		},
+		{
+			sql:              "select (select count(*) from s where s.a > t.a) from t",
+			best:             "Table(t)->Apply(Index(s.primary)[[<nil>,<nil>]]->Selection->Aggr->Limit->Projection->MaxOneRow)->Projection",
+			tasks:            6,
+			noEstimatorTasks: 2,
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		p = optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(p), Equals, ca.best, comment)
+		c.Assert(CoprocessorTaskEstimate(ctx, p), Equals, ca.tasks, comment)
+		c.Assert(CoprocessorTaskEstimate(mock.NewContext(), p), Equals, ca.noEstimatorTasks, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestRowInPointRanges(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select * from r where (a, b) in ((1, 2), (3, 4))",
+			best: "Index(r.primary)[[1 2,1 2] [3 4,3 4]]->Projection",
+		},
+		{
+			sql:  "select * from r where (b, a) in ((4, 3), (2, 1), (4, 3), (2, 1))",
+			best: "Index(r.primary)[[1 2,1 2] [3 4,3 4]]->Projection",
+		},
+		{
+			sql:  "select * from r where (a, b) in ((1, 2), (1, null)) and c > 1",
+			best: "Index(r.primary)[[1 2,1 2]]->Selection->Projection",
+		},
+		{
+			sql:  "select * from r where (a, c) in ((1, 2), (3, 4))",
+			best: "Table(r)->Selection->Projection",
+		},
+		{
+			sql:  "select * from t where (c, d, e) in ((1, 2, 3), (3, 4, 5), (1, 2, 3))",
+			best: "Index(t.c_d_e)[[1 2 3,1 2 3] [3 4 5,3 4 5]]->Projection",
+		},
+		{
+			sql:  "select * from t where (c, d) in ((1, 2), (3, b))",
+			best: "Table(t)->Selection->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		best := optimizeTestPlan(c, lp, comment)
+		c.Assert(ToString(best), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestHandlerRead(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql     string
+		handler *variable.Handler
+		best    string
+		desc    bool
+	}{
+		{
+			sql:  "handler t read c_d_e next",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]",
+		},
+		{
+			sql:     "handler t read c_d_e next",
+			handler: &variable.Handler{IndexName: "c_d_e", LastKey: types.MakeDatums(1, 2, 3, 4)},
+			best:    "Index(t.c_d_e)[[1,<nil>]]->Selection",
+		},
+		{
+			sql:     "handler t read c_d_e prev limit 2",
+			handler: &variable.Handler{IndexName: "c_d_e", LastKey: types.MakeDatums(1, 2, 3, 4)},
+			best:    "Index(t.c_d_e)[[<nil>,1]]->Selection",
+			desc:    true,
+		},
+		{
+			sql:     "handler t read c_d_e next",
+			handler: &variable.Handler{IndexName: "primary", LastKey: types.MakeDatums(1, 1)},
+			best:    "Index(t.c_d_e)[[<nil>,<nil>]]",
+		},
+		{
+			sql:     "handler t read next",
+			handler: &variable.Handler{IndexName: "primary", LastKey: types.MakeDatums(1, 1)},
+			best:    "Table(t)",
+		},
+		{
+			sql:  "handler t read c_d_e = (1)",
+			best: "Index(t.c_d_e)[[1,1]]",
+		},
+		{
+			sql:  "handler t read c_d_e = (1, 2) limit 3",
+			best: "Index(t.c_d_e)[[1 2,1 2]]",
+		},
+		{
+			sql:  "handler t read c_d_e >= (1, 2) where e > 3",
+			best: "Index(t.c_d_e)[[1,<nil>]]->Selection->Limit",
+		},
+		{
+			sql:  "handler t read c_d_e prev",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]",
+			desc: true,
+		},
+		{
+			sql:  "handler t read c_d_e < (5)",
+			best: "Index(t.c_d_e)[[<nil>,5)]",
+			desc: true,
+		},
+		{
+			sql:  "handler t read `primary` >= (3)",
+			best: "Table(t)",
+		},
+		{
+			sql:  "handler t read first where b > 1 limit 2",
+			best: "Table(t)->Selection->Limit",
+		},
+		{
+			sql:  "handler t read d_e next",
+			best: "[optimizer:10]Key 'd_e' doesn't exist in table 't'",
+		},
+		{
+			sql:  "handler t read c_d_e = (1, 2, 3, 4)",
+			best: "[optimizer:11]Too many key parts specified; max 3 parts allowed",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		builder := newTestBuilder()
+		variable.BindSessionVars(builder.ctx)
+		if ca.handler == nil {
+			ca.handler = &variable.Handler{}
+		}
+		variable.GetSessionVars(builder.ctx).Handlers["t"] = ca.handler
+		p, err := s.buildTestPlan(c, ca.sql, builder)
+		if err != nil {
+			c.Assert(err.Error(), Equals, ca.best, comment)
+			continue
+		}
+		np := optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(np), Equals, ca.best, comment)
+		for len(np.GetChildren()) > 0 {
+			np = np.GetChildByIndex(0).(PhysicalPlan)
+		}
+		switch x := np.(type) {
+		case *PhysicalIndexScan:
+			c.Assert(x.Desc, Equals, ca.desc, comment)
+		case *PhysicalTableScan:
+			c.Assert(x.Desc, Equals, ca.desc, comment)
+		}
+	}
+	builder := newTestBuilder()
+	variable.BindSessionVars(builder.ctx)
+	_, err := s.buildTestPlan(c, "handler t read first", builder)
+	c.Assert(err, NotNil)
+	c.Assert(err.Error(), Equals, "[optimizer:13]Unknown table 't' in HANDLER")
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestDescribePlanDOT(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql string
+		dot string
+	}{
+		{
+			sql: "select * from t t1 join t t2 on t1.a = t2.b",
+			dot: "digraph Plan {\n" +
+				"\tn0 [label=\"Projection\\nrows: 33333333\"];\n" +
+				"\tn1 [label=\"HashJoin\\nrows: 33333333\"];\n" +
+				"\tn2 [label=\"TableScan(t)\\nrows: 10000\"];\n" +
+				"\tn1 -> n2;\n" +
+				"\tn3 [label=\"TableScan(t)\\nrows: 10000\"];\n" +
+				"\tn1 -> n3;\n" +
+				"\tn0 -> n1;\n" +
+				"}\n",
+		},
+		{
+			sql: "select * from t where exists (select 1 from t x where x.a < t.a)",
+			dot: "digraph Plan {\n" +
+				"\tn0 [label=\"Projection\\nrows: 3333\"];\n" +
+				"\tn1 [label=\"Selection\\nrows: 3333\"];\n" +
+				"\tn2 [label=\"Apply\\nrows: 10000\"];\n" +
+				"\tn3 [label=\"TableScan(t)\\nrows: 10000\"];\n" +
+				"\tn2 -> n3;\n" +
+				"\tn4 [label=\"Exists\\nrows: 1\"];\n" +
+				"\tn5 [label=\"Limit\\nrows: 1\"];\n" +
+				"\tn6 [label=\"Selection\\nrows: 5333\"];\n" +
+				"\tn7 [label=\"IndexScan(t.c_d_e)\\nrows: 6667\"];\n" +
+				"\tn6 -> n7;\n" +
+				"\tn5 -> n6;\n" +
+				"\tn4 -> n5;\n" +
+				"\tn2 -> n4 [label=\"inner\"];\n" +
+				"\tn1 -> n2;\n" +
+				"\tn0 -> n1;\n" +
+				"}\n",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		dot, err := DescribePlanDOT(optimizeTestPlan(c, lp, comment))
+		c.Assert(err, IsNil)
+		c.Assert(dot, Equals, ca.dot, comment)
+	}
+
+	// The quotes and the backslashes in the names are escaped.
+	ts := &PhysicalTableScan{Table: &model.TableInfo{Name: model.NewCIStr(`a"b\c`)}}
+	dot, err := DescribePlanDOT(ts)
+	c.Assert(err, IsNil)
+	c.Assert(dot, Equals, "digraph Plan {\n\tn0 [label=\"TableScan(a\\\"b\\\\c)\\nrows: 0\"];\n}\n")
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestAtMostOneRow(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql   string
+		best  string
+		count uint64
+	}{
+		{
+			sql:   "select * from t where a = 1 order by b",
+			best:  "Table(t)->Projection",
+			count: 1,
 		},
 		{
-			sql:  "select * from (select * from t) a right outer join (select * from t) b on 1 order by b.c",
-			best: "RightHashJoin{Table(t)->Projection->Index(t.c_d_e)[[<nil>,<nil>]]->Projection}->Projection",
+			sql:   "select * from s where a = 1 order by b limit 5",
+			best:  "Index(s.primary)[[1,1]]->Projection",
+			count: 1,
 		},
 		{
-			sql:  "select * from t a where exists(select * from t b where a.a = b.a) and a.c = 1 order by a.d limit 3",
-			best: "SemiJoin{Index(t.c_d_e)[[1,1]]->Table(t)}->Limit->Projection",
+			sql:   "select * from s where b = 1 order by a limit 5",
+			best:  "Index(s.primary)[[<nil>,<nil>]]->Selection->Limit->Projection",
+			count: 5,
 		},
 		{
-			sql:  "select exists(select * from t b where a.a = b.a and b.c = 1) from t a order by a.c limit 3",
-			best: "SemiJoinWithAux{Index(t.c_d_e)[[<nil>,<nil>]]->Index(t.c_d_e)[[1,1]]}->Projection->Trim",
+			sql:   "select * from t join s on t.b = s.b where s.a = 1",
+			best:  "LeftHashJoin{Table(t)->Index(s.primary)[[1,1]]}(test.t.b,test.s.b)->Projection",
+			count: 3333,
 		},
 		{
-			sql:  "select * from (select t.a from t union select t.d from t where t.c = 1 union select t.c from t) k order by a limit 1",
-			best: "UnionAll{Table(t)->Projection->Index(t.c_d_e)[[1,1]]->Projection->Index(t.c_d_e)[[<nil>,<nil>]]->Projection}->Distinct->Limit->Projection",
+			sql:   "select * from t join s on t.b = s.b where s.b = 1",
+			best:  "LeftHashJoin{Table(t)->Selection->Index(s.b)[[1,1]]}(test.t.b,test.s.b)->Projection",
+			count: 21333333,
 		},
 		{
-			sql:  "select * from (select t.a from t union select t.d from t union select t.c from t) k order by a limit 1",
-			best: "UnionAll{Table(t)->Projection->Table(t)->Projection->Table(t)->Projection}->Distinct->Projection->Sort + Limit(1) + Offset(0)",
+			sql:   "select * from t join s on t.b = s.b where s.a = 1 and t.a = 2 order by t.c limit 3",
+			best:  "LeftHashJoin{Table(t)->Index(s.primary)[[1,1]]}(test.t.b,test.s.b)->Projection",
+			count: 0,
 		},
 	}
 	for _, ca := range cases {
 		comment := Commentf("for %s", ca.sql)
-		stmt, err := s.ParseOneStmt(ca.sql, "", "")
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
 		c.Assert(err, IsNil, comment)
-		ast.SetFlag(stmt)
-
-		err = newMockResolve(stmt)
-		c.Assert(err, IsNil)
-
-		builder := &planBuilder{
-			allocator: new(idAllocator),
-			ctx:       mock.NewContext(),
-			colMapper: make(map[*ast.ColumnNameExpr]int),
-		}
-		p := builder.build(stmt)
-		c.Assert(builder.err, IsNil)
 		lp := p.(LogicalPlan)
 
 		_, lp, err = lp.PredicatePushDown(nil)
 		c.Assert(err, IsNil)
 		_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
 		c.Assert(err, IsNil)
-		_, res, _, err := lp.convert2PhysicalPlan(nil)
+		_, res, count, err := lp.convert2PhysicalPlan(nil)
 		c.Assert(err, IsNil)
-		p = res.p.PushLimit(nil)
-		c.Assert(ToString(p), Equals, ca.best, Commentf("for %s", ca.sql))
+		c.Assert(ToString(res.p.PushLimit(nil)), Equals, ca.best, comment)
+		c.Assert(count, Equals, ca.count, comment)
 	}
 	UseNewPlanner = false
 }
@@ -357,7 +2515,7 @@ func (s *testPlanSuite) TestRefine(c *C) {
 		},
 		{
 			sql:  "select a from t where d <= 5 and d > 3",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
 		},
 		{
 			sql:  "select a from t where c <= 5 and c >= 3 and d = 1",
@@ -369,7 +2527,7 @@ func (s *testPlanSuite) TestRefine(c *C) {
 		},
 		{
 			sql:  "select a from t where c = 1 or c = 2 or c = 3 or c = 4 or c = 5",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[1,1] [2,2] [3,3] [4,4] [5,5]]->Projection",
 		},
 		{
 			sql:  "select a from t where c = 5",
@@ -385,7 +2543,19 @@ func (s *testPlanSuite) TestRefine(c *C) {
 		},
 		{
 			sql:  "select a from t where c in (1) and d > 3",
-			best: "Index(t.c_d_e)[[1,1]]->Selection->Projection",
+			best: "Index(t.c_d_e)[(1 3,1 <nil>]]->Projection",
+		},
+		{
+			sql:  "select a from t where c in (1, 2) and d > 3",
+			best: "Index(t.c_d_e)[[1,1] [2,2]]->Selection->Projection",
+		},
+		{
+			sql:  "select a from t where c in (select 1) and d > 3",
+			best: "Index(t.c_d_e)[(1 3,1 <nil>]]->Projection",
+		},
+		{
+			sql:  "select a from t where (c, d) in ((1, 2)) and e > 3",
+			best: "Index(t.c_d_e)[(1 2 3,1 2 <nil>]]->Projection",
 		},
 		{
 			sql:  "select a from t where c in (1, 2, 3)",
@@ -393,27 +2563,32 @@ func (s *testPlanSuite) TestRefine(c *C) {
 		},
 		{
 			sql:  "select a from t where d in (1, 2, 3)",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
 		},
 		{
+			// c not in (1) is c != 1.
 			sql:  "select a from t where c not in (1)",
 			best: "Table(t)->Selection->Projection",
 		},
+		{
+			sql:  "select a from t where c not in (1, 2)",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
+		},
 		{
 			sql:  "select a from t where c like 'abc'",
 			best: "Index(t.c_d_e)[[abc,abc]]->Projection",
 		},
 		{
 			sql:  "select a from t where c not like 'abc'",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
 		},
 		{
 			sql:  "select a from t where not (c like 'abc' or c like 'abd')",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
 		},
 		{
 			sql:  "select a from t where c like '_abc'",
-			best: "Table(t)->Selection->Projection",
+			best: "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
 		},
 		{
 			sql:  "select a from t where c like 'abc%'",
@@ -480,6 +2655,133 @@ func (s *testPlanSuite) TestRefine(c *C) {
 	UseNewPlanner = false
 }
 
+func (s *testPlanSuite) TestPropagateConstant(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select a from t where c = b and b = 10",
+			best: "Index(t.c_d_e)[[10,10]]->Selection->Projection",
+		},
+		{
+			sql:  "select b from s where a = b and b > 10 and b = 20",
+			best: "Index(s.primary)[[20,20]]->Selection->Projection",
+		},
+		{
+			sql:  "select a from t where c = d and d = e and e = 1",
+			best: "Index(t.c_d_e)[[1 1 1,1 1 1]]->Selection->Projection",
+		},
+		{
+			sql:  "select a from t where c = b + 1 and b = 10",
+			best: "Table(t)->Selection->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1, t t2 where t1.c = t2.b and t2.b = 3",
+			best: "LeftHashJoin{Index(t.c_d_e)[[3,3]]->Table(t)->Selection}(t1.c,t2.b)->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1 join t t2 on t1.c = t2.c and t1.c = 5",
+			best: "LeftHashJoin{Index(t.c_d_e)[[5,5]]->Index(t.c_d_e)[[5,5]]}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1 join s on t1.c = s.a and 5 = s.a",
+			best: "LeftHashJoin{Index(t.c_d_e)[[5,5]]->Index(s.primary)[[5,5]]}(t1.c,test.s.a)->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1 left join t t2 on t1.c = t2.c and t1.c = 5",
+			best: "LeftHashJoin{Table(t)->Index(t.c_d_e)[[5,5]]}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1 left join t t2 on t1.c = t2.c and t2.c = 5",
+			best: "LeftHashJoin{Table(t)->Index(t.c_d_e)[[5,5]]}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:  "select t1.a from t t1 right join t t2 on t1.c = t2.c and t1.c = 5",
+			best: "RightHashJoin{Index(t.c_d_e)[[5,5]]->Table(t)}(t1.c,t2.c)->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		np := optimizeTestPlan(c, p.(LogicalPlan), comment)
+		c.Assert(ToString(np), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
+// countConditions counts the conditions of the selections and the joins in the logical plan.
+func countConditions(p Plan) int {
+	count := 0
+	switch x := p.(type) {
+	case *Selection:
+		count = len(x.Conditions)
+	case *Join:
+		count = len(x.EqualConditions) + len(x.LeftConditions) + len(x.RightConditions) + len(x.OtherConditions)
+	}
+	for _, child := range p.GetChildren() {
+		count += countConditions(child)
+	}
+	return count
+}
+
+func (s *testPlanSuite) TestRemoveDupConditions(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql   string
+		conds int
+		best  string
+	}{
+		{
+			sql:   "select t1.a from t t1 join t t2 on t1.c = t2.c where t1.c = t2.c",
+			conds: 1,
+			best:  "LeftHashJoin{Table(t)->Table(t)}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:   "select t1.a from t t1 join t t2 on t1.c = t2.c and t1.d > t2.d where t2.c = t1.c and t1.d > t2.d",
+			conds: 2,
+			best:  "LeftHashJoin{Table(t)->Table(t)}(t1.c,t2.c)->Projection",
+		},
+		{
+			// The where condition repeats t1.c = 5, which implies t2.c = 5 with the ON conditions.
+			sql:   "select t1.a from t t1 join t t2 on t1.c = t2.c and t1.c = 5 where t1.c = 5",
+			conds: 3,
+			best:  "LeftHashJoin{Index(t.c_d_e)[[5,5]]->Index(t.c_d_e)[[5,5]]}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql:   "select a from t where b > 1 and c = 2 and b > 1",
+			conds: 2,
+			best:  "Index(t.c_d_e)[[2,2]]->Selection->Projection",
+		},
+		{
+			sql:   "select a from t where rand() > 0.5 and rand() > 0.5",
+			conds: 2,
+			best:  "Index(t.c_d_e)[[<nil>,<nil>]]->Selection->Projection",
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		lp := p.(LogicalPlan)
+
+		_, lp, err = lp.PredicatePushDown(nil)
+		c.Assert(err, IsNil)
+		c.Assert(countConditions(lp), Equals, ca.conds, comment)
+		_, err = lp.PruneColumnsAndResolveIndices(lp.GetSchema())
+		c.Assert(err, IsNil)
+		_, res, _, err := lp.convert2PhysicalPlan(nil)
+		c.Assert(err, IsNil)
+		np := res.p.PushLimit(nil)
+		c.Assert(ToString(np), Equals, ca.best, comment)
+	}
+	UseNewPlanner = false
+}
+
 func (s *testPlanSuite) TestColumnPruning(c *C) {
 	UseNewPlanner = true
 	defer testleak.AfterTest(c)()
@@ -538,28 +2840,28 @@ func (s *testPlanSuite) TestColumnPruning(c *C) {
 			sql: "select (select count(a) from t where b = k.a) from t k",
 			ans: map[string][]string{
 				"TableScan_1": {"a"},
-				"TableScan_2": {"a", "b"},
+				"TableScan_3": {"a", "b"},
 			},
 		},
 		{
 			sql: "select exists (select count(*) from t where b = k.a) from t k",
 			ans: map[string][]string{
 				"TableScan_1": {"a"},
-				"TableScan_2": {"b"},
+				"TableScan_3": {"b"},
 			},
 		},
 		{
 			sql: "select b = (select count(*) from t where b = k.a) from t k",
 			ans: map[string][]string{
 				"TableScan_1": {"a", "b"},
-				"TableScan_2": {"b"},
+				"TableScan_3": {"b"},
 			},
 		},
 		{
 			sql: "select exists (select count(a) from t where b = k.a) from t k",
 			ans: map[string][]string{
 				"TableScan_1": {"a"},
-				"TableScan_2": {"b"},
+				"TableScan_3": {"b"},
 			},
 		},
 		{
@@ -586,7 +2888,35 @@ func (s *testPlanSuite) TestColumnPruning(c *C) {
 			sql: "select a from t where (b,a) in (select c,d from t)",
 			ans: map[string][]string{
 				"TableScan_1": {"a", "b"},
-				"TableScan_2": {"c", "d"},
+				"TableScan_3": {"c", "d"},
+			},
+		},
+		{
+			sql: "select x.b from (select a, b, c from t union all select c, d, e from t) x",
+			ans: map[string][]string{
+				"TableScan_2": {"b"},
+				"TableScan_4": {"d"},
+			},
+		},
+		{
+			sql: "select x.b from (select a, b, c from t union all select c, d, e from t union all select e, a, b from t) x where x.c > 1",
+			ans: map[string][]string{
+				"TableScan_2": {"b", "c"},
+				"TableScan_4": {"d", "e"},
+				"TableScan_6": {"a", "b"},
+			},
+		},
+		{
+			sql: "select x.a from (select distinct a, b from t) x",
+			ans: map[string][]string{
+				"TableScan_1": {"a", "b"},
+			},
+		},
+		{
+			sql: "select x.b from (select a, b, c from t union select c, d, e from t) x",
+			ans: map[string][]string{
+				"TableScan_2": {"a", "b", "c"},
+				"TableScan_4": {"c", "d", "e"},
 			},
 		},
 	}
@@ -626,6 +2956,47 @@ func (s *testPlanSuite) TestAllocID(c *C) {
 	c.Assert(pA.id, Equals, pB.id)
 }
 
+func collectPlanIDs(p Plan, ids []string) []string {
+	ids = append(ids, p.GetID())
+	switch x := p.(type) {
+	case *Apply:
+		ids = collectPlanIDs(x.InnerPlan, ids)
+	case *PhysicalApply:
+		ids = collectPlanIDs(x.InnerPlan, ids)
+	}
+	for _, child := range p.GetChildren() {
+		ids = collectPlanIDs(child, ids)
+	}
+	return ids
+}
+
+func (s *testPlanSuite) TestStableID(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	sqls := []string{
+		"select * from t t1, t t2, t t3, t t4 where t1.a = t3.a and t2.b = t4.b",
+		"select * from t t1, t t2, t t3 where t1.a = t2.a and t1.a = t3.a and t2.b = 1",
+		"select * from t where exists (select s.a from s where s.b = t.b) and t.a in (select a from s)",
+		"select (select count(*) from t where t.a = k.a) from t k where k.b > 1",
+		"select a from t union select b from s order by a",
+		"select sum(a), count(distinct b) from t group by c having sum(a) > 1 order by count(b)",
+	}
+	for _, sql := range sqls {
+		comment := Commentf("for %s", sql)
+		var logicalIDs, physicalIDs [][]string
+		for i := 0; i < 2; i++ {
+			p, err := s.buildTestPlan(c, sql, newTestBuilder())
+			c.Assert(err, IsNil, comment)
+			lp := p.(LogicalPlan)
+			logicalIDs = append(logicalIDs, collectPlanIDs(lp, nil))
+			physicalIDs = append(physicalIDs, collectPlanIDs(optimizeTestPlan(c, lp, comment), nil))
+		}
+		c.Assert(logicalIDs[0], DeepEquals, logicalIDs[1], comment)
+		c.Assert(physicalIDs[0], DeepEquals, physicalIDs[1], comment)
+	}
+	UseNewPlanner = false
+}
+
 func (s *testPlanSuite) TestNewRangeBuilder(c *C) {
 	UseNewPlanner = true
 	defer testleak.AfterTest(c)()
@@ -889,9 +3260,10 @@ func (s *testPlanSuite) TestConstantFolding(c *C) {
 
 func check(p Plan, c *C, ans map[string][]string, comment CommentInterface) {
 	switch p.(type) {
-	case *PhysicalTableScan:
+	case *PhysicalTableScan, *DataSource:
 		colList, ok := ans[p.GetID()]
 		c.Assert(ok, IsTrue, comment)
+		c.Assert(len(p.GetSchema()), Equals, len(colList), comment)
 		for i, colName := range colList {
 			c.Assert(colName, Equals, p.GetSchema()[i].ColName.L, comment)
 		}
@@ -900,3 +3272,182 @@ func check(p Plan, c *C, ans map[string][]string, comment CommentInterface) {
 		check(child, c, ans, comment)
 	}
 }
+
+func (s *testPlanSuite) TestCheckTable(c *C) {
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql     string
+		indices [][]string
+	}{
+		{
+			sql:     "admin check table t",
+			indices: [][]string{{"c_d_e"}},
+		},
+		{
+			sql:     "admin check table s, t",
+			indices: [][]string{{"PRIMARY", "b"}, {"c_d_e"}},
+		},
+		{
+			sql:     "admin check index s b",
+			indices: [][]string{{"b"}},
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		var indices [][]string
+		for _, tableIndices := range p.(*CheckTable).Indices {
+			var names []string
+			for _, idx := range tableIndices {
+				names = append(names, idx.Name.O)
+			}
+			indices = append(indices, names)
+		}
+		c.Assert(indices, DeepEquals, ca.indices, comment)
+	}
+
+	stmt, err := s.ParseOneStmt("admin check index s c_d_e", "", "")
+	c.Assert(err, IsNil)
+	err = newMockResolve(stmt)
+	c.Assert(err, IsNil)
+	builder := newTestBuilder()
+	builder.build(stmt)
+	c.Assert(ErrKeyDoesNotExist.Equal(builder.err), IsTrue)
+}
+
+func (s *testPlanSuite) TestMaxExecutionTimeHint(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql      string
+		ms       uint64
+		warnings int
+	}{
+		{"select /*+ MAX_EXECUTION_TIME(1000) */ a from t", 1000, 0},
+		{"select /*+ max_execution_time(10) */ a from t where c = 1", 10, 0},
+		{"select /*+ MAX_EXECUTION_TIME(0) */ a from t", 0, 0},
+		{"select a from t", 0, 0},
+		{"select /*+ MAX_EXECUTION_TIME(-1) */ a from t", 0, 1},
+		{"select /*+ MAX_EXECUTION_TIME(abc) */ a from t", 0, 1},
+		{"select /*+ MAX_EXECUTION_TIME(1, 2) */ a from t", 0, 1},
+		{"select /*+ MAX_EXECUTION_TIME(10) MAX_EXECUTION_TIME(20) */ a from t", 10, 1},
+		{"select a from t where a in (select /*+ MAX_EXECUTION_TIME(10) */ a from s)", 0, 0},
+		{"insert /*+ MAX_EXECUTION_TIME(10) */ into t (a) values (1)", 0, 1},
+		{"update /*+ MAX_EXECUTION_TIME(10) */ t set b = 1", 0, 1},
+		{"delete /*+ MAX_EXECUTION_TIME(10) */ from t", 0, 1},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		stmt, err := s.ParseOneStmt(ca.sql, "", "")
+		c.Assert(err, IsNil, comment)
+		err = newMockResolve(stmt)
+		c.Assert(err, IsNil, comment)
+
+		ctx := mock.NewContext()
+		variable.BindSessionVars(ctx)
+		builder := newTestBuilder()
+		builder.ctx = ctx
+		p := builder.build(stmt)
+		c.Assert(builder.err, IsNil, comment)
+		p.SetMaxExecutionTime(maxExecutionTime(ctx, stmt))
+		c.Assert(p.MaxExecutionTime(), Equals, ca.ms, comment)
+		c.Assert(variable.GetSessionVars(ctx).GetWarnings(), HasLen, ca.warnings, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestJoinWildcard(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql  string
+		cols []string
+	}{
+		{
+			sql:  "select * from s join r",
+			cols: []string{"s.a", "s.b", "r.a", "r.b", "r.c"},
+		},
+		{
+			sql:  "select * from s join r using (a)",
+			cols: []string{"s.a", "s.b", "r.b", "r.c"},
+		},
+		{
+			sql:  "select * from s join r using (b)",
+			cols: []string{"s.b", "s.a", "r.a", "r.c"},
+		},
+		{
+			sql:  "select * from s natural join r",
+			cols: []string{"s.a", "s.b", "r.c"},
+		},
+		{
+			sql:  "select * from s left join r using (b)",
+			cols: []string{"s.b", "s.a", "r.a", "r.c"},
+		},
+		{
+			sql:  "select * from s right join r using (b)",
+			cols: []string{"r.b", "r.a", "r.c", "s.a"},
+		},
+		{
+			sql:  "select s.*, r.* from s join r using (a)",
+			cols: []string{"s.a", "s.b", "r.a", "r.b", "r.c"},
+		},
+		{
+			sql:  "select *, r.* from s natural join r",
+			cols: []string{"s.a", "s.b", "r.c", "r.a", "r.b", "r.c"},
+		},
+		{
+			sql:  "select * from s join r using (a) join t using (c)",
+			cols: []string{"r.c", "s.a", "s.b", "r.b", "t.a", "t.b", "t.d", "t.e"},
+		},
+		{
+			sql:  "select a, r.a from s join r using (a) where a > 1",
+			cols: []string{"a", "r.a"},
+		},
+		{
+			sql:  "select * from (select * from s join r using (a, b)) x",
+			cols: []string{"x.a", "x.b", "x.c"},
+		},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		p, err := s.buildTestPlan(c, ca.sql, newTestBuilder())
+		c.Assert(err, IsNil, comment)
+		var cols []string
+		for _, col := range p.GetSchema() {
+			cols = append(cols, col.ToString())
+		}
+		c.Assert(cols, DeepEquals, ca.cols, comment)
+	}
+	UseNewPlanner = false
+}
+
+func (s *testPlanSuite) TestJoinWildcardError(c *C) {
+	UseNewPlanner = true
+	defer testleak.AfterTest(c)()
+	cases := []struct {
+		sql string
+		err string
+	}{
+		{"select * from s join r using (c)", "[optimizer:9]Unknown column 'c' in 'from clause'"},
+		{"select * from s join r using (x)", "[optimizer:9]Unknown column 'x' in 'from clause'"},
+		{"select b from s join r using (a)", "column b is ambiguous."},
+		{"select * from s join r join t using (a)", "[optimizer:16]Column 'a' in from clause is ambiguous"},
+	}
+	for _, ca := range cases {
+		comment := Commentf("for %s", ca.sql)
+		stmt, err := s.ParseOneStmt(ca.sql, "", "")
+		c.Assert(err, IsNil, comment)
+		ast.SetFlag(stmt)
+
+		err = newMockResolve(stmt)
+		if err == nil {
+			builder := newTestBuilder()
+			builder.build(stmt)
+			err = builder.err
+		}
+		c.Assert(err, NotNil, comment)
+		c.Assert(err.Error(), Equals, ca.err, comment)
+	}
+	UseNewPlanner = false
+}
@@ -14,13 +14,20 @@
 package plan
 
 import (
+	"math"
+	"strings"
+
 	"github.com/juju/errors"
 	"github.com/ngaut/log"
 	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/terror"
+	"github.com/pingcap/tidb/util/types"
 )
 
 // Optimize does optimization and creates a Plan.
@@ -46,30 +53,190 @@ func Optimize(ctx context.Context, node ast.Node, sb SubQueryBuilder, is infosch
 		return nil, errors.Trace(builder.err)
 	}
 	if logic, ok := p.(LogicalPlan); UseNewPlanner && ok {
-		var err error
-		_, logic, err = logic.PredicatePushDown(nil)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		_, err = logic.PruneColumnsAndResolveIndices(p.GetSchema())
+		phyPlan, err := physicalOptimize(ctx, logic)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
-		_, res, _, err := logic.convert2PhysicalPlan(nil)
-		if err != nil {
-			return nil, errors.Trace(err)
+		p = phyPlan
+		if hr := builder.handlerRead; hr != nil {
+			addChild(hr, p)
+			hr.SetSchema(p.GetSchema())
+			p = hr
 		}
-		p = res.p.PushLimit(nil)
-		log.Debugf("[PLAN] %s", ToString(p))
+		p.SetMaxExecutionTime(maxExecutionTime(ctx, node))
 		return p, nil
 	}
+	if set, ok := p.(*Set); ok {
+		for v, value := range set.Values {
+			phyPlan, err := physicalOptimize(ctx, value.(LogicalPlan))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			set.Values[v] = phyPlan
+		}
+	}
 	err := Refine(p)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	p.SetMaxExecutionTime(maxExecutionTime(ctx, node))
+	return p, nil
+}
+
+// physicalOptimize converts the logical plan to the best physical plan, and checks the full scans and suggests
+// the indices for it.
+func physicalOptimize(ctx context.Context, logic LogicalPlan) (PhysicalPlan, error) {
+	schema := logic.GetSchema()
+	_, logic, err := logic.PredicatePushDown(nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	_, err = logic.PruneColumnsAndResolveIndices(schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	_, res, _, err := logic.convert2PhysicalPlan(nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	phyPlan, err := pushSelectionIntoJoin(res.p)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p := phyPlan.PushLimit(nil)
+	log.Debugf("[PLAN] %s", ToString(p))
+	if err = checkFullScan(p); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if sessionVars := variable.GetSessionVars(ctx); sessionVars != nil {
+		suggestIndexes(sessionVars, p)
+	}
 	return p, nil
 }
 
+// checkFullScan returns ErrFullScanForbidden if a table that isn't allowed to be fully scanned is scanned
+// with a full range in the physical plan.
+func checkFullScan(p Plan) error {
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		if x.forbidFullScan && isFullTableRange(x.Ranges) {
+			return ErrFullScanForbidden.Gen("Full scan on table %s is forbidden by %s", x.Table.Name, variable.TiDBFullScanRowLimit)
+		}
+	case *PhysicalIndexScan:
+		if !x.forbidFullScan {
+			return nil
+		}
+		for _, rg := range x.Ranges {
+			low, high := rg.LowVal[0].Kind(), rg.HighVal[0].Kind()
+			if (low == types.KindNull || low == types.KindMinNotNull) && high == types.KindMaxValue {
+				return ErrFullScanForbidden.Gen("Full scan on table %s is forbidden by %s", x.Table.Name, variable.TiDBFullScanRowLimit)
+			}
+		}
+	case *PhysicalApply:
+		if err := checkFullScan(x.InnerPlan); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, child := range p.GetChildren() {
+		if err := checkFullScan(child); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// suggestIndexes appends a warning for the table that is fully scanned and filtered by some selective conditions
+// on unindexed columns, i.e. the equal or range conditions between a column and a constant, recommending
+// an index on these columns.
+func suggestIndexes(sessionVars *variable.SessionVars, p Plan) {
+	switch x := p.(type) {
+	case *Selection:
+		if ts, ok := x.GetChildByIndex(0).(*PhysicalTableScan); ok && isFullTableRange(ts.Ranges) {
+			if cols := unindexedFilterColumns(x.Conditions, ts.Table); len(cols) > 0 {
+				sessionVars.AppendWarning(ErrMissingIndex.Gen("Full scan on table %s, an index on (%s) may help",
+					ts.Table.Name, strings.Join(cols, ", ")))
+			}
+		}
+	case *PhysicalApply:
+		suggestIndexes(sessionVars, x.InnerPlan)
+	}
+	for _, child := range p.GetChildren() {
+		suggestIndexes(sessionVars, child)
+	}
+}
+
+func isFullTableRange(ranges []TableRange) bool {
+	for _, rg := range ranges {
+		if rg.LowVal == math.MinInt64 && rg.HighVal == math.MaxInt64 {
+			return true
+		}
+	}
+	return false
+}
+
+// unindexedFilterColumns returns the columns which are compared with constants in the conditions, but aren't
+// the first column of any index. The columns compared by equal conditions are put in front, followed by the
+// first column compared by a range condition, so that an index on them can be used by all these conditions.
+func unindexedFilterColumns(conditions []expression.Expression, table *model.TableInfo) []string {
+	var eqCols []string
+	rangeCol := ""
+	for _, cond := range conditions {
+		f, ok := cond.(*expression.ScalarFunction)
+		if !ok || len(f.Args) != 2 {
+			continue
+		}
+		col, lOK := f.Args[0].(*expression.Column)
+		_, rOK := f.Args[1].(*expression.Constant)
+		if !lOK || !rOK {
+			col, lOK = f.Args[1].(*expression.Column)
+			_, rOK = f.Args[0].(*expression.Constant)
+		}
+		if !lOK || !rOK || col.Correlated || isIndexedColumn(table, col.ColName) {
+			continue
+		}
+		switch f.FuncName.L {
+		case ast.EQ:
+			if !containsString(eqCols, col.ColName.O) {
+				eqCols = append(eqCols, col.ColName.O)
+			}
+		case ast.LT, ast.LE, ast.GT, ast.GE:
+			if rangeCol == "" {
+				rangeCol = col.ColName.O
+			}
+		}
+	}
+	if rangeCol != "" && !containsString(eqCols, rangeCol) {
+		return append(eqCols, rangeCol)
+	}
+	return eqCols
+}
+
+// isIndexedColumn checks if the column is the integer primary key handle or the first column of an index.
+func isIndexedColumn(table *model.TableInfo, name model.CIStr) bool {
+	if table.PKIsHandle {
+		for _, colInfo := range table.Columns {
+			if mysql.HasPriKeyFlag(colInfo.Flag) && colInfo.Name.L == name.L {
+				return true
+			}
+		}
+	}
+	for _, index := range table.Indices {
+		if index.Columns[0].Name.L == name.L {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
 // PrepareStmt prepares a raw statement parsed from parser.
 // The statement must be prepared before it can be passed to optimize function.
 // We pass InfoSchema instead of getting from Context in case it is changed after resolving name.
@@ -92,6 +259,16 @@ const (
 	CodeUnsupported         terror.ErrCode = 4
 	CodeInvalidGroupFuncUse terror.ErrCode = 5
 	CodeIllegalReference    terror.ErrCode = 6
+	CodeFullScanForbidden   terror.ErrCode = 7
+	CodeMissingIndex        terror.ErrCode = 8
+	CodeUnknownColumn       terror.ErrCode = 9
+	CodeKeyDoesNotExist     terror.ErrCode = 10
+	CodeTooManyKeyParts     terror.ErrCode = 11
+	CodeInvalidHint         terror.ErrCode = 12
+	CodeUnknownTable        terror.ErrCode = 13
+	CodeUnsupportedHint     terror.ErrCode = 14
+	CodeConflictingHint     terror.ErrCode = 15
+	CodeAmbiguousColumn     terror.ErrCode = 16
 )
 
 // Optimizer base errors.
@@ -102,6 +279,16 @@ var (
 	ErrUnSupported         = terror.ClassOptimizer.New(CodeUnsupported, "unsupported")
 	ErrInvalidGroupFuncUse = terror.ClassOptimizer.New(CodeInvalidGroupFuncUse, "Invalid use of group function")
 	ErrIllegalReference    = terror.ClassOptimizer.New(CodeIllegalReference, "Illegal reference")
+	ErrFullScanForbidden   = terror.ClassOptimizer.New(CodeFullScanForbidden, "Full scan is forbidden")
+	ErrMissingIndex        = terror.ClassOptimizer.New(CodeMissingIndex, "Missing index")
+	ErrUnknownColumn       = terror.ClassOptimizer.New(CodeUnknownColumn, "Unknown column")
+	ErrKeyDoesNotExist     = terror.ClassOptimizer.New(CodeKeyDoesNotExist, "Key does not exist")
+	ErrTooManyKeyParts     = terror.ClassOptimizer.New(CodeTooManyKeyParts, "Too many key parts specified")
+	ErrInvalidHint         = terror.ClassOptimizer.New(CodeInvalidHint, "Invalid optimizer hint")
+	ErrUnknownTable        = terror.ClassOptimizer.New(CodeUnknownTable, "Unknown table")
+	ErrUnsupportedHint     = terror.ClassOptimizer.New(CodeUnsupportedHint, "Unsupported optimizer hint")
+	ErrConflictingHint     = terror.ClassOptimizer.New(CodeConflictingHint, "Conflicting optimizer hint")
+	ErrAmbiguousColumn     = terror.ClassOptimizer.New(CodeAmbiguousColumn, "Column is ambiguous")
 )
 
 func init() {
@@ -111,6 +298,16 @@ func init() {
 		CodeMultiWildCard:       mysql.ErrParse,
 		CodeInvalidGroupFuncUse: mysql.ErrInvalidGroupFuncUse,
 		CodeIllegalReference:    mysql.ErrIllegalReference,
+		CodeUnknownColumn:       mysql.ErrBadField,
+		CodeFullScanForbidden:   mysql.ErrTooBigSelect,
+		CodeMissingIndex:        mysql.ErrUnknown,
+		CodeKeyDoesNotExist:     mysql.ErrKeyDoesNotExits,
+		CodeTooManyKeyParts:     mysql.ErrTooManyKeyParts,
+		CodeInvalidHint:         mysql.ErrParse,
+		CodeUnknownTable:        mysql.ErrUnknownTable,
+		CodeUnsupportedHint:     mysql.ErrWarnUnsupportedMaxExecutionTime,
+		CodeConflictingHint:     mysql.ErrWarnConflictingHint,
+		CodeAmbiguousColumn:     mysql.ErrNonUniq,
 	}
 	terror.ErrClassToMySQLCodes[terror.ClassOptimizer] = mySQLErrCodes
 }
@@ -17,6 +17,7 @@ import (
 	"math"
 
 	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
@@ -66,14 +67,37 @@ func getRowCountByIndexRange(table *statistics.Table, indexRange *IndexRange, in
 	return uint64(count), nil
 }
 
+// isCoveringIndex checks if all the columns can be read from the index without looking up the table.
+// If the integer primary key is the handle, the table is clustered by it, and every index carries the handle,
+// so the primary key column is covered by every index.
+func isCoveringIndex(columns []*model.ColumnInfo, indexColumns []*model.IndexColumn, pkIsHandle bool) bool {
+	for _, colInfo := range columns {
+		if pkIsHandle && mysql.HasPriKeyFlag(colInfo.Flag) {
+			continue
+		}
+		isIndexColumn := false
+		for _, indexCol := range indexColumns {
+			if colInfo.Name.L == indexCol.Name.L && indexCol.Length == types.UnspecifiedLength {
+				isIndexColumn = true
+				break
+			}
+		}
+		if !isIndexColumn {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *DataSource) handleTableScan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, error) {
 	table := p.Table
 	var resultPlan PhysicalPlan
 	ts := &PhysicalTableScan{
-		Table:       p.Table,
-		Columns:     p.Columns,
-		TableAsName: p.TableAsName,
-		DBName:      p.DBName,
+		Table:          p.Table,
+		Columns:        p.Columns,
+		TableAsName:    p.TableAsName,
+		DBName:         p.DBName,
+		forbidFullScan: p.forbidFullScan,
 	}
 	ts.SetSchema(p.GetSchema())
 	resultPlan = ts
@@ -132,6 +156,18 @@ func (p *DataSource) handleTableScan(prop requiredProperty) (*physicalPlanInfo,
 			rowCount += uint64(cnt)
 		}
 	}
+	if resultPlan == ts {
+		// All the conditions are used to build the ranges, so the scan returns the filtered rows.
+		rowCount = p.correctRowCount(rowCount)
+	}
+	// Each point range of the handle matches at most one row.
+	if cnt, ok := maxRowCount(ts); ok && cnt < rowCount {
+		rowCount = cnt
+	}
+	ts.setRowCount(rowCount)
+	if resultPlan != ts {
+		resultPlan.setRowCount(uint64(float64(rowCount) * selectionFactor))
+	}
 	rowCounts := []uint64{rowCount}
 	return resultPlan.matchProperty(prop, rowCounts), resultPlan.matchProperty(nil, rowCounts), nil
 }
@@ -140,12 +176,13 @@ func (p *DataSource) handleIndexScan(prop requiredProperty, index *model.IndexIn
 	statsTbl := p.statisticTable
 	var resultPlan PhysicalPlan
 	is := &PhysicalIndexScan{
-		Index:       index,
-		Table:       p.Table,
-		Columns:     p.Columns,
-		TableAsName: p.TableAsName,
-		OutOfOrder:  true,
-		DBName:      p.DBName,
+		Index:          index,
+		Table:          p.Table,
+		Columns:        p.Columns,
+		TableAsName:    p.TableAsName,
+		OutOfOrder:     true,
+		DBName:         p.DBName,
+		forbidFullScan: p.forbidFullScan,
 	}
 	is.SetSchema(p.schema)
 	rowCount := uint64(statsTbl.Count)
@@ -177,16 +214,17 @@ func (p *DataSource) handleIndexScan(prop requiredProperty, index *model.IndexIn
 		rb := rangeBuilder{}
 		is.Ranges = rb.buildIndexRanges(fullRange)
 	}
-	for _, colInfo := range is.Columns {
-		for _, indexCol := range is.Index.Columns {
-			if colInfo.Name.L != indexCol.Name.L || indexCol.Length != types.UnspecifiedLength {
-				is.DoubleRead = true
-				break
-			}
-		}
-		if is.DoubleRead {
-			break
-		}
+	is.DoubleRead = !isCoveringIndex(is.Columns, is.Index.Columns, is.Table.PKIsHandle)
+	if resultPlan == is {
+		rowCount = p.correctRowCount(rowCount)
+	}
+	// Each point range of a unique index matches at most one row.
+	if cnt, ok := maxRowCount(is); ok && cnt < rowCount {
+		rowCount = cnt
+	}
+	is.setRowCount(rowCount)
+	if resultPlan != is {
+		resultPlan.setRowCount(uint64(float64(rowCount) * selectionFactor))
 	}
 	rowCounts := []uint64{rowCount}
 	return resultPlan.matchProperty(prop, rowCounts), resultPlan.matchProperty(nil, rowCounts), nil
@@ -198,23 +236,40 @@ func (p *DataSource) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanI
 	if sortedRes != nil {
 		return sortedRes, unsortedRes, cnt, nil
 	}
-	indices, includeTableScan := availableIndices(p.table)
-	var err error
-	if includeTableScan {
-		sortedRes, unsortedRes, err = p.handleTableScan(prop)
+	// Index hints FOR JOIN restrict the access paths which are used to find rows. Index hints FOR ORDER BY
+	// and FOR GROUP BY only restrict the access paths which are used to eliminate the sorting required by
+	// their own clause, the access paths used for filtering are not affected.
+	indices, includeTableScan := availableIndices(p.table, ast.HintForScan, ast.HintForJoin)
+	sortedIndices, sortedIncludeTableScan := indices, includeTableScan
+	if len(prop) > 0 {
+		sortedIndices, sortedIncludeTableScan = availableIndices(p.table, ast.HintForScan, ast.HintForJoin, prop.scope())
+	}
+	if includeTableScan || sortedIncludeTableScan {
+		sortedTsRes, unsortedTsRes, err := p.handleTableScan(prop)
 		if err != nil {
 			return nil, nil, 0, errors.Trace(err)
 		}
+		if sortedIncludeTableScan {
+			sortedRes = sortedTsRes
+		}
+		if includeTableScan {
+			unsortedRes = unsortedTsRes
+		}
 	}
-	for _, index := range indices {
+	for _, index := range p.table.TableInfo.Indices {
+		usedForSort := findIndexByName(sortedIndices, index.Name) != nil
+		usedForFilter := findIndexByName(indices, index.Name) != nil
+		if !usedForSort && !usedForFilter {
+			continue
+		}
 		sortedIsRes, unsortedIsRes, err := p.handleIndexScan(prop, index)
 		if err != nil {
 			return nil, nil, 0, errors.Trace(err)
 		}
-		if sortedRes == nil || sortedIsRes.cost < sortedRes.cost {
+		if usedForSort && (sortedRes == nil || sortedIsRes.cost < sortedRes.cost) {
 			sortedRes = sortedIsRes
 		}
-		if unsortedRes == nil || unsortedIsRes.cost < unsortedRes.cost {
+		if usedForFilter && (unsortedRes == nil || unsortedIsRes.cost < unsortedRes.cost) {
 			unsortedRes = unsortedIsRes
 		}
 	}
@@ -223,9 +278,12 @@ func (p *DataSource) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanI
 	return sortedRes, unsortedRes, uint64(statsTbl.Count), nil
 }
 
-func addPlanToResponse(p PhysicalPlan, planInfo *physicalPlanInfo) *physicalPlanInfo {
+// addPlanToResponse returns the plan info of a copy of p over the plan of planInfo, the copy is estimated to output
+// count rows.
+func addPlanToResponse(p PhysicalPlan, planInfo *physicalPlanInfo, count uint64) *physicalPlanInfo {
 	np := p.Copy()
 	np.SetChildren(planInfo.p)
+	np.setRowCount(count)
 	return &physicalPlanInfo{p: np, cost: planInfo.cost}
 }
 
@@ -243,12 +301,27 @@ func (p *Limit) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo,
 	if p.Offset+p.Count < count {
 		count = p.Offset + p.Count
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
+// materialize puts the plan of an occurrence of the subquery under a cache, which shares one materialized result
+// with the caches of the other occurrences. The result is materialized only when the cost of executing it again for
+// the other occurrences exceeds the cost of holding its rows.
+func (s *sharedSubquery) materialize(info *physicalPlanInfo, count uint64) *physicalPlanInfo {
+	if s.refs < 2 || float64(s.refs-1)*info.cost <= memoryFactor*float64(count) {
+		return info
+	}
+	cache := &PhysicalCache{ShareID: s.id}
+	cache.SetSchema(info.p.GetSchema())
+	cache.setRowCount(count)
+	cache.SetChildren(info.p)
+	info.p.SetParents(cache)
+	return &physicalPlanInfo{p: cache, cost: info.cost}
+}
+
 func estimateJoinCount(lc uint64, rc uint64) uint64 {
 	return lc * rc / 3
 }
@@ -268,6 +341,7 @@ func (p *Join) handleLeftJoin(prop requiredProperty, innerJoin bool) (*physicalP
 		RightConditions: p.RightConditions,
 		OtherConditions: p.OtherConditions,
 		SmallTable:      1,
+		RightUnique:     p.rightUnique,
 	}
 	join.SetSchema(p.schema)
 	if innerJoin {
@@ -291,7 +365,14 @@ func (p *Join) handleLeftJoin(prop requiredProperty, innerJoin bool) (*physicalP
 	}
 	sortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lSortedPlanInfo, rSortedPlanInfo)
 	unSortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lUnSortedPlanInfo, rUnSortedPlanInfo)
-	return sortedPlanInfo, unSortedPlanInfo, estimateJoinCount(lCount, rCount), nil
+	count := boundRowCount(estimateJoinCount(lCount, rCount), unSortedPlanInfo.p)
+	if !innerJoin && p.rightUnique {
+		// Every left row is output exactly once.
+		count = lCount
+	}
+	sortedPlanInfo.p.setRowCount(count)
+	unSortedPlanInfo.p.setRowCount(count)
+	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
 func (p *Join) handleRightJoin(prop requiredProperty, innerJoin bool) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
@@ -331,7 +412,10 @@ func (p *Join) handleRightJoin(prop requiredProperty, innerJoin bool) (*physical
 	}
 	sortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lSortedPlanInfo, rSortedPlanInfo)
 	unSortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lUnSortedPlanInfo, rUnSortedPlanInfo)
-	return sortedPlanInfo, unSortedPlanInfo, estimateJoinCount(lCount, rCount), nil
+	count := boundRowCount(estimateJoinCount(lCount, rCount), unSortedPlanInfo.p)
+	sortedPlanInfo.p.setRowCount(count)
+	unSortedPlanInfo.p.setRowCount(count)
+	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
@@ -357,6 +441,7 @@ func (p *Join) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *
 			RightConditions: p.RightConditions,
 			OtherConditions: p.OtherConditions,
 			Anti:            p.anti,
+			NullAware:       p.nullAware,
 		}
 		join.SetSchema(p.schema)
 		if !allLeft {
@@ -370,11 +455,17 @@ func (p *Join) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *
 		if err != nil {
 			return nil, nil, 0, errors.Trace(err)
 		}
+		if p.sharedInner != nil {
+			rUnSortedPlanInfo = p.sharedInner.materialize(rUnSortedPlanInfo, rCount)
+			rSortedPlanInfo = rUnSortedPlanInfo
+		}
 		sortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lSortedPlanInfo, rSortedPlanInfo)
 		unSortedPlanInfo := join.matchProperty(prop, []uint64{lCount, rCount}, lUnSortedPlanInfo, rUnSortedPlanInfo)
 		if p.JoinType == SemiJoin {
-			lCount = uint64(float64(lCount) * selectionFactor)
+			lCount = boundRowCount(uint64(float64(lCount)*selectionFactor), unSortedPlanInfo.p)
 		}
+		sortedPlanInfo.p.setRowCount(lCount)
+		unSortedPlanInfo.p.setRowCount(lCount)
 		if !allLeft {
 			sortedPlanInfo.cost = math.MaxFloat64
 		}
@@ -422,12 +513,293 @@ func (p *Aggregation) convert2PhysicalPlan(prop requiredProperty) (*physicalPlan
 		return planInfo, planInfo, cnt, nil
 	}
 	_, planInfo, cnt, err = p.GetChildByIndex(0).(LogicalPlan).convert2PhysicalPlan(nil)
+	if err != nil {
+		return nil, nil, 0, errors.Trace(err)
+	}
+	var streamPlanInfo *physicalPlanInfo
+	hashed := p.ResultSize == ast.SelectResultSizeSmall
+	if !hashed && (p.ResultSize == ast.SelectResultSizeBig || p.groupLimit > 0 && cnt/3 > p.groupLimit) {
+		streamPlanInfo, err = p.streamAggPlanInfo(planInfo, cnt)
+		if err != nil {
+			return nil, nil, 0, errors.Trace(err)
+		}
+	}
+	if streamPlanInfo == nil && !hashed {
+		distinctPlanInfo, err := p.streamDistinctPlanInfo(cnt / 3)
+		if err != nil {
+			return nil, nil, 0, errors.Trace(err)
+		}
+		// The hash set of the distinct arguments is costed like sorting them, which holds all of them in memory as well.
+		c := float64(cnt)
+		hashCost := planInfo.cost + c*math.Log2(c)*cpuFactor + memoryFactor*c
+		if distinctPlanInfo != nil && (p.ResultSize == ast.SelectResultSizeBig || distinctPlanInfo.cost < hashCost) {
+			streamPlanInfo = distinctPlanInfo
+		}
+	}
+	if streamPlanInfo != nil {
+		planInfo = streamPlanInfo
+	} else {
+		planInfo = addPlanToResponse(p, planInfo, cnt/3)
+	}
+	if p.ResultSize == ast.SelectResultSizeDefault {
+		skipPlanInfo, err := p.groupSkipPlanInfo(cnt / 3)
+		if err != nil {
+			return nil, nil, 0, errors.Trace(err)
+		}
+		if skipPlanInfo != nil && skipPlanInfo.cost < planInfo.cost {
+			planInfo = skipPlanInfo
+		}
+	}
 	if len(prop) != 0 {
-		return &physicalPlanInfo{cost: math.MaxFloat64}, addPlanToResponse(p, planInfo), cnt / 3, errors.Trace(err)
+		return &physicalPlanInfo{cost: math.MaxFloat64}, planInfo, cnt / 3, nil
 	}
-	planInfo = addPlanToResponse(p, planInfo)
 	p.storePlanInfo(prop, planInfo, planInfo, cnt/3)
-	return planInfo, planInfo, cnt / 3, errors.Trace(err)
+	return planInfo, planInfo, cnt / 3, nil
+}
+
+// streamAggPlanInfo returns the plan info of the aggregation streamed over the child sorted by the group by columns,
+// which is sorted by an index or a sort operator, whichever is cheaper. It returns nil if a group by item isn't a column.
+func (p *Aggregation) streamAggPlanInfo(unSortedPlanInfo *physicalPlanInfo, count uint64) (*physicalPlanInfo, error) {
+	if len(p.GroupByItems) == 0 {
+		return nil, nil
+	}
+	prop := make(requiredProperty, 0, len(p.GroupByItems))
+	for _, item := range p.GroupByItems {
+		col, ok := item.(*expression.Column)
+		if !ok {
+			return nil, nil
+		}
+		prop = append(prop, &columnProp{col: col, scope: ast.HintForGroupBy})
+	}
+	sortedPlanInfo, err := p.sortedChildPlanInfo(prop, unSortedPlanInfo, count)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	planInfo := addPlanToResponse(p, sortedPlanInfo, count/3)
+	planInfo.p.(*Aggregation).Streamed = true
+	return planInfo, nil
+}
+
+// sortedChildPlanInfo returns the plan info of the child sorted by prop, which is sorted by an index or a sort operator
+// over the unsorted child, whichever is cheaper. count is the row count of the child.
+func (p *baseLogicalPlan) sortedChildPlanInfo(prop requiredProperty, unSortedPlanInfo *physicalPlanInfo, count uint64) (*physicalPlanInfo, error) {
+	sortedPlanInfo, _, _, err := p.GetChildByIndex(0).(LogicalPlan).convert2PhysicalPlan(prop)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	cnt := float64(count)
+	sortCost := unSortedPlanInfo.cost + cnt*math.Log2(cnt)*cpuFactor + memoryFactor*cnt
+	if sortCost < sortedPlanInfo.cost {
+		sort := &NewSort{baseLogicalPlan: newBaseLogicalPlan(Srt, p.allocator)}
+		sort.initID()
+		for _, c := range prop {
+			sort.ByItems = append(sort.ByItems, &ByItems{Expr: c.col.DeepCopy()})
+		}
+		sort.SetSchema(unSortedPlanInfo.p.GetSchema())
+		sortedPlanInfo = addPlanToResponse(sort, unSortedPlanInfo, count)
+		sortedPlanInfo.cost = sortCost
+	}
+	return sortedPlanInfo, nil
+}
+
+// streamDistinctPlanInfo returns the plan info of the aggregation without group by, whose only aggregate function
+// is a COUNT(DISTINCT) over columns, like COUNT(DISTINCT a, b), over the child sorted by the columns, so the equal
+// arguments are adjacent and needn't be kept in a hash set. It returns nil if the child can't be sorted without
+// a sort operator, e.g. there is no index on the columns, so the hash set is used instead. The caller keeps it only
+// if it's cheaper than the hash set.
+func (p *Aggregation) streamDistinctPlanInfo(count uint64) (*physicalPlanInfo, error) {
+	if len(p.GroupByItems) > 0 || len(p.AggFuncs) != 1 {
+		return nil, nil
+	}
+	agg := p.AggFuncs[0]
+	if agg.GetName() != ast.AggFuncCount || !agg.IsDistinct() {
+		return nil, nil
+	}
+	cols := make([]*expression.Column, 0, len(agg.GetArgs()))
+	for _, arg := range agg.GetArgs() {
+		col, ok := arg.(*expression.Column)
+		if !ok {
+			return nil, nil
+		}
+		cols = append(cols, col)
+	}
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	sortedPlanInfo, _, _, err := child.convert2PhysicalPlan(distinctProperty(child, cols))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if sortedPlanInfo.cost == math.MaxFloat64 {
+		return nil, nil
+	}
+	planInfo := addPlanToResponse(p, sortedPlanInfo, count)
+	planInfo.p.(*Aggregation).StreamedDistinct = true
+	return planInfo, nil
+}
+
+// groupSkipPlanInfo returns the plan info of the aggregation over an index, whose leading columns are the group by
+// columns followed by the argument of the MIN or the MAX, so the first entry of every group, read in the descending
+// order for MAX, has the result, and the other entries are skipped by seeking, i.e. a loose index scan. Every group
+// costs a seek, so the cost depends on the number of distinct group by values. The other aggregate functions
+// must be FIRSTROW of the group by columns. It returns nil if there is no such index.
+func (p *Aggregation) groupSkipPlanInfo(count uint64) (*physicalPlanInfo, error) {
+	ds, ok := p.GetChildByIndex(0).(*DataSource)
+	if !ok || len(p.GroupByItems) == 0 {
+		return nil, nil
+	}
+	gbyCols := make([]*expression.Column, 0, len(p.GroupByItems))
+	for _, item := range p.GroupByItems {
+		col, ok := item.(*expression.Column)
+		if !ok {
+			return nil, nil
+		}
+		gbyCols = append(gbyCols, col)
+	}
+	var aggCol *expression.Column
+	desc := false
+	for _, af := range p.AggFuncs {
+		args := af.GetArgs()
+		if len(args) != 1 {
+			return nil, nil
+		}
+		col, ok := args[0].(*expression.Column)
+		if !ok {
+			return nil, nil
+		}
+		switch af.GetName() {
+		case ast.AggFuncFirstRow:
+			if findColumn(gbyCols, col) == -1 {
+				return nil, nil
+			}
+		case ast.AggFuncMin, ast.AggFuncMax:
+			isMax := af.GetName() == ast.AggFuncMax
+			if aggCol != nil && (!aggCol.Equal(col) || isMax != desc) {
+				return nil, nil
+			}
+			aggCol, desc = col, isMax
+		default:
+			return nil, nil
+		}
+	}
+	if aggCol == nil {
+		return nil, nil
+	}
+	groups := groupCount(ds, gbyCols)
+	indices, _ := availableIndices(ds.table, ast.HintForScan, ast.HintForJoin, ast.HintForGroupBy)
+	var best *physicalPlanInfo
+	for _, idx := range indices {
+		prop := groupSkipProperty(idx, gbyCols, aggCol, desc)
+		if prop == nil || !isCoveringIndex(ds.Columns, idx.Columns, ds.Table.PKIsHandle) {
+			continue
+		}
+		sortedPlanInfo, _, err := ds.handleIndexScan(prop, idx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if sortedPlanInfo.cost == math.MaxFloat64 {
+			continue
+		}
+		planInfo := addPlanToResponse(p, sortedPlanInfo, count)
+		planInfo.p.(*Aggregation).GroupSkip = true
+		planInfo.cost = float64(groups) * netWorkFactor
+		if best == nil || planInfo.cost < best.cost {
+			best = planInfo
+		}
+	}
+	return best, nil
+}
+
+// groupSkipProperty returns the property sorting the index columns if the leading ones are the group by columns
+// followed by the aggregated column, otherwise it returns nil.
+func groupSkipProperty(idx *model.IndexInfo, gbyCols []*expression.Column, aggCol *expression.Column, desc bool) requiredProperty {
+	if len(idx.Columns) <= len(gbyCols) {
+		return nil
+	}
+	prop := make(requiredProperty, 0, len(gbyCols)+1)
+	for _, idxCol := range idx.Columns[:len(gbyCols)] {
+		if idxCol.Length != types.UnspecifiedLength {
+			return nil
+		}
+		for _, col := range gbyCols {
+			if col.ColName.L == idxCol.Name.L {
+				prop = append(prop, &columnProp{col: col, desc: desc, scope: ast.HintForGroupBy})
+				break
+			}
+		}
+	}
+	if len(prop) != len(gbyCols) {
+		return nil
+	}
+	idxCol := idx.Columns[len(gbyCols)]
+	if idxCol.Length != types.UnspecifiedLength || idxCol.Name.L != aggCol.ColName.L {
+		return nil
+	}
+	return append(prop, &columnProp{col: aggCol, desc: desc, scope: ast.HintForGroupBy})
+}
+
+// groupCount estimates the number of distinct values of the columns of the data source, which is the product of
+// the numbers of distinct values of the columns, but no more than the row count.
+func groupCount(ds *DataSource, cols []*expression.Column) uint64 {
+	count := float64(1)
+	for _, col := range cols {
+		for _, colInfo := range ds.Table.Columns {
+			if colInfo.Name.L == col.ColName.L {
+				count *= float64(ds.statisticTable.Columns[colInfo.Offset].NDV)
+				break
+			}
+		}
+	}
+	return uint64(math.Min(count, float64(ds.statisticTable.Count)))
+}
+
+// distinctProperty returns the property sorting the columns, which are ordered as the leading columns of an index
+// of the data source under the plan if there is one, because the distinct values don't depend on the order.
+func distinctProperty(p LogicalPlan, cols []*expression.Column) requiredProperty {
+	prop := make(requiredProperty, 0, len(cols))
+	for _, col := range cols {
+		prop = append(prop, &columnProp{col: col, scope: ast.HintForGroupBy})
+	}
+	if sel, ok := p.(*Selection); ok {
+		p = sel.GetChildByIndex(0).(LogicalPlan)
+	}
+	ds, ok := p.(*DataSource)
+	if !ok {
+		return prop
+	}
+	for _, idx := range ds.Table.Indices {
+		if len(idx.Columns) < len(cols) {
+			continue
+		}
+		idxProp := make(requiredProperty, 0, len(cols))
+		for _, idxCol := range idx.Columns[:len(cols)] {
+			for _, col := range cols {
+				if col.ColName.L == idxCol.Name.L {
+					idxProp = append(idxProp, &columnProp{col: col, scope: ast.HintForGroupBy})
+					break
+				}
+			}
+		}
+		if len(idxProp) == len(cols) {
+			return idxProp
+		}
+	}
+	return prop
+}
+
+// convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
+func (p *Expand) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
+	var err error
+	_, planInfo, cnt := p.getPlanInfo(prop)
+	if planInfo != nil {
+		return planInfo, planInfo, cnt, nil
+	}
+	_, planInfo, cnt, err = p.GetChildByIndex(0).(LogicalPlan).convert2PhysicalPlan(nil)
+	cnt *= uint64(len(p.GroupingSets))
+	if len(prop) != 0 {
+		return &physicalPlanInfo{cost: math.MaxFloat64}, addPlanToResponse(p, planInfo, cnt), cnt, errors.Trace(err)
+	}
+	planInfo = addPlanToResponse(p, planInfo, cnt)
+	p.storePlanInfo(prop, planInfo, planInfo, cnt)
+	return planInfo, planInfo, cnt, errors.Trace(err)
 }
 
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
@@ -443,7 +815,7 @@ func (p *NewUnion) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInf
 		newProp := make(requiredProperty, 0, len(prop))
 		for _, c := range prop {
 			idx := p.GetSchema().GetIndex(c.col)
-			newProp = append(newProp, &columnProp{col: child.GetSchema()[idx], desc: c.desc})
+			newProp = append(newProp, &columnProp{col: child.GetSchema()[idx], desc: c.desc, scope: c.scope})
 		}
 		var cnt uint64
 		sortedPlanInfo, unSortedPlanInfo, cnt, err = child.(LogicalPlan).convert2PhysicalPlan(newProp)
@@ -456,6 +828,8 @@ func (p *NewUnion) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInf
 	}
 	sortedPlanInfo = p.matchProperty(prop, nil, sortedPlanInfoCollection...)
 	unSortedPlanInfo = p.matchProperty(prop, nil, unSortedPlanInfoCollection...)
+	sortedPlanInfo.p.setRowCount(count)
+	unSortedPlanInfo.p.setRowCount(count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
@@ -471,18 +845,23 @@ func (p *Selection) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanIn
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	if _, ok := p.GetChildByIndex(0).(*DataSource); ok {
-		count = uint64(float64(count) * selectionFactor)
+	if ds, ok := p.GetChildByIndex(0).(*DataSource); ok {
+		count = boundRowCount(ds.correctRowCount(uint64(float64(count)*selectionFactor)), unSortedPlanInfo.p)
 		return sortedPlanInfo, unSortedPlanInfo, count, nil
 	}
 	count /= 3
 	sortedPlanInfo = p.matchProperty(prop, nil, sortedPlanInfo)
 	unSortedPlanInfo = p.matchProperty(prop, nil, unSortedPlanInfo)
+	sortedPlanInfo.p.setRowCount(count)
+	unSortedPlanInfo.p.setRowCount(count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
+// The projection keeps the order of its child on the columns it passes through, which may be renamed, so the
+// property on them is required of the child instead. The columns computed by functions break the order, while
+// the constants and the correlated columns, which are the same in all rows, keep any order.
 func (p *Projection) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
 	var err error
 	sortedPlanInfo, unSortedPlanInfo, count := p.getPlanInfo(prop)
@@ -498,10 +877,13 @@ loop:
 		idx := p.schema.GetIndex(c.col)
 		switch v := p.Exprs[idx].(type) {
 		case *expression.Column:
+			if v.Correlated {
+				continue
+			}
 			childIdx := childSchema.GetIndex(v)
 			if !usedCols[childIdx] {
 				usedCols[childIdx] = true
-				newProp = append(newProp, &columnProp{col: v, desc: c.desc})
+				newProp = append(newProp, &columnProp{col: v, desc: c.desc, scope: c.scope})
 			}
 		case *expression.ScalarFunction:
 			newProp = nil
@@ -513,12 +895,14 @@ loop:
 	if err != nil {
 		return nil, nil, count, errors.Trace(err)
 	}
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	if !canPassSort {
-		return &physicalPlanInfo{cost: math.MaxFloat64}, unSortedPlanInfo, count, nil
+		sortedPlanInfo = &physicalPlanInfo{cost: math.MaxFloat64}
+		p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
+		return sortedPlanInfo, unSortedPlanInfo, count, nil
 	}
 
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
@@ -547,7 +931,7 @@ func (p *NewSort) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo
 	selfProp := make(requiredProperty, 0, len(p.ByItems))
 	for _, by := range p.ByItems {
 		if col, ok := by.Expr.(*expression.Column); ok {
-			selfProp = append(selfProp, &columnProp{col: col, desc: by.Desc})
+			selfProp = append(selfProp, &columnProp{col: col, desc: by.Desc, scope: ast.HintForOrderBy})
 		} else {
 			selfProp = nil
 			break
@@ -557,13 +941,18 @@ func (p *NewSort) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
+	// At most one row is in any order, so the sort is unnecessary.
+	if cnt, ok := maxRowCount(unSortedPlanInfo.p); ok && cnt <= 1 {
+		p.storePlanInfo(prop, unSortedPlanInfo, unSortedPlanInfo, count)
+		return unSortedPlanInfo, unSortedPlanInfo, count, nil
+	}
 	cnt := float64(count)
 	sortCost := cnt*math.Log2(cnt)*cpuFactor + memoryFactor*cnt
 	if len(selfProp) == 0 {
-		sortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+		sortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	} else if sortCost+unSortedPlanInfo.cost < sortedPlanInfo.cost {
 		sortedPlanInfo.cost = sortCost + unSortedPlanInfo.cost
-		sortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+		sortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	}
 	if matchProp(prop, selfProp) {
 		return sortedPlanInfo, sortedPlanInfo, count, nil
@@ -574,6 +963,98 @@ func (p *NewSort) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
+// isCorrelatedPlan checks if the physical plan or any of its children refers to correlated columns.
+func isCorrelatedPlan(p PhysicalPlan) bool {
+	var exprs []expression.Expression
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		exprs = x.AccessCondition
+	case *PhysicalIndexScan:
+		exprs = x.AccessCondition
+	case *Selection:
+		exprs = x.Conditions
+	case *Projection:
+		exprs = x.Exprs
+	case *Aggregation:
+		exprs = append(exprs, x.GroupByItems...)
+		for _, aggFunc := range x.AggFuncs {
+			exprs = append(exprs, aggFunc.GetArgs()...)
+		}
+	case *NewSort:
+		for _, item := range x.ByItems {
+			exprs = append(exprs, item.Expr)
+		}
+	case *PhysicalHashJoin:
+		for _, cond := range x.EqualConditions {
+			exprs = append(exprs, cond)
+		}
+		exprs = append(exprs, x.LeftConditions...)
+		exprs = append(exprs, x.RightConditions...)
+		exprs = append(exprs, x.OtherConditions...)
+	case *PhysicalHashSemiJoin:
+		for _, cond := range x.EqualConditions {
+			exprs = append(exprs, cond)
+		}
+		exprs = append(exprs, x.LeftConditions...)
+		exprs = append(exprs, x.RightConditions...)
+		exprs = append(exprs, x.OtherConditions...)
+	case *Limit, *Distinct, *NewUnion, *Trim, *Exists, *MaxOneRow, *NewTableDual, *PhysicalCache, *Expand:
+	default:
+		// Apply and the other plans are regarded as correlated conservatively.
+		return true
+	}
+	for _, expr := range exprs {
+		if _, outerCols := extractColumn(expr, nil, nil); len(outerCols) > 0 {
+			return true
+		}
+	}
+	for _, child := range p.GetChildren() {
+		if isCorrelatedPlan(child.(PhysicalPlan)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlainScan checks if the plan only reads rows from a table, maybe with some filters or projections.
+func isPlainScan(p PhysicalPlan) bool {
+	switch p.(type) {
+	case *PhysicalTableScan, *PhysicalIndexScan:
+		return true
+	case *Selection, *Projection, *Trim:
+		return isPlainScan(p.GetChildByIndex(0).(PhysicalPlan))
+	}
+	return false
+}
+
+// cacheInvariantPlans finds the sub plans of apply's inner plan that don't depend on the outer row and
+// caches them, so they are computed only once instead of once per outer row.
+// A plain scan isn't cached, because it would copy the whole table into memory for little gain.
+func cacheInvariantPlans(p PhysicalPlan) PhysicalPlan {
+	if !isCorrelatedPlan(p) {
+		if isPlainScan(p) {
+			return p
+		}
+		cache := &PhysicalCache{}
+		cache.SetSchema(p.GetSchema())
+		cache.setRowCount(uint64(p.RowCount()))
+		cache.SetChildren(p)
+		p.SetParents(cache)
+		return cache
+	}
+	if _, ok := p.(*PhysicalApply); ok {
+		return p
+	}
+	children := make([]Plan, 0, len(p.GetChildren()))
+	for _, child := range p.GetChildren() {
+		newChild := cacheInvariantPlans(child.(PhysicalPlan))
+		newChild.SetParents(p)
+		children = append(children, newChild)
+	}
+	p.SetChildren(children...)
+	return p
+}
+
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
 func (p *Apply) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
 	var err error
@@ -589,15 +1070,15 @@ func (p *Apply) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo,
 	np := &PhysicalApply{
 		OuterSchema: p.OuterSchema,
 		Checker:     p.Checker,
-		InnerPlan:   innerRes.p,
+		InnerPlan:   cacheInvariantPlans(innerRes.p),
 	}
 	np.SetSchema(p.GetSchema())
 	sortedPlanInfo, unSortedPlanInfo, count, err = child.convert2PhysicalPlan(prop)
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(np, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(np, unSortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(np, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(np, unSortedPlanInfo, count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
@@ -610,13 +1091,29 @@ func (p *Distinct) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInf
 		return sortedPlanInfo, unSortedPlanInfo, count, nil
 	}
 	child := p.GetChildByIndex(0).(LogicalPlan)
-	sortedPlanInfo, unSortedPlanInfo, count, err = child.convert2PhysicalPlan(prop)
+	sortedPlanInfo, unSortedPlanInfo, childCount, err := child.convert2PhysicalPlan(prop)
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
-	count = uint64(float64(count) * distinctFactor)
+	count = uint64(float64(childCount) * distinctFactor)
+	if p.ResultSize == ast.SelectResultSizeBig {
+		// SQL_BIG_RESULT streams the distinct over the child sorted by all the columns, whose order is kept.
+		distinctProp := distinctProperty(child, p.GetSchema())
+		streamChildInfo, err := p.sortedChildPlanInfo(distinctProp, unSortedPlanInfo, childCount)
+		if err != nil {
+			return nil, nil, 0, errors.Trace(err)
+		}
+		unSortedPlanInfo = addPlanToResponse(p, streamChildInfo, count)
+		unSortedPlanInfo.p.(*Distinct).Streamed = true
+		sortedPlanInfo = &physicalPlanInfo{cost: math.MaxFloat64}
+		if matchProp(prop, distinctProp) {
+			sortedPlanInfo = unSortedPlanInfo
+		}
+		p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
+		return sortedPlanInfo, unSortedPlanInfo, count, nil
+	}
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
@@ -639,8 +1136,8 @@ func (p *MaxOneRow) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanIn
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
@@ -656,9 +1153,11 @@ func (p *Exists) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo,
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
-	return sortedPlanInfo, unSortedPlanInfo, count, nil
+	// Exists outputs a single row telling whether the child returns any row, like in maxRowCount.
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, 1)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, 1)
+	p.storePlanInfo(prop, sortedPlanInfo, unSortedPlanInfo, 1)
+	return sortedPlanInfo, unSortedPlanInfo, 1, nil
 }
 
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
@@ -673,8 +1172,8 @@ func (p *Trim) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
@@ -690,11 +1189,31 @@ func (p *SelectLock) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanI
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo)
-	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo)
+	sortedPlanInfo = addPlanToResponse(p, sortedPlanInfo, count)
+	unSortedPlanInfo = addPlanToResponse(p, unSortedPlanInfo, count)
 	return sortedPlanInfo, unSortedPlanInfo, count, nil
 }
 
+// convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
+func (p *Do) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	sortedPlanInfo, unSortedPlanInfo, count, err := child.convert2PhysicalPlan(nil)
+	if err != nil {
+		return nil, nil, 0, errors.Trace(err)
+	}
+	return addPlanToResponse(p, sortedPlanInfo, count), addPlanToResponse(p, unSortedPlanInfo, count), count, nil
+}
+
+// convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
+func (p *NewUpdate) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	sortedPlanInfo, unSortedPlanInfo, count, err := child.convert2PhysicalPlan(nil)
+	if err != nil {
+		return nil, nil, 0, errors.Trace(err)
+	}
+	return addPlanToResponse(p, sortedPlanInfo, count), addPlanToResponse(p, unSortedPlanInfo, count), count, nil
+}
+
 // convert2PhysicalPlan implements LogicalPlan convert2PhysicalPlan interface.
 func (p *Insert) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo, *physicalPlanInfo, uint64, error) {
 	if len(p.GetChildren()) == 0 {
@@ -706,5 +1225,5 @@ func (p *Insert) convert2PhysicalPlan(prop requiredProperty) (*physicalPlanInfo,
 	if err != nil {
 		return nil, nil, 0, errors.Trace(err)
 	}
-	return addPlanToResponse(p, sortedPlanInfo), addPlanToResponse(p, unSortedPlanInfo), count, nil
+	return addPlanToResponse(p, sortedPlanInfo, count), addPlanToResponse(p, unSortedPlanInfo, count), count, nil
 }
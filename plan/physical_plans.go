@@ -16,6 +16,8 @@ package plan
 import (
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/pingcap/tidb/util/types"
 )
 
 // PhysicalIndexScan represents an index scan plan.
@@ -31,14 +33,20 @@ type PhysicalIndexScan struct {
 	OutOfOrder bool
 	// DoubleRead means if the index executor will read kv two times.
 	// If the query requires the columns that don't belong to index, DoubleRead will be true.
+	// The integer primary key which is the handle is always covered by the index.
 	DoubleRead bool
 
 	accessEqualCount int
-	AccessCondition  []expression.Expression
+	// accessInRow means the only access condition is a row IN list on a prefix of the index columns,
+	// whose rows are the points of the ranges.
+	accessInRow     bool
+	AccessCondition []expression.Expression
 
 	TableAsName *model.CIStr
 
 	LimitCount *int64
+
+	forbidFullScan bool
 }
 
 // PhysicalTableScan represents a table scan plan.
@@ -57,6 +65,8 @@ type PhysicalTableScan struct {
 	TableAsName *model.CIStr
 
 	LimitCount *int64
+
+	forbidFullScan bool
 }
 
 // PhysicalApply represents apply plan, only used for subquery.
@@ -68,6 +78,34 @@ type PhysicalApply struct {
 	Checker     *ApplyConditionChecker
 }
 
+// PhysicalCache caches the result of its child, so that the child is executed only once even if it is
+// executed repeatedly, e.g. a sub plan of apply's inner plan that doesn't depend on the outer row.
+type PhysicalCache struct {
+	basePlan
+
+	// ShareID is set for the caches of the identical subqueries of a statement, which share one materialized
+	// result. The result is fetched from the child of the cache executed first. Zero means the result isn't shared.
+	ShareID int
+}
+
+// HandlerRead returns the rows of its child for HANDLER READ and keeps the index key and the handle of the last row
+// in the handler, where the next HANDLER READ NEXT or PREV starts.
+type HandlerRead struct {
+	basePlan
+
+	Handler *variable.Handler
+	// IndexName is the lower case name of the index read.
+	IndexName  string
+	KeyColumns []*expression.Column
+	// LastKey is the index key and the handle of the row that NEXT or PREV reads after. The rows of the child up to
+	// it in the order of (key, handle), which is descending if Desc is true, are skipped before Offset and Count
+	// are applied. The limit isn't planned in the child if LastKey isn't nil.
+	LastKey []types.Datum
+	Desc    bool
+	Offset  uint64
+	Count   uint64
+}
+
 // PhysicalHashJoin represents hash join for inner/ outer join.
 type PhysicalHashJoin struct {
 	basePlan
@@ -79,6 +117,8 @@ type PhysicalHashJoin struct {
 	RightConditions []expression.Expression
 	OtherConditions []expression.Expression
 	SmallTable      int
+	// RightUnique means every left row matches at most one right row, so the probe can stop at the first match.
+	RightUnique bool
 }
 
 // PhysicalHashSemiJoin represents hash join for semi join.
@@ -87,6 +127,8 @@ type PhysicalHashSemiJoin struct {
 
 	WithAux bool
 	Anti    bool
+	// NullAware is set for IN, the key of which is unknown to match if it's null or the inner side has a null key.
+	NullAware bool
 
 	EqualConditions []*expression.ScalarFunction
 	LeftConditions  []expression.Expression
@@ -112,6 +154,12 @@ func (p *PhysicalApply) Copy() PhysicalPlan {
 	return &np
 }
 
+// Copy implements the PhysicalPlan Copy interface.
+func (p *PhysicalCache) Copy() PhysicalPlan {
+	np := *p
+	return &np
+}
+
 // Copy implements the PhysicalPlan Copy interface.
 func (p *PhysicalHashSemiJoin) Copy() PhysicalPlan {
 	np := *p
@@ -154,6 +202,18 @@ func (p *MaxOneRow) Copy() PhysicalPlan {
 	return &np
 }
 
+// Copy implements the PhysicalPlan Copy interface.
+func (p *Do) Copy() PhysicalPlan {
+	np := *p
+	return &np
+}
+
+// Copy implements the PhysicalPlan Copy interface.
+func (p *NewUpdate) Copy() PhysicalPlan {
+	np := *p
+	return &np
+}
+
 // Copy implements the PhysicalPlan Copy interface.
 func (p *Insert) Copy() PhysicalPlan {
 	np := *p
@@ -201,3 +261,9 @@ func (p *Aggregation) Copy() PhysicalPlan {
 	np := *p
 	return &np
 }
+
+// Copy implements the PhysicalPlan Copy interface.
+func (p *Expand) Copy() PhysicalPlan {
+	np := *p
+	return &np
+}
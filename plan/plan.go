@@ -28,6 +28,8 @@ const (
 	Proj = "Projection"
 	// Agg is the type of Aggregation.
 	Agg = "Aggregation"
+	// Expd is the type of Expand.
+	Expd = "Expand"
 	// Jn is the type of Join.
 	Jn = "Join"
 	// Un is the type of Union.
@@ -100,6 +102,11 @@ type Plan interface {
 	SetParents(...Plan)
 	// SetParents sets children for plan.
 	SetChildren(...Plan)
+	// MaxExecutionTime returns the maximum execution time in milliseconds hinted by MAX_EXECUTION_TIME,
+	// 0 means no limit.
+	MaxExecutionTime() uint64
+	// SetMaxExecutionTime sets the maximum execution time in milliseconds.
+	SetMaxExecutionTime(ms uint64)
 }
 
 type requiredProperty []*columnProp
@@ -112,6 +119,17 @@ type physicalPlanInfo struct {
 type columnProp struct {
 	col  *expression.Column
 	desc bool
+	// scope is the clause requiring the order, HintForOrderBy or HintForGroupBy, which decides the index hints
+	// used to eliminate the sorting.
+	scope ast.IndexHintScope
+}
+
+// scope returns the clause requiring the property.
+func (p requiredProperty) scope() ast.IndexHintScope {
+	if len(p) == 0 {
+		return 0
+	}
+	return p[0].scope
 }
 
 // LogicalPlan is a tree of logical operators.
@@ -149,10 +167,15 @@ type PhysicalPlan interface {
 
 	// PushLimit tries to push down limit as deeply as possible.
 	PushLimit(l *Limit) PhysicalPlan
+
+	// setRowCount sets the estimated row count, which is shown by DescribePlanDOT. It's only called when the plan
+	// is created, because the plans are shared by the plan infos of their parents.
+	setRowCount(count uint64)
 }
 
 type baseLogicalPlan struct {
 	propLen          int
+	propScope        ast.IndexHintScope
 	sortedPlanInfo   *physicalPlanInfo
 	unSortedPlanInfo *physicalPlanInfo
 	count            uint64
@@ -167,6 +190,9 @@ func (p *baseLogicalPlan) getPlanInfo(prop requiredProperty) (*physicalPlanInfo,
 		return nil, nil, 0
 	}
 	if len(prop) == p.propLen {
+		if prop.scope() != p.propScope {
+			return nil, nil, 0
+		}
 		return p.sortedPlanInfo, p.unSortedPlanInfo, p.count
 	}
 	return p.unSortedPlanInfo, p.unSortedPlanInfo, p.count
@@ -174,6 +200,7 @@ func (p *baseLogicalPlan) getPlanInfo(prop requiredProperty) (*physicalPlanInfo,
 
 func (p *baseLogicalPlan) storePlanInfo(prop requiredProperty, sortedPlanInfo, unSortedPlanInfo *physicalPlanInfo, cnt uint64) {
 	p.propLen = len(prop)
+	p.propScope = prop.scope()
 	p.sortedPlanInfo = sortedPlanInfo
 	p.unSortedPlanInfo = unSortedPlanInfo
 	p.count = cnt
@@ -227,6 +254,8 @@ type basePlan struct {
 	rowCount    float64
 	limit       float64
 	correlated  bool
+	// maxExecutionTime is only set on the root plan.
+	maxExecutionTime uint64
 
 	parents  []Plan
 	children []Plan
@@ -275,6 +304,21 @@ func (p *basePlan) RowCount() float64 {
 	return math.Min(p.rowCount, p.limit)
 }
 
+// setRowCount implements PhysicalPlan setRowCount interface.
+func (p *basePlan) setRowCount(count uint64) {
+	p.rowCount = float64(count)
+}
+
+// MaxExecutionTime implements Plan MaxExecutionTime interface.
+func (p *basePlan) MaxExecutionTime() uint64 {
+	return p.maxExecutionTime
+}
+
+// SetMaxExecutionTime implements Plan SetMaxExecutionTime interface.
+func (p *basePlan) SetMaxExecutionTime(ms uint64) {
+	p.maxExecutionTime = ms
+}
+
 // SetLimit implements Plan SetLimit interface.
 func (p *basePlan) SetLimit(limit float64) {
 	p.limit = limit
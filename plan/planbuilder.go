@@ -15,6 +15,7 @@ package plan
 
 import (
 	"math"
+	"strings"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/juju/errors"
@@ -64,6 +65,14 @@ type planBuilder struct {
 	outerSchemas []expression.Schema
 	// colMapper stores the column that must be pre-resolved.
 	colMapper map[*ast.ColumnNameExpr]int
+	// sharedSubqueries records the different non-correlated IN subqueries in the statement.
+	sharedSubqueries []*sharedSubquery
+	// handlerRead is the parent of the optimized plan of HANDLER READ, which keeps the key of the last row read.
+	handlerRead *HandlerRead
+	// inSetValue is set when building the values of SET statement. The values are evaluated after the earlier
+	// variables are set, so the subqueries and the user variables in them must be evaluated when the plan is
+	// executed instead of when it's built.
+	inSetValue bool
 }
 
 func (b *planBuilder) build(node ast.Node) Plan {
@@ -96,6 +105,8 @@ func (b *planBuilder) build(node ast.Node) Plan {
 		return &Execute{Name: x.Name, UsingVars: x.UsingVars}
 	case *ast.ExplainStmt:
 		return b.buildExplain(x)
+	case *ast.HandlerStmt:
+		return b.buildHandler(x)
 	case *ast.InsertStmt:
 		return b.buildInsert(x)
 	case *ast.PrepareStmt:
@@ -111,14 +122,23 @@ func (b *planBuilder) build(node ast.Node) Plan {
 		}
 		return b.buildUnion(x)
 	case *ast.UpdateStmt:
+		if UseNewPlanner && assignmentsHaveSubquery(x.List) {
+			return b.buildNewUpdate(x)
+		}
 		return b.buildUpdate(x)
 	case *ast.UseStmt:
 		return b.buildSimple(x)
 	case *ast.SetStmt:
+		if UseNewPlanner && hasSubquery(x) {
+			return b.buildSet(x)
+		}
 		return b.buildSimple(x)
 	case *ast.ShowStmt:
 		return b.buildShow(x)
 	case *ast.DoStmt:
+		if UseNewPlanner {
+			return b.buildDo(x)
+		}
 		return b.buildSimple(x)
 	case *ast.BeginStmt:
 		return b.buildSimple(x)
@@ -226,10 +246,20 @@ func (b *planBuilder) buildSubquery(n ast.Node) {
 
 func (b *planBuilder) buildSelect(sel *ast.SelectStmt) Plan {
 	var aggFuncs []*ast.AggregateFuncExpr
+	if sel.GroupBy != nil && sel.GroupBy.GroupingSets != nil {
+		b.err = ErrUnsupportedType.Gen("GROUPING SETS is only supported by the new planner")
+		return nil
+	}
 	hasAgg := b.detectSelectAgg(sel)
 	canPushLimit := !hasAgg
 	if hasAgg {
 		aggFuncs = b.extractSelectAgg(sel)
+		for _, agg := range aggFuncs {
+			if strings.ToLower(agg.F) == ast.AggFuncGrouping {
+				b.err = ErrUnsupportedType.Gen("GROUPING function is only supported by the new planner")
+				return nil
+			}
+		}
 	}
 	// Build subquery
 	// Convert subquery to expr with plan
@@ -348,7 +378,7 @@ func (b *planBuilder) buildTableSource(sel *ast.SelectStmt) Plan {
 }
 
 func (b *planBuilder) buildAllAccessMethodsPlan(path *joinPath) []Plan {
-	indices, includeTableScan := availableIndices(path.table)
+	indices, includeTableScan := availableIndices(path.table, ast.HintForScan, ast.HintForJoin)
 	var candidates []Plan
 	if includeTableScan {
 		p := b.buildTableScanPlan(path)
@@ -361,11 +391,16 @@ func (b *planBuilder) buildAllAccessMethodsPlan(path *joinPath) []Plan {
 	return candidates
 }
 
-func availableIndices(table *ast.TableName) (indices []*model.IndexInfo, includeTableScan bool) {
+// availableIndices returns the indices and whether table scan can be used, only the index hints
+// whose scope is in scopes are taken into account.
+func availableIndices(table *ast.TableName, scopes ...ast.IndexHintScope) (indices []*model.IndexInfo, includeTableScan bool) {
 	var usableHints []*ast.IndexHint
 	for _, hint := range table.IndexHints {
-		if hint.HintScope == ast.HintForScan {
-			usableHints = append(usableHints, hint)
+		for _, scope := range scopes {
+			if hint.HintScope == scope {
+				usableHints = append(usableHints, hint)
+				break
+			}
 		}
 	}
 	if len(usableHints) == 0 {
@@ -655,8 +690,8 @@ func (b *planBuilder) buildAdmin(as *ast.AdminStmt) Plan {
 	var p Plan
 
 	switch as.Tp {
-	case ast.AdminCheckTable:
-		p = &CheckTable{Tables: as.Tables}
+	case ast.AdminCheckTable, ast.AdminCheckIndex:
+		p = b.buildCheckTable(as)
 	case ast.AdminShowDDL:
 		p = &ShowDDL{}
 		p.SetFields(buildShowDDLFields())
@@ -667,6 +702,24 @@ func (b *planBuilder) buildAdmin(as *ast.AdminStmt) Plan {
 	return p
 }
 
+// buildCheckTable enumerates the indices to check of the tables from their infos.
+func (b *planBuilder) buildCheckTable(as *ast.AdminStmt) Plan {
+	p := &CheckTable{Tables: as.Tables}
+	for _, tn := range as.Tables {
+		indices := tn.TableInfo.Indices
+		if as.Tp == ast.AdminCheckIndex {
+			idx := findIndexByName(indices, model.NewCIStr(as.Index))
+			if idx == nil {
+				b.err = ErrKeyDoesNotExist.Gen("Key '%s' doesn't exist in table '%s'", as.Index, tn.Name)
+				return nil
+			}
+			indices = []*model.IndexInfo{idx}
+		}
+		p.Indices = append(p.Indices, indices)
+	}
+	return p
+}
+
 func buildShowDDLFields() []*ast.ResultField {
 	rfs := make([]*ast.ResultField, 0, 6)
 	rfs = append(rfs, buildResultField("", "SCHEMA_VER", mysql.TypeLonglong, 4))
@@ -605,6 +605,9 @@ func (b *planBuilder) buildJoin(sel *ast.SelectStmt) Plan {
 		sel.Where.Accept(nrfinder)
 	}
 	path := b.buildBasicJoinPath(sel.From.TableRefs, nrfinder.nullRejectTables)
+	if b.err != nil {
+		return nil
+	}
 	rfs := path.resultFields()
 
 	var filterConditions []ast.ExprNode
@@ -667,6 +670,10 @@ func (b *planBuilder) buildBasicJoinPath(node ast.ResultSetNode, nullRejectTable
 		if x.Right == nil {
 			return leftPath
 		}
+		if len(x.Using) > 0 || x.NaturalJoin {
+			b.err = ErrUnsupportedType.Gen("USING and NATURAL joins are only supported by the new planner")
+			return nil
+		}
 		righPath := b.buildBasicJoinPath(x.Right, nullRejectTables)
 		isOuter := b.isOuterJoin(x.Tp, leftPath, righPath, nullRejectTables)
 		if isOuter {
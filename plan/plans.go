@@ -72,6 +72,12 @@ type CheckTable struct {
 	basePlan
 
 	Tables []*ast.TableName
+	// Indices are the indices to check of each table, which are all the indices for ADMIN CHECK TABLE and the
+	// named one for ADMIN CHECK INDEX. The executor compares each index with its table by
+	// inspectkv.CompareIndexData, which scans both in the KV store to check that every index entry has a matching
+	// row and every row has its index entry.
+	// TODO: Plan the check as a pair of table and index scans for each index. It isn't done yet.
+	Indices [][]*model.IndexInfo
 }
 
 // IndexRange represents an index range to be scanned.
@@ -257,6 +263,13 @@ type Union struct {
 // Distinct represents Distinct plan.
 type Distinct struct {
 	baseLogicalPlan
+
+	// Streamed means the child is sorted by all the columns, so the equal rows are adjacent and found by comparing
+	// each row with the last one instead of in a hash set.
+	Streamed bool
+	// ResultSize is the size of the result hinted by SQL_SMALL_RESULT or SQL_BIG_RESULT, which makes the distinct
+	// hashed or streamed.
+	ResultSize ast.SelectResultSize
 }
 
 // SetLimit implements Plan SetLimit interface.
@@ -381,6 +394,16 @@ type Simple struct {
 	Statement ast.StmtNode
 }
 
+// Set represents SET statement whose values contain subqueries. The variables are set in order, and each value in
+// Values is evaluated after the earlier variables are set, since it may read them.
+type Set struct {
+	basePlan
+
+	Stmt *ast.SetStmt
+	// Values are the plans of the values with subqueries, each of which returns one row of the value.
+	Values map[*ast.VariableAssignment]Plan
+}
+
 // Insert represents an insert plan.
 type Insert struct {
 	baseLogicalPlan
@@ -15,7 +15,10 @@ package plan
 import (
 	"github.com/juju/errors"
 	"github.com/ngaut/log"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/evaluator"
 	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/mysql"
 )
 
 func addSelection(p Plan, child LogicalPlan, conditions []expression.Expression, allocator *idAllocator) error {
@@ -32,6 +35,10 @@ func addSelection(p Plan, child LogicalPlan, conditions []expression.Expression,
 func columnSubstitute(expr expression.Expression, schema expression.Schema, newExprs []expression.Expression) expression.Expression {
 	switch v := expr.(type) {
 	case *expression.Column:
+		// The correlated column comes from the outer plan, so it is kept as it is.
+		if v.Correlated {
+			return v
+		}
 		id := schema.GetIndex(v)
 		if id == -1 {
 			log.Errorf("Can't find columns %s in schema %s", v.ToString(), schema.ToString())
@@ -47,8 +54,8 @@ func columnSubstitute(expr expression.Expression, schema expression.Schema, newE
 
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *Selection) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retP LogicalPlan, err error) {
-	conditions := p.Conditions
-	retConditions, child, err1 := p.GetChildByIndex(0).(LogicalPlan).PredicatePushDown(append(conditions, predicates...))
+	conditions := removeDupConditions(propagateConstant(append(p.Conditions, predicates...)))
+	retConditions, child, err1 := p.GetChildByIndex(0).(LogicalPlan).PredicatePushDown(conditions)
 	if err1 != nil {
 		return nil, nil, errors.Trace(err1)
 	}
@@ -78,8 +85,9 @@ func (p *NewTableDual) PredicatePushDown(predicates []expression.Expression) ([]
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *Join) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan, err error) {
 	//TODO: add null rejecter.
-	groups, valid := tryToGetJoinGroup(p)
+	groups, conds, valid := tryToGetJoinGroup(p)
 	if valid {
+		predicates = append(predicates, conds...)
 		e := joinReOrderSolver{allocator: p.allocator}
 		e.reorderJoin(groups, predicates)
 		newJoin := e.resultJoin
@@ -92,6 +100,15 @@ func (p *Join) PredicatePushDown(predicates []expression.Expression) (ret []expr
 	retPlan = p
 	leftPlan := p.GetChildByIndex(0).(LogicalPlan)
 	rightPlan := p.GetChildByIndex(1).(LogicalPlan)
+	switch p.JoinType {
+	case InnerJoin:
+		predicates = append(predicates, p.onConstantConditions(predicates)...)
+	case LeftOuterJoin:
+		// Only the rows of the inner side are filtered by the ON conditions.
+		p.RightConditions = append(p.RightConditions, filterBySchema(p.onConstantConditions(nil), rightPlan.GetSchema())...)
+	case RightOuterJoin:
+		p.LeftConditions = append(p.LeftConditions, filterBySchema(p.onConstantConditions(nil), leftPlan.GetSchema())...)
+	}
 	equalCond, leftPushCond, rightPushCond, otherCond := extractOnCondition(predicates, leftPlan, rightPlan)
 	if p.JoinType == LeftOuterJoin || p.JoinType == SemiJoinWithAux {
 		rightCond = p.RightConditions
@@ -106,8 +123,8 @@ func (p *Join) PredicatePushDown(predicates []expression.Expression) (ret []expr
 		ret = append(expression.ScalarFuncs2Exprs(equalCond), otherCond...)
 		ret = append(ret, leftPushCond...)
 	} else {
-		leftCond = append(p.LeftConditions, leftPushCond...)
-		rightCond = append(p.RightConditions, rightPushCond...)
+		leftCond = removeDupConditions(append(p.LeftConditions, leftPushCond...))
+		rightCond = removeDupConditions(append(p.RightConditions, rightPushCond...))
 		p.LeftConditions = nil
 		p.RightConditions = nil
 	}
@@ -132,12 +149,175 @@ func (p *Join) PredicatePushDown(predicates []expression.Expression) (ret []expr
 		}
 	}
 	if p.JoinType == InnerJoin {
-		p.EqualConditions = append(p.EqualConditions, equalCond...)
-		p.OtherConditions = append(p.OtherConditions, otherCond...)
+		// The where conditions may repeat the ON conditions.
+		for _, eq := range equalCond {
+			if !containsCondition(expression.ScalarFuncs2Exprs(p.EqualConditions), eq) {
+				p.EqualConditions = append(p.EqualConditions, eq)
+			}
+		}
+		p.OtherConditions = removeDupConditions(append(p.OtherConditions, otherCond...))
+	}
+	if p.JoinType == LeftOuterJoin {
+		p.rightUnique = p.isRightUnique()
 	}
 	return
 }
 
+// onConstantConditions returns the equalities between the columns and the constants implied by the ON
+// conditions and the predicates, but not already in them, e.g. t2.a = 5 is implied by t1.a = t2.a and t1.a = 5.
+func (p *Join) onConstantConditions(predicates []expression.Expression) []expression.Expression {
+	conds := expression.ScalarFuncs2Exprs(p.EqualConditions)
+	conds = append(conds, p.LeftConditions...)
+	conds = append(conds, p.RightConditions...)
+	conds = append(conds, p.OtherConditions...)
+	conds = append(conds, predicates...)
+	n := len(conds)
+	return propagateConstant(conds)[n:]
+}
+
+// removeDupConditions removes the conditions that are equal to the earlier ones, e.g. a where condition that
+// repeats an ON condition, so they aren't evaluated twice.
+func removeDupConditions(conditions []expression.Expression) []expression.Expression {
+	result := make([]expression.Expression, 0, len(conditions))
+	for _, cond := range conditions {
+		if !containsCondition(result, cond) {
+			result = append(result, cond)
+		}
+	}
+	return result
+}
+
+func containsCondition(conditions []expression.Expression, cond expression.Expression) bool {
+	for _, c := range conditions {
+		if c.Equal(cond) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterBySchema returns the conditions whose columns are all in the schema.
+func filterBySchema(conditions []expression.Expression, schema expression.Schema) []expression.Expression {
+	var result []expression.Expression
+	for _, cond := range conditions {
+		cols, _ := extractColumn(cond, nil, nil)
+		inSchema := true
+		for _, col := range cols {
+			if schema.GetIndex(col) == -1 {
+				inSchema = false
+				break
+			}
+		}
+		if inSchema {
+			result = append(result, cond)
+		}
+	}
+	return result
+}
+
+// isRightUnique checks if the right child is unique on the join keys, e.g. it's grouped by the keys or
+// the keys cover a unique index, so the join is one-to-at-most-one.
+func (p *Join) isRightUnique() bool {
+	if len(p.EqualConditions) == 0 {
+		return false
+	}
+	keys := make([]*expression.Column, 0, len(p.EqualConditions))
+	for _, eq := range p.EqualConditions {
+		ln := eq.Args[0].(*expression.Column)
+		rn := eq.Args[1].(*expression.Column)
+		// The keys are converted to the same type to build the hash key, which may make different values equal.
+		if lt, rt := ln.GetType(), rn.GetType(); lt == nil || rt == nil || lt.Tp != rt.Tp {
+			return false
+		}
+		keys = append(keys, rn)
+	}
+	return isUniqueOn(p.GetChildByIndex(1).(LogicalPlan), keys)
+}
+
+// isUniqueOn checks if no two rows of the plan have the same not null values on the columns.
+func isUniqueOn(p LogicalPlan, cols []*expression.Column) bool {
+	switch x := p.(type) {
+	case *MaxOneRow, *NewTableDual:
+		return true
+	case *Selection, *NewSort, *Trim, *Limit, *SelectLock:
+		return isUniqueOn(p.GetChildByIndex(0).(LogicalPlan), cols)
+	case *Projection:
+		childCols := make([]*expression.Column, 0, len(cols))
+		for _, col := range cols {
+			idx := x.GetSchema().GetIndex(col)
+			if idx == -1 {
+				return false
+			}
+			childCol, ok := x.Exprs[idx].(*expression.Column)
+			if !ok || childCol.Correlated {
+				return false
+			}
+			childCols = append(childCols, childCol)
+		}
+		return isUniqueOn(p.GetChildByIndex(0).(LogicalPlan), childCols)
+	case *Aggregation:
+		for _, item := range x.GroupByItems {
+			found := false
+			for _, col := range cols {
+				idx := x.GetSchema().GetIndex(col)
+				if idx == -1 || x.AggFuncs[idx].GetName() != ast.AggFuncFirstRow {
+					continue
+				}
+				if arg, ok := x.AggFuncs[idx].GetArgs()[0].(*expression.Column); ok && arg.Equal(item) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case *Distinct:
+		used := make([]bool, len(x.GetSchema()))
+		for _, col := range cols {
+			if idx := x.GetSchema().GetIndex(col); idx != -1 {
+				used[idx] = true
+			}
+		}
+		for _, u := range used {
+			if !u {
+				return false
+			}
+		}
+		return true
+	case *DataSource:
+		names := make(map[string]bool, len(cols))
+		for _, col := range cols {
+			idx := x.GetSchema().GetIndex(col)
+			if idx == -1 {
+				return false
+			}
+			colInfo := x.Columns[idx]
+			if x.Table.PKIsHandle && mysql.HasPriKeyFlag(colInfo.Flag) {
+				return true
+			}
+			names[colInfo.Name.L] = true
+		}
+		for _, idx := range x.Table.Indices {
+			if !idx.Unique {
+				continue
+			}
+			covered := true
+			for _, idxCol := range idx.Columns {
+				if !names[idxCol.Name.L] {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *Projection) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan, err error) {
 	retPlan = p
@@ -193,10 +373,101 @@ func (p *NewUnion) PredicatePushDown(predicates []expression.Expression) (ret []
 }
 
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
-func (p *Aggregation) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
-	// TODO: implement aggregation push down.
-	p.GetChildByIndex(0).(LogicalPlan).PredicatePushDown(nil)
-	return predicates, p, nil
+func (p *Aggregation) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan, err error) {
+	// The conditions which only reference the group by columns can be evaluated before aggregation,
+	// e.g. select * from (select a, count(*) from t group by a) k where k.a > 1 => the condition a > 1 can be pushed to t.
+	var condsToPush []expression.Expression
+	exprs := make([]expression.Expression, 0, len(p.AggFuncs))
+	for _, fun := range p.AggFuncs {
+		var arg expression.Expression
+		if len(fun.GetArgs()) > 0 {
+			arg = fun.GetArgs()[0]
+		}
+		exprs = append(exprs, arg)
+	}
+	for _, cond := range predicates {
+		// Aggregation without group by items always returns one row, so no condition can be pushed.
+		if len(p.GroupByItems) > 0 && p.onlyReferGroupByColumns(cond) {
+			condsToPush = append(condsToPush, columnSubstitute(cond.DeepCopy(), p.GetSchema(), exprs))
+		} else {
+			ret = append(ret, cond)
+		}
+	}
+	_, _, err = p.baseLogicalPlan.PredicatePushDown(condsToPush)
+	return ret, p, errors.Trace(err)
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *Expand) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan, err error) {
+	// The child columns are passed through unchanged, so the conditions only referencing them can be pushed,
+	// but the padded columns differ among the grouping sets.
+	child := p.GetChildByIndex(0).(LogicalPlan)
+	var push []expression.Expression
+	for _, cond := range predicates {
+		extractedCols, _ := extractColumn(cond, nil, nil)
+		canPush := true
+		for _, col := range extractedCols {
+			if child.GetSchema().GetIndex(col) == -1 {
+				canPush = false
+				break
+			}
+		}
+		if canPush {
+			push = append(push, cond)
+		} else {
+			ret = append(ret, cond)
+		}
+	}
+	_, _, err = p.baseLogicalPlan.PredicatePushDown(push)
+	return ret, p, errors.Trace(err)
+}
+
+// onlyReferGroupByColumns checks if the condition only references the first row of the group by columns.
+// A condition referencing no column or calling a dynamic function like rand() is evaluated once per group,
+// so it can't be evaluated on the input rows.
+func (p *Aggregation) onlyReferGroupByColumns(cond expression.Expression) bool {
+	cols, _ := extractColumn(cond, nil, nil)
+	if len(cols) == 0 || hasDynamicFunc(cond) {
+		return false
+	}
+	for _, col := range cols {
+		id := p.GetSchema().GetIndex(col)
+		if id == -1 || p.AggFuncs[id].GetName() != ast.AggFuncFirstRow {
+			return false
+		}
+		arg, ok := p.AggFuncs[id].GetArgs()[0].(*expression.Column)
+		if !ok {
+			return false
+		}
+		isGroupByColumn := false
+		for _, item := range p.GroupByItems {
+			if arg.Equal(item) {
+				isGroupByColumn = true
+				break
+			}
+		}
+		if !isGroupByColumn {
+			return false
+		}
+	}
+	return true
+}
+
+// hasDynamicFunc checks if the expression calls a function in evaluator.DynamicFuncs.
+func hasDynamicFunc(expr expression.Expression) bool {
+	fun, ok := expr.(*expression.ScalarFunction)
+	if !ok {
+		return false
+	}
+	if _, ok := evaluator.DynamicFuncs[fun.FuncName.L]; ok {
+		return true
+	}
+	for _, arg := range fun.Args {
+		if hasDynamicFunc(arg) {
+			return true
+		}
+	}
+	return false
 }
 
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
@@ -222,7 +493,14 @@ func (p *Apply) PredicatePushDown(predicates []expression.Expression) (ret []exp
 	if err != nil {
 		return nil, nil, errors.Trace(err)
 	}
-	return append(ret, childRet...), p, nil
+	// The conditions on the outer side are evaluated below the apply, so the inner plan is executed for fewer rows.
+	if len(childRet) > 0 {
+		err = addSelection(p, child, childRet, p.allocator)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	return ret, p, nil
 }
 
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
@@ -262,6 +540,18 @@ func (p *Distinct) PredicatePushDown(predicates []expression.Expression) ([]expr
 	return ret, p, errors.Trace(err)
 }
 
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *Do) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
+	ret, _, err := p.baseLogicalPlan.PredicatePushDown(predicates)
+	return ret, p, errors.Trace(err)
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *NewUpdate) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
+	ret, _, err := p.baseLogicalPlan.PredicatePushDown(predicates)
+	return ret, p, errors.Trace(err)
+}
+
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *Insert) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
 	ret, _, err := p.baseLogicalPlan.PredicatePushDown(predicates)
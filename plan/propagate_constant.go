@@ -0,0 +1,105 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// propagateConstant appends the equalities between the columns and the constants implied by the conditions,
+// e.g. a = 10 is implied by a = b and b = 10, so the ranges of a can be extracted from the conditions and they
+// can be pushed to the side of a join where a comes from. The conditions are kept, because they are implied
+// by the new ones only if the columns have the same type.
+func propagateConstant(conditions []expression.Expression) []expression.Expression {
+	var constCols []*expression.Column
+	var constants []*expression.Constant
+	var colEqualities [][2]*expression.Column
+	for _, cond := range conditions {
+		col, con, ok := columnEqualConstant(cond)
+		if ok {
+			constCols = append(constCols, col)
+			constants = append(constants, con)
+			continue
+		}
+		if lCol, rCol, ok := columnEqualColumn(cond); ok {
+			colEqualities = append(colEqualities, [2]*expression.Column{lCol, rCol})
+		}
+	}
+	// Each round equates at least one more column to a constant, e.g. a = b, b = c and c = 10 need two rounds.
+	for changed := true; changed; {
+		changed = false
+		for _, eq := range colEqualities {
+			for i := 0; i < 2; i++ {
+				col, other := eq[i], eq[1-i]
+				idx := findColumn(constCols, other)
+				if idx == -1 || findColumn(constCols, col) != -1 {
+					continue
+				}
+				con := constants[idx]
+				newCond, _ := expression.NewFunction(ast.EQ, types.NewFieldType(mysql.TypeTiny), col.DeepCopy(), con.DeepCopy())
+				conditions = append(conditions, newCond)
+				constCols = append(constCols, col)
+				constants = append(constants, con)
+				changed = true
+			}
+		}
+	}
+	return conditions
+}
+
+// columnEqualConstant checks if the condition is like col = constant or constant = col.
+func columnEqualConstant(cond expression.Expression) (*expression.Column, *expression.Constant, bool) {
+	f, ok := cond.(*expression.ScalarFunction)
+	if !ok || f.FuncName.L != ast.EQ {
+		return nil, nil, false
+	}
+	for i := 0; i < 2; i++ {
+		col, isCol := f.Args[i].(*expression.Column)
+		con, isCon := f.Args[1-i].(*expression.Constant)
+		if isCol && isCon && !col.Correlated {
+			return col, con, true
+		}
+	}
+	return nil, nil, false
+}
+
+// columnEqualColumn checks if the condition is like col1 = col2, in which the columns have the same type.
+func columnEqualColumn(cond expression.Expression) (*expression.Column, *expression.Column, bool) {
+	f, ok := cond.(*expression.ScalarFunction)
+	if !ok || f.FuncName.L != ast.EQ {
+		return nil, nil, false
+	}
+	lCol, lOk := f.Args[0].(*expression.Column)
+	rCol, rOk := f.Args[1].(*expression.Column)
+	if !lOk || !rOk || lCol.Correlated || rCol.Correlated {
+		return nil, nil, false
+	}
+	lType, rType := lCol.GetType(), rCol.GetType()
+	if lType == nil || rType == nil || lType.Tp != rType.Tp {
+		return nil, nil, false
+	}
+	return lCol, rCol, true
+}
+
+func findColumn(cols []*expression.Column, col *expression.Column) int {
+	for i, c := range cols {
+		if c.Equal(col) {
+			return i
+		}
+	}
+	return -1
+}
@@ -13,25 +13,104 @@
 
 package plan
 
+import "math"
+
 func insertLimit(p PhysicalPlan, l *Limit) *Limit {
 	l.SetSchema(p.GetSchema())
+	l.setRowCount(uint64(math.Min(p.RowCount(), float64(l.Offset+l.Count))))
 	l.SetChildren(p)
 	p.SetParents(l)
 	return l
 }
 
+// maxRowCount returns the upper bound of the row count that the plan can produce,
+// the second return value is false if the row count can't be bounded.
+func maxRowCount(p PhysicalPlan) (uint64, bool) {
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		for _, rg := range x.Ranges {
+			if rg.LowVal != rg.HighVal {
+				return 0, false
+			}
+		}
+		return uint64(len(x.Ranges)), true
+	case *PhysicalIndexScan:
+		if !x.Index.Unique {
+			return 0, false
+		}
+		for _, rg := range x.Ranges {
+			if len(rg.LowVal) != len(x.Index.Columns) || !rg.IsPoint() {
+				return 0, false
+			}
+			// Unique index allows multiple null values.
+			for _, d := range rg.LowVal {
+				if d.IsNull() {
+					return 0, false
+				}
+			}
+		}
+		return uint64(len(x.Ranges)), true
+	case *Aggregation:
+		if len(x.GroupByItems) == 0 {
+			return 1, true
+		}
+	case *MaxOneRow, *Exists, *NewTableDual:
+		return 1, true
+	case *Limit:
+		return x.Count, true
+	case *Selection, *Projection, *Trim, *NewSort, *SelectLock, *Distinct, *PhysicalApply, *PhysicalHashSemiJoin:
+		// An apply or a semi join outputs at most one row for each outer row.
+		return maxRowCount(p.GetChildByIndex(0).(PhysicalPlan))
+	case *PhysicalHashJoin:
+		lCnt, lOK := maxRowCount(p.GetChildByIndex(0).(PhysicalPlan))
+		rCnt, rOK := maxRowCount(p.GetChildByIndex(1).(PhysicalPlan))
+		if !lOK || !rOK {
+			return 0, false
+		}
+		// An unmatched outer row is output once padded with nulls.
+		if x.JoinType == LeftOuterJoin && rCnt == 0 {
+			rCnt = 1
+		}
+		if x.JoinType == RightOuterJoin && lCnt == 0 {
+			lCnt = 1
+		}
+		return lCnt * rCnt, true
+	}
+	return 0, false
+}
+
+// boundRowCount returns the estimated row count, which is bounded by the row count that the plan can produce.
+func boundRowCount(count uint64, p PhysicalPlan) uint64 {
+	if p == nil {
+		return count
+	}
+	if cnt, ok := maxRowCount(p); ok && cnt < count {
+		return cnt
+	}
+	return count
+}
+
 // PushLimit implements PhysicalPlan PushLimit interface.
 func (p *Limit) PushLimit(l *Limit) PhysicalPlan {
-	child := p.GetChildByIndex(0).(PhysicalPlan)
-	newChild := child.PushLimit(p)
 	if l != nil {
-		p.Count = l.Count
-		p.Offset = l.Offset
-		p.SetChildren(newChild)
-		newChild.SetParents(p)
-		return p
+		// The outer limit skips l.Offset rows and returns at most l.Count rows of the ones returned by this limit,
+		// e.g. LIMIT 1, 2 over LIMIT 2, 10 is LIMIT 3, 2.
+		count := uint64(0)
+		if p.Count > l.Offset {
+			count = p.Count - l.Offset
+		}
+		if count > l.Count {
+			count = l.Count
+		}
+		p.Offset += l.Offset
+		p.Count = count
+	}
+	child := p.GetChildByIndex(0).(PhysicalPlan)
+	// If the child never produces more rows than the limit, the limit is redundant.
+	if cnt, ok := maxRowCount(child); ok && p.Offset == 0 && cnt <= p.Count {
+		return child.PushLimit(nil)
 	}
-	return newChild
+	return child.PushLimit(p)
 }
 
 // PushLimit implements PhysicalPlan PushLimit interface.
@@ -164,6 +243,18 @@ func (p *PhysicalApply) PushLimit(l *Limit) PhysicalPlan {
 	return p
 }
 
+// PushLimit implements PhysicalPlan PushLimit interface.
+func (p *PhysicalCache) PushLimit(l *Limit) PhysicalPlan {
+	// The cached rows are shared by all the executions, so the limit can't be pushed into the child.
+	newChild := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
+	p.SetChildren(newChild)
+	newChild.SetParents(p)
+	if l != nil {
+		return insertLimit(p, l)
+	}
+	return p
+}
+
 // PushLimit implements PhysicalPlan PushLimit interface.
 func (p *Aggregation) PushLimit(l *Limit) PhysicalPlan {
 	newChild := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
@@ -175,6 +266,18 @@ func (p *Aggregation) PushLimit(l *Limit) PhysicalPlan {
 	return p
 }
 
+// PushLimit implements PhysicalPlan PushLimit interface.
+func (p *Expand) PushLimit(l *Limit) PhysicalPlan {
+	// Every child row is expanded to several rows, so the limit can't be pushed into the child.
+	newChild := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
+	p.SetChildren(newChild)
+	newChild.SetParents(p)
+	if l != nil {
+		return insertLimit(p, l)
+	}
+	return p
+}
+
 // PushLimit implements PhysicalPlan PushLimit interface.
 func (p *Distinct) PushLimit(l *Limit) PhysicalPlan {
 	newChild := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
@@ -226,6 +329,22 @@ func (p *PhysicalTableScan) PushLimit(l *Limit) PhysicalPlan {
 	return p
 }
 
+// PushLimit implements PhysicalPlan PushLimit interface.
+func (p *Do) PushLimit(_ *Limit) PhysicalPlan {
+	np := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
+	p.SetChildren(np)
+	np.SetParents(p)
+	return p
+}
+
+// PushLimit implements PhysicalPlan PushLimit interface.
+func (p *NewUpdate) PushLimit(_ *Limit) PhysicalPlan {
+	np := p.GetChildByIndex(0).(PhysicalPlan).PushLimit(nil)
+	p.SetChildren(np)
+	np.SetParents(p)
+	return p
+}
+
 // PushLimit implements PhysicalPlan PushLimit interface.
 func (p *Insert) PushLimit(_ *Limit) PhysicalPlan {
 	if len(p.GetChildren()) == 0 {
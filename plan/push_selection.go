@@ -0,0 +1,143 @@
+// Copyright 2016 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/expression"
+)
+
+// pushSelectionIntoJoin moves the conditions of a selection above a hash join, which only refer to one side
+// of the join, onto that side's input, so the join processes fewer rows. For an inner join, the conditions
+// referring to both sides become the other conditions of the join. It's applied after the physical plan
+// is chosen, to catch the conditions that are left above the join.
+func pushSelectionIntoJoin(p PhysicalPlan) (PhysicalPlan, error) {
+	if apply, ok := p.(*PhysicalApply); ok {
+		inner, err := pushSelectionIntoJoin(apply.InnerPlan)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		apply.InnerPlan = inner
+	}
+	if sel, ok := p.(*Selection); ok {
+		if join, ok := sel.GetChildByIndex(0).(*PhysicalHashJoin); ok {
+			np, err := sel.pushIntoJoin(join)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			p = np
+		}
+	}
+	children := make([]Plan, 0, len(p.GetChildren()))
+	for _, child := range p.GetChildren() {
+		newChild, err := pushSelectionIntoJoin(child.(PhysicalPlan))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		newChild.SetParents(p)
+		children = append(children, newChild)
+	}
+	p.SetChildren(children...)
+	return p, nil
+}
+
+// pushIntoJoin splits the conditions of the selection by the side of join they refer to, and returns the plan
+// that replaces the selection, which is the join if no condition remains.
+func (p *Selection) pushIntoJoin(join *PhysicalHashJoin) (PhysicalPlan, error) {
+	lChild := join.GetChildByIndex(0).(PhysicalPlan)
+	rChild := join.GetChildByIndex(1).(PhysicalPlan)
+	// The conditions on the outer side of an outer join can be pushed, but those on the inner side can't,
+	// because the inner side is padded with nulls when it isn't matched.
+	pushLeft := join.JoinType == InnerJoin || join.JoinType == LeftOuterJoin
+	pushRight := join.JoinType == InnerJoin || join.JoinType == RightOuterJoin
+	var leftConds, rightConds, restConds []expression.Expression
+	for _, cond := range p.Conditions {
+		cols, _ := extractColumn(cond, nil, nil)
+		allFromLeft, allFromRight := true, true
+		for _, col := range cols {
+			if lChild.GetSchema().GetIndex(col) == -1 {
+				allFromLeft = false
+			}
+			if rChild.GetSchema().GetIndex(col) == -1 {
+				allFromRight = false
+			}
+		}
+		if allFromLeft && pushLeft {
+			leftConds = append(leftConds, cond)
+		} else if allFromRight && pushRight {
+			rightConds = append(rightConds, cond)
+		} else {
+			restConds = append(restConds, cond)
+		}
+	}
+	if len(leftConds) == 0 && len(rightConds) == 0 {
+		return p, nil
+	}
+	newLChild, err := p.addSelection(lChild, leftConds)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	newRChild, err := p.addSelection(rChild, rightConds)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	join.SetChildren(newLChild, newRChild)
+	newLChild.SetParents(join)
+	newRChild.SetParents(join)
+	if join.JoinType == InnerJoin {
+		// The schema of an inner join is composed of the children's, so the indices of columns are unchanged.
+		join.OtherConditions = append(join.OtherConditions, restConds...)
+		restConds = nil
+	}
+	if len(restConds) == 0 {
+		return join, nil
+	}
+	sel := &Selection{
+		baseLogicalPlan: newBaseLogicalPlan(Sel, p.allocator),
+		Conditions:      restConds,
+	}
+	sel.initID()
+	sel.SetSchema(join.GetSchema())
+	sel.SetChildren(join)
+	join.SetParents(sel)
+	return sel, nil
+}
+
+// addSelection returns a selection over the child with the conditions, which are resolved against the schema
+// of the child. If the child is a selection itself, the conditions are merged into it.
+func (p *Selection) addSelection(child PhysicalPlan, conds []expression.Expression) (PhysicalPlan, error) {
+	if len(conds) == 0 {
+		return child, nil
+	}
+	for i, cond := range conds {
+		newCond, err := retrieveColumnsInExpression(cond.DeepCopy(), child.GetSchema())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		conds[i] = newCond
+	}
+	if childSel, ok := child.(*Selection); ok {
+		conds = append(append([]expression.Expression(nil), childSel.Conditions...), conds...)
+		child = childSel.GetChildByIndex(0).(PhysicalPlan)
+	}
+	sel := &Selection{
+		baseLogicalPlan: newBaseLogicalPlan(Sel, p.allocator),
+		Conditions:      conds,
+	}
+	sel.initID()
+	sel.SetSchema(child.GetSchema())
+	sel.SetChildren(child)
+	child.SetParents(sel)
+	return sel, nil
+}
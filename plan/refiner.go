@@ -15,6 +15,7 @@ package plan
 
 import (
 	"math"
+	"sort"
 
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
@@ -96,6 +97,9 @@ func buildIndexRange(p *IndexScan) error {
 }
 
 func buildNewIndexRange(p *PhysicalIndexScan) error {
+	if p.accessInRow {
+		return errors.Trace(buildRowInIndexRanges(p))
+	}
 	rb := rangeBuilder{}
 	if p.accessEqualCount > 0 {
 		// Build ranges for equal access conditions.
@@ -202,6 +206,112 @@ func getEQFunctionOffset(expr expression.Expression, cols []*model.IndexColumn)
 	return -1
 }
 
+// getRowInOffsets returns the offsets in the index of the columns compared by the condition like
+// (c1, c2) in ((1, 2), (3, 4)), in which the columns are a prefix of the index columns in any order and the
+// rows in the list are constants. It returns nil if the condition isn't like this.
+func getRowInOffsets(expr expression.Expression, cols []*model.IndexColumn) []int {
+	f, ok := expr.(*expression.ScalarFunction)
+	if !ok || f.FuncName.L != ast.In {
+		return nil
+	}
+	row, ok := f.Args[0].(*expression.ScalarFunction)
+	if !ok || row.FuncName.L != ast.RowFunc || len(row.Args) > len(cols) {
+		return nil
+	}
+	offsets := make([]int, len(row.Args))
+	used := make([]bool, len(row.Args))
+	for i, arg := range row.Args {
+		c, ok := arg.(*expression.Column)
+		if !ok {
+			return nil
+		}
+		offsets[i] = -1
+		for j := range used {
+			if !used[j] && cols[j].Name.L == c.ColName.L && cols[j].Length == types.UnspecifiedLength {
+				offsets[i] = j
+				used[j] = true
+				break
+			}
+		}
+		if offsets[i] == -1 {
+			return nil
+		}
+	}
+	for _, arg := range f.Args[1:] {
+		con, ok := arg.(*expression.Constant)
+		if !ok || con.Value.Kind() != types.KindRow || len(con.Value.GetRow()) != len(offsets) {
+			return nil
+		}
+	}
+	return offsets
+}
+
+// buildRowInIndexRanges builds a point range for each distinct row in the list of the row IN condition, which
+// is the only access condition of the index scan. The ranges are sorted in the index order.
+func buildRowInIndexRanges(p *PhysicalIndexScan) error {
+	f := p.AccessCondition[0].(*expression.ScalarFunction)
+	offsets := getRowInOffsets(f, p.Index.Columns)
+	keys := make([]types.Datum, 0, len(f.Args)-1)
+	for _, arg := range f.Args[1:] {
+		vals := make([]types.Datum, len(offsets))
+		hasNull := false
+		for i, d := range arg.(*expression.Constant).Value.GetRow() {
+			// A row with null never equals to any row.
+			if d.IsNull() {
+				hasNull = true
+				break
+			}
+			vals[offsets[i]] = d
+		}
+		if !hasNull {
+			var key types.Datum
+			key.SetRow(vals)
+			keys = append(keys, key)
+		}
+	}
+	sorter := &datumSorter{datums: keys}
+	sort.Sort(sorter)
+	if sorter.err != nil {
+		return errors.Trace(sorter.err)
+	}
+	p.Ranges = make([]*IndexRange, 0, len(keys))
+	for i, key := range keys {
+		if i > 0 {
+			// The keys are sorted, and they have been compared, so there is no error.
+			if cmp, _ := key.CompareDatum(keys[i-1]); cmp == 0 {
+				continue
+			}
+		}
+		vals := key.GetRow()
+		p.Ranges = append(p.Ranges, &IndexRange{
+			LowVal:  vals,
+			HighVal: append([]types.Datum(nil), vals...),
+		})
+	}
+	return nil
+}
+
+type datumSorter struct {
+	datums []types.Datum
+	err    error
+}
+
+func (s *datumSorter) Len() int {
+	return len(s.datums)
+}
+
+func (s *datumSorter) Less(i, j int) bool {
+	cmp, err := s.datums[i].CompareDatum(s.datums[j])
+	if err != nil {
+		s.err = err
+	}
+	return cmp < 0
+}
+
+func (s *datumSorter) Swap(i, j int) {
+	s.datums[i], s.datums[j] = s.datums[j], s.datums[i]
+}
+
 func detachIndexScanConditions(conditions []expression.Expression, indexScan *PhysicalIndexScan) ([]expression.Expression, []expression.Expression) {
 	accessConds := make([]expression.Expression, len(indexScan.Index.Columns))
 	var filterConds []expression.Expression
@@ -211,6 +321,19 @@ func detachIndexScanConditions(conditions []expression.Expression, indexScan *Ph
 			accessConds[offset] = cond
 		}
 	}
+	// Without an equal condition on the first index column, a row IN condition on the prefix of the index
+	// columns is used to scan the points of the rows, and the other conditions are filters.
+	if accessConds[0] == nil {
+		for i, cond := range conditions {
+			if getRowInOffsets(cond, indexScan.Index.Columns) == nil {
+				continue
+			}
+			indexScan.accessInRow = true
+			filterConds = append(filterConds, conditions[:i]...)
+			filterConds = append(filterConds, conditions[i+1:]...)
+			return []expression.Expression{cond}, filterConds
+		}
+	}
 	for i, cond := range accessConds {
 		if cond == nil {
 			accessConds = accessConds[:i]
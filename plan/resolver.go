@@ -59,6 +59,9 @@ type nameResolver struct {
 	DefaultSchema   model.CIStr
 	Err             error
 	useOuterContext bool
+	// redundantFields are the result fields of joins coalesced by USING or NATURAL with the fields of the same
+	// names on the other side, which are only resolved by qualified names.
+	redundantFields map[*ast.ResultField]bool
 
 	contextStack []*resolverContext
 }
@@ -73,6 +76,8 @@ type resolverContext struct {
 	derivedTableMap map[string]int
 	// tableSources collected in from clause.
 	tables []*ast.TableSource
+	// result fields of the from clause, which an unqualified wildcard expands to.
+	fromFields []*ast.ResultField
 	// result fields collected in select field list.
 	fieldList []*ast.ResultField
 	// result fields collected in group by clause.
@@ -191,6 +196,8 @@ func (nr *nameResolver) Enter(inNode ast.Node) (outNode ast.Node, skipChildren b
 		nr.currentContext().inFieldList = true
 	case *ast.GroupByClause:
 		nr.currentContext().inGroupBy = true
+	case *ast.HandlerStmt:
+		nr.pushContext()
 	case *ast.HavingClause:
 		nr.currentContext().inHaving = true
 	case *ast.InsertStmt:
@@ -265,7 +272,9 @@ func (nr *nameResolver) Leave(inNode ast.Node) (node ast.Node, ok bool) {
 		nr.handleJoin(v)
 		nr.popJoin()
 	case *ast.TableRefsClause:
-		nr.currentContext().inTableRefs = false
+		ctx := nr.currentContext()
+		ctx.inTableRefs = false
+		ctx.fromFields = v.TableRefs.GetResultFields()
 	case *ast.FieldList:
 		nr.handleFieldList(v)
 		nr.currentContext().inFieldList = false
@@ -278,6 +287,8 @@ func (nr *nameResolver) Leave(inNode ast.Node) (node ast.Node, ok bool) {
 				ctx.groupBy = append(ctx.groupBy, x.Refer)
 			}
 		}
+	case *ast.HandlerStmt:
+		nr.popContext()
 	case *ast.HavingClause:
 		nr.currentContext().inHaving = false
 	case *ast.OrderByClause:
@@ -449,6 +460,10 @@ func (nr *nameResolver) handleJoin(j *ast.Join) {
 		j.SetResultFields(j.Left.GetResultFields())
 		return
 	}
+	if len(j.Using) > 0 || j.NaturalJoin {
+		nr.handleCoalescedJoin(j)
+		return
+	}
 	leftLen := len(j.Left.GetResultFields())
 	rightLen := len(j.Right.GetResultFields())
 	rfs := make([]*ast.ResultField, leftLen+rightLen)
@@ -457,6 +472,108 @@ func (nr *nameResolver) handleJoin(j *ast.Join) {
 	j.SetResultFields(rfs)
 }
 
+// handleCoalescedJoin sets result fields for join with USING or NATURAL. The coalesced fields are taken from
+// the left side, or from the right side for a right join, and come first in the order of that side, followed
+// by the other fields of that side and the other side, then the redundant fields of the other side.
+func (nr *nameResolver) handleCoalescedJoin(j *ast.Join) {
+	first, second := nr.visibleFields(j.Left.GetResultFields()), nr.visibleFields(j.Right.GetResultFields())
+	if j.Tp == ast.RightJoin {
+		first, second = second, first
+	}
+	var names []string
+	if j.NaturalJoin {
+		for _, rf := range first {
+			if findResultFieldByName(second, resultFieldName(rf)) != -1 {
+				names = append(names, resultFieldName(rf))
+			}
+		}
+	} else {
+		for _, cn := range j.Using {
+			names = append(names, cn.Name.L)
+		}
+	}
+	coalesced := make(map[*ast.ResultField]bool, len(names)*2)
+	var redundant []*ast.ResultField
+	for _, name := range names {
+		firstIdx, secondIdx := findResultFieldByName(first, name), findResultFieldByName(second, name)
+		if firstIdx == -1 || secondIdx == -1 {
+			nr.Err = ErrUnknownColumn.Gen("Unknown column '%s' in 'from clause'", name)
+			return
+		}
+		if firstIdx == -2 || secondIdx == -2 {
+			nr.Err = ErrAmbiguousColumn.Gen("Column '%s' in from clause is ambiguous", name)
+			return
+		}
+		coalesced[first[firstIdx]] = true
+		coalesced[second[secondIdx]] = true
+		redundant = append(redundant, second[secondIdx])
+	}
+	var rfs []*ast.ResultField
+	for _, rf := range first {
+		if coalesced[rf] {
+			rfs = append(rfs, rf)
+		}
+	}
+	for _, rf := range first {
+		if !coalesced[rf] {
+			rfs = append(rfs, rf)
+		}
+	}
+	for _, rf := range second {
+		if !coalesced[rf] {
+			rfs = append(rfs, rf)
+		}
+	}
+	if nr.redundantFields == nil {
+		nr.redundantFields = make(map[*ast.ResultField]bool)
+	}
+	for _, rf := range redundant {
+		nr.redundantFields[rf] = true
+	}
+	// The redundant fields of the children are kept, so they can still be resolved by qualified names.
+	for _, child := range []ast.ResultSetNode{j.Left, j.Right} {
+		for _, rf := range child.GetResultFields() {
+			if nr.redundantFields[rf] {
+				rfs = append(rfs, rf)
+			}
+		}
+	}
+	j.SetResultFields(rfs)
+}
+
+// visibleFields returns the result fields that aren't redundant.
+func (nr *nameResolver) visibleFields(rfs []*ast.ResultField) []*ast.ResultField {
+	visible := make([]*ast.ResultField, 0, len(rfs))
+	for _, rf := range rfs {
+		if !nr.redundantFields[rf] {
+			visible = append(visible, rf)
+		}
+	}
+	return visible
+}
+
+func resultFieldName(rf *ast.ResultField) string {
+	if rf.ColumnAsName.L != "" {
+		return rf.ColumnAsName.L
+	}
+	return rf.Column.Name.L
+}
+
+// findResultFieldByName returns the index of the result field with the name, or -1 if there is none and -2 if
+// there are more than one.
+func findResultFieldByName(rfs []*ast.ResultField, name string) int {
+	idx := -1
+	for i, rf := range rfs {
+		if resultFieldName(rf) == name {
+			if idx != -1 {
+				return -2
+			}
+			idx = i
+		}
+	}
+	return idx
+}
+
 // handleColumnName looks up and sets ResultField for
 // the column name.
 func (nr *nameResolver) handleColumnName(cn *ast.ColumnNameExpr) {
@@ -620,6 +737,9 @@ func (nr *nameResolver) resolveColumnInTableSources(cn *ast.ColumnNameExpr, tabl
 		for _, ts := range tableSources {
 			rfs := ts.GetResultFields()
 			for _, rf := range rfs {
+				if nr.redundantFields[rf] {
+					continue
+				}
 				matchAsName := rf.ColumnAsName.L != "" && rf.ColumnAsName.L == columnNameL
 				matchColumnName := rf.ColumnAsName.L == "" && rf.Column.Name.L == columnNameL
 				if matchAsName || matchColumnName {
@@ -724,9 +844,7 @@ func (nr *nameResolver) createResultFields(field *ast.SelectField) (rfs []*ast.R
 		}
 		tableRfs := []*ast.ResultField{}
 		if field.WildCard.Table.L == "" {
-			for _, v := range ctx.tables {
-				tableRfs = append(tableRfs, v.GetResultFields()...)
-			}
+			tableRfs = nr.visibleFields(ctx.fromFields)
 		} else {
 			name := nr.tableUniqueName(field.WildCard.Schema, field.WildCard.Table)
 			tableIdx, ok1 := ctx.tableMap[name]
@@ -818,7 +936,11 @@ func (nr *nameResolver) tableUniqueName(schema, table model.CIStr) string {
 func (nr *nameResolver) handlePosition(pos *ast.PositionExpr) {
 	ctx := nr.currentContext()
 	if pos.N < 1 || pos.N > len(ctx.fieldList) {
-		nr.Err = errors.Errorf("Unknown column '%d'", pos.N)
+		clause := "order clause"
+		if ctx.inGroupBy {
+			clause = "group statement"
+		}
+		nr.Err = ErrUnknownColumn.Gen("Unknown column '%d' in '%s'", pos.N, clause)
 		return
 	}
 	matched := ctx.fieldList[pos.N-1]
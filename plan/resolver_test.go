@@ -79,6 +79,10 @@ var resolverTestCases = []resolverTestCase{
 	{"select c1 from t1 group by c1 having c1 = 3", true},
 	{"select c1 from t1 group by c1 having c2 = 3", false},
 	{"select c1 from t1 where exists (select c2)", true},
+	{"select c1, c2 from t1 order by 2", true},
+	{"select c1, c2 from t1 order by 3", false},
+	{"select c1 from t1 order by c2, 2", false},
+	{"select c1 from t1 group by 0", false},
 }
 
 func (ts *testNameResolverSuite) TestNameResolver(c *C) {
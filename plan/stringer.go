@@ -75,10 +75,15 @@ func toString(in Plan, strs []string, idxs []int) ([]string, []int) {
 		} else {
 			str = "SemiJoin{" + strings.Join(children, "->") + "}"
 		}
+		if x.Anti {
+			str = "Anti" + str
+		}
 	case *Apply:
 		str = fmt.Sprintf("Apply(%s)", ToString(x.InnerPlan))
 	case *PhysicalApply:
 		str = fmt.Sprintf("Apply(%s)", ToString(x.InnerPlan))
+	case *PhysicalCache:
+		str = "Cache"
 	case *Exists:
 		str = "Exists"
 	case *MaxOneRow:
@@ -153,12 +158,34 @@ func toString(in Plan, strs []string, idxs []int) ([]string, []int) {
 		str = "Projection"
 	case *Aggregation:
 		str = "Aggr"
+		if x.Streamed {
+			str = "StreamAggr"
+		}
+		if x.StreamedDistinct {
+			str = "StreamDistinctAggr"
+		}
+		if x.GroupSkip {
+			str = "GroupSkipAggr"
+		}
+	case *Expand:
+		str = "Expand"
 	case *Aggregate:
 		str = "Aggregate"
 	case *Distinct:
 		str = "Distinct"
+		if x.Streamed {
+			str = "StreamDistinct"
+		}
 	case *Trim:
 		str = "Trim"
+	case *Do:
+		str = "Do"
+	case *Set:
+		str = "Set"
+	case *NewUpdate:
+		str = "Update"
+	case *NewTableDual:
+		str = "Dual"
 	default:
 		str = fmt.Sprintf("%T", in)
 	}
@@ -98,6 +98,11 @@ func (v *typeInferrer) Leave(in ast.Node) (out ast.Node, ok bool) {
 		x.Type.Collate = charset.CollationBin
 	case *ast.SelectStmt:
 		v.selectStmt(x)
+	case *ast.SubqueryExpr:
+		// A scalar subquery has the type of its only column.
+		if rf := x.Query.GetResultFields(); len(rf) == 1 {
+			x.SetType(&rf[0].Column.FieldType)
+		}
 	case *ast.UnaryOperationExpr:
 		v.unaryOperation(x)
 	case *ast.ValueExpr:
@@ -131,7 +136,7 @@ func (v *typeInferrer) selectStmt(x *ast.SelectStmt) {
 func (v *typeInferrer) aggregateFunc(x *ast.AggregateFuncExpr) {
 	name := strings.ToLower(x.F)
 	switch name {
-	case ast.AggFuncCount:
+	case ast.AggFuncCount, ast.AggFuncGrouping:
 		ft := types.NewFieldType(mysql.TypeLonglong)
 		ft.Flen = 21
 		ft.Charset = charset.CharsetBin
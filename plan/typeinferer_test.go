@@ -73,6 +73,7 @@ func (ts *testTypeInferrerSuite) TestInferType(c *C) {
 
 		{"1 > any (select 1)", mysql.TypeLonglong, charset.CharsetBin},
 		{"exists (select 1)", mysql.TypeLonglong, charset.CharsetBin},
+		{"(select c2 from t limit 1) + 1", mysql.TypeDouble, charset.CharsetBin},
 		{"1 in (2, 3)", mysql.TypeLonglong, charset.CharsetBin},
 		{"'abc' like 'abc'", mysql.TypeLonglong, charset.CharsetBin},
 		{"'abc' rlike 'abc'", mysql.TypeLonglong, charset.CharsetBin},
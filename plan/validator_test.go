@@ -52,6 +52,8 @@ func (s *testValidatorSuite) TestValidator(c *C) {
 			errors.New("Incorrect column specifier for column 'id'")},
 		{"create table t(id float auto_increment, key (id))", true, nil},
 		{"create table t(id int auto_increment) ENGINE=MYISAM", true, nil},
+		{"select *, * from t1 join t2 using (id)", false, plan.ErrMultiWildCard},
+		{"select *, t1.*, t2.* from t1 natural join t2", false, nil},
 	}
 	store, err := tidb.NewStore(tidb.EngineGoLevelDBMemory)
 	c.Assert(err, IsNil)
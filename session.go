@@ -37,6 +37,7 @@ import (
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/parser"
 	"github.com/pingcap/tidb/perfschema"
+	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/privilege"
 	"github.com/pingcap/tidb/privilege/privileges"
 	"github.com/pingcap/tidb/sessionctx"
@@ -681,6 +682,11 @@ func CreateSession(store kv.Storage) (Session, error) {
 
 	// session implements autocommit.Checker. Bind it to ctx
 	autocommit.BindAutocommitChecker(s, s)
+
+	// The store knows the regions of the tables if it has a region cache, e.g. tikv.
+	if counter, ok := store.(plan.RegionCounter); ok {
+		plan.BindRegionEstimator(s, plan.NewRegionEstimator(counter))
+	}
 	sessionMu.Lock()
 	defer sessionMu.Unlock()
 
@@ -2202,24 +2202,35 @@ func (s *testSessionSuite) TestMultiColumnIndex(c *C) {
 	mustExecSQL(c, se, "create index idx_c1_c2 on t (c1, c2)")
 	mustExecSQL(c, se, "insert into t values (1, 5)")
 
+	// c1 in (1) is c1 = 1, so the range of c2 is built too.
 	sql := "select c1 from t where c1 in (1) and c2 < 10"
-	expectedExplain := "Index(t.idx_c1_c2)[[1,1]]->Selection->Projection"
+	expectedExplain := "Index(t.idx_c1_c2)[[1 <nil>,1 10)]->Projection"
 	checkPlan(c, se, sql, expectedExplain)
 	mustExecMatch(c, se, sql, [][]interface{}{{1}})
 
 	sql = "select c1 from t where c1 in (1) and c2 > 3"
+	expectedExplain = "Index(t.idx_c1_c2)[(1 3,1 <nil>]]->Projection"
 	checkPlan(c, se, sql, expectedExplain)
 	mustExecMatch(c, se, sql, [][]interface{}{{1}})
 
 	sql = "select c1 from t where c1 in (1) and c2 < 5.1"
+	expectedExplain = "Index(t.idx_c1_c2)[[1 <nil>,1 5.1)]->Projection"
 	checkPlan(c, se, sql, expectedExplain)
 	mustExecMatch(c, se, sql, [][]interface{}{{1}})
 
 	sql = "select c1 from t where c1 in (1.1) and c2 > 3"
-	expectedExplain = "Index(t.idx_c1_c2)[[1.1,1.1]]->Selection->Projection"
+	expectedExplain = "Index(t.idx_c1_c2)[(1.1 3,1.1 <nil>]]->Projection"
 	checkPlan(c, se, sql, expectedExplain)
 	mustExecMatch(c, se, sql, [][]interface{}{})
 
+	sql = "select c1 from t where c1 = 1.1 and c2 > 3"
+	mustExecMatch(c, se, sql, [][]interface{}{})
+
+	sql = "select c1 from t where c1 in (1, 2) and c2 > 3"
+	expectedExplain = "Index(t.idx_c1_c2)[[1,1] [2,2]]->Selection->Projection"
+	checkPlan(c, se, sql, expectedExplain)
+	mustExecMatch(c, se, sql, [][]interface{}{{1}})
+
 	// Test varchar type.
 	mustExecSQL(c, se, "drop table t;")
 	mustExecSQL(c, se, "create table t (c1 varchar(64), c2 varchar(64), index c1_c2 (c1, c2));")
@@ -18,17 +18,21 @@ import (
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/types"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	codeCantGetValidID terror.ErrCode = 1
 	codeCantSetToNull  terror.ErrCode = 2
+	codeWrongValue     terror.ErrCode = 3
 )
 
 var (
 	errCantGetValidID = terror.ClassVariable.New(codeCantGetValidID, "cannot get valid auto-increment id in retry")
 	errCantSetToNull  = terror.ClassVariable.New(codeCantSetToNull, "cannot set variable to null")
+	errWrongValue     = terror.ClassVariable.New(codeWrongValue, "wrong value for variable")
 )
 
 // RetryInfo saves retry information.
@@ -81,6 +85,8 @@ type SessionVars struct {
 	PreparedStmtNameToID map[string]uint32
 	// prepared statement auto increment id
 	preparedStmtID uint32
+	// Handlers are the tables opened by HANDLER OPEN, keyed by the lower case table names.
+	Handlers map[string]*Handler
 
 	// retry information
 	RetryInfo *RetryInfo
@@ -107,6 +113,27 @@ type SessionVars struct {
 
 	// InUpdateStmt indicates if the session is handling update stmt.
 	InUpdateStmt bool
+
+	// FullScanRowLimit is the value of tidb_full_scan_row_limit.
+	FullScanRowLimit int64
+
+	// HashAggGroupLimit is the value of tidb_hash_agg_group_limit.
+	HashAggGroupLimit uint64
+
+	// StmtDeadline is the time after which the current statement is interrupted for exceeding its
+	// MAX_EXECUTION_TIME, zero means no limit.
+	StmtDeadline time.Time
+
+	// warnings of the last statement, which are returned by SHOW WARNINGS.
+	warnings []error
+}
+
+// Handler is the cursor of a table opened by HANDLER OPEN, where HANDLER READ NEXT and PREV continue to read.
+type Handler struct {
+	// IndexName is the lower case name of the index read by the last HANDLER READ that returned rows.
+	IndexName string
+	// LastKey is the index key of the last row read followed by its handle.
+	LastKey []types.Datum
 }
 
 // sessionVarsKeyType is a dummy type to avoid naming collision in context.
@@ -126,8 +153,10 @@ func BindSessionVars(ctx context.Context) {
 		systems:              make(map[string]string),
 		PreparedStmts:        make(map[uint32]interface{}),
 		PreparedStmtNameToID: make(map[string]uint32),
+		Handlers:             make(map[string]*Handler),
 		RetryInfo:            &RetryInfo{},
 		StrictSQLMode:        true,
+		HashAggGroupLimit:    DefHashAggGroupLimit,
 	}
 	ctx.SetValue(sessionVarsKey, v)
 }
@@ -183,6 +212,21 @@ func (s *SessionVars) AddFoundRows(rows uint64) {
 	s.FoundRows += rows
 }
 
+// AppendWarning appends a warning of the current statement.
+func (s *SessionVars) AppendWarning(warn error) {
+	s.warnings = append(s.warnings, warn)
+}
+
+// GetWarnings returns the warnings of the last statement.
+func (s *SessionVars) GetWarnings() []error {
+	return s.warnings
+}
+
+// ClearWarnings clears the warnings before a new statement is executed.
+func (s *SessionVars) ClearWarnings() {
+	s.warnings = nil
+}
+
 // SetStatusFlag sets the session server status variable.
 // If on is ture sets the flag in session status,
 // otherwise removes the flag.
@@ -227,6 +271,20 @@ func (s *SessionVars) SetSystemVar(key string, value types.Datum) error {
 			s.StrictSQLMode = false
 		}
 	}
+	if key == TiDBFullScanRowLimit {
+		limit, err := strconv.ParseInt(sVal, 10, 64)
+		if err != nil || limit < 0 {
+			return errWrongValue.Gen("Variable '%s' can't be set to the value of '%s'", key, sVal)
+		}
+		s.FullScanRowLimit = limit
+	}
+	if key == TiDBHashAggGroupLimit {
+		limit, err := strconv.ParseUint(sVal, 10, 64)
+		if err != nil {
+			return errWrongValue.Gen("Variable '%s' can't be set to the value of '%s'", key, sVal)
+		}
+		s.HashAggGroupLimit = limit
+	}
 	s.systems[key] = sVal
 	return nil
 }
@@ -580,6 +580,9 @@ var defaultSysVars = []*SysVar{
 	{ScopeGlobal | ScopeSession, "min_examined_row_limit", "0"},
 	{ScopeGlobal, "sync_frm", "ON"},
 	{ScopeGlobal, "innodb_online_alter_log_max_size", "134217728"},
+	// TiDB specific variables.
+	{ScopeSession, TiDBFullScanRowLimit, "0"},
+	{ScopeSession, TiDBHashAggGroupLimit, "1000000"},
 }
 
 // SetNamesVariables is the system variable names related to set names statements.
@@ -596,6 +599,15 @@ const (
 	CharsetDatabase = "character_set_database"
 	// CollationDatabase is the name for collation_database system variable.
 	CollationDatabase = "collation_database"
+	// TiDBFullScanRowLimit is the name for tidb_full_scan_row_limit system variable.
+	// Full scan on a table whose estimated row count exceeds it is forbidden, 0 means no limit.
+	TiDBFullScanRowLimit = "tidb_full_scan_row_limit"
+	// TiDBHashAggGroupLimit is the name for tidb_hash_agg_group_limit system variable.
+	// An aggregation whose estimated group count exceeds it is streamed over the input sorted by the group by
+	// columns, instead of keeping all the groups in an in-memory hash table, 0 means no limit.
+	TiDBHashAggGroupLimit = "tidb_hash_agg_group_limit"
+	// DefHashAggGroupLimit is the default value of tidb_hash_agg_group_limit.
+	DefHashAggGroupLimit uint64 = 1000000
 )
 
 // GlobalVarAccessor is the interface for accessing global scope system and status variables.
@@ -144,6 +144,17 @@ func (s *tikvStore) CurrentVersion() (kv.Version, error) {
 	return kv.NewVersion(startTS), nil
 }
 
+// RegionCount returns the number of the regions that the keys in [startKey, endKey) are stored in, which are
+// looked up in the region cache.
+func (s *tikvStore) RegionCount(startKey, endKey kv.Key) (int, error) {
+	bo := NewBackoffer(copBuildTaskMaxBackoff)
+	tasks, err := buildCopTasks(bo, s.regionCache, []kv.KeyRange{{StartKey: startKey, EndKey: endKey}}, false)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return len(tasks), nil
+}
+
 func (s *tikvStore) getTimestampWithRetry(bo *Backoffer) (uint64, error) {
 	for {
 		startTS, err := s.oracle.GetTimestamp()
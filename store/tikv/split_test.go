@@ -71,3 +71,32 @@ func (s *testSplitSuite) TestSplitBatchGet(c *C) {
 	err = snapshot.batchGetSingleRegion(s.bo, batch, func([]byte, []byte) {})
 	c.Assert(err, IsNil)
 }
+
+func (s *testSplitSuite) TestRegionCount(c *C) {
+	firstRegion, err := s.store.regionCache.GetRegion(s.bo, []byte("a"))
+	c.Assert(err, IsNil)
+	s.split(c, firstRegion.GetID(), []byte("b"))
+	s.store.regionCache.DropRegion(firstRegion.VerID())
+	secondRegion, err := s.store.regionCache.GetRegion(s.bo, []byte("b"))
+	c.Assert(err, IsNil)
+	s.split(c, secondRegion.GetID(), []byte("c"))
+	s.store.regionCache.DropRegion(secondRegion.VerID())
+
+	cases := []struct {
+		startKey string
+		endKey   string
+		regions  int
+	}{
+		{"a", "a1", 1},
+		{"a", "b", 1},
+		{"a", "b1", 2},
+		{"b1", "c1", 2},
+		{"a", "c1", 3},
+		{"c", "d", 1},
+	}
+	for _, ca := range cases {
+		regions, err := s.store.RegionCount([]byte(ca.startKey), []byte(ca.endKey))
+		c.Assert(err, IsNil)
+		c.Assert(regions, Equals, ca.regions, Commentf("for [%s, %s)", ca.startKey, ca.endKey))
+	}
+}
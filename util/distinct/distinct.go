@@ -26,9 +26,18 @@ func CreateDistinctChecker() *Checker {
 	}
 }
 
+// CreateSortedDistinctChecker creates a distinct checker for the data that are checked in order, so the equal data
+// are adjacent and only the last key is stored.
+func CreateSortedDistinctChecker() *Checker {
+	return &Checker{sorted: true}
+}
+
 // Checker stores existing keys and checks if given data is distinct.
 type Checker struct {
 	existingKeys map[string]bool
+	sorted       bool
+	hasLastKey   bool
+	lastKey      string
 }
 
 // Check checks if values is distinct.
@@ -38,6 +47,13 @@ func (d *Checker) Check(values []interface{}) (bool, error) {
 		return false, errors.Trace(err)
 	}
 	key := string(bs)
+	if d.sorted {
+		if d.hasLastKey && d.lastKey == key {
+			return false, nil
+		}
+		d.hasLastKey, d.lastKey = true, key
+		return true, nil
+	}
 	_, ok := d.existingKeys[key]
 	if ok {
 		return false, nil
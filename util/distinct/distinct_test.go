@@ -49,3 +49,25 @@ func (s *testDistinctSuite) TestDistinct(c *check.C) {
 		c.Assert(d, check.Equals, t.expect)
 	}
 }
+
+func (s *testDistinctSuite) TestSortedDistinct(c *check.C) {
+	defer testleak.AfterTest(c)()
+	dc := CreateSortedDistinctChecker()
+	cases := []struct {
+		vals   []interface{}
+		expect bool
+	}{
+		{[]interface{}{1, nil}, true},
+		{[]interface{}{1, nil}, false},
+		{[]interface{}{1, 1}, true},
+		{[]interface{}{1, 1}, false},
+		{[]interface{}{1, 2}, true},
+		{[]interface{}{2, 1}, true},
+		{[]interface{}{2, 1}, false},
+	}
+	for _, t := range cases {
+		d, err := dc.Check(t.vals)
+		c.Assert(err, check.IsNil)
+		c.Assert(d, check.Equals, t.expect)
+	}
+}